@@ -3,23 +3,20 @@ module github.com/v1Flows/runner-plugins/action-plugins/actions_check
 go 1.24.0
 
 require (
-	github.com/hashicorp/go-plugin v1.6.3
 	github.com/v1Flows/runner v1.3.0
-)
-
-require (
-	github.com/go-viper/mapstructure/v2 v2.2.1 // indirect
-	github.com/v1Flows/alertFlow/services/backend v0.0.0-20250317112742-7a11f04dd445 // indirect
-	github.com/v1Flows/exFlow/services/backend v0.0.0-20250729085929-95657664575c // indirect
+	github.com/v1Flows/runner-plugins/sdk v0.0.0
+	github.com/v1Flows/shared-library v1.0.25
 )
 
 require (
 	github.com/fatih/color v1.18.0 // indirect
 	github.com/fsnotify/fsnotify v1.8.0 // indirect
+	github.com/go-viper/mapstructure/v2 v2.2.1 // indirect
 	github.com/golang-jwt/jwt/v5 v5.2.2 // indirect
 	github.com/golang/protobuf v1.5.4 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/hashicorp/go-hclog v1.6.3 // indirect
+	github.com/hashicorp/go-plugin v1.6.3 // indirect
 	github.com/hashicorp/yamux v0.1.1 // indirect
 	github.com/jhump/protoreflect v1.17.0 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
@@ -38,7 +35,8 @@ require (
 	github.com/subosito/gotenv v1.6.0 // indirect
 	github.com/tmthrgd/go-hex v0.0.0-20190904060850-447a3041c3bc // indirect
 	github.com/uptrace/bun v1.2.11 // indirect
-	github.com/v1Flows/shared-library v1.0.25
+	github.com/v1Flows/alertFlow/services/backend v0.0.0-20250317112742-7a11f04dd445 // indirect
+	github.com/v1Flows/exFlow/services/backend v0.0.0-20250729085929-95657664575c // indirect
 	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	go.uber.org/atomic v1.10.0 // indirect
@@ -52,3 +50,5 @@ require (
 	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/v1Flows/runner-plugins/sdk => ../../sdk
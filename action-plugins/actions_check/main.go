@@ -1,19 +1,13 @@
 package main
 
 import (
-	"context"
-	"errors"
-	"net/rpc"
 	"strconv"
-	"sync"
 	"time"
 
-	"github.com/v1Flows/runner/pkg/executions"
+	"github.com/v1Flows/runner-plugins/sdk"
 	"github.com/v1Flows/runner/pkg/plugins"
 
 	"github.com/v1Flows/shared-library/pkg/models"
-
-	"github.com/hashicorp/go-plugin"
 )
 
 type Receiver struct {
@@ -21,37 +15,32 @@ type Receiver struct {
 }
 
 // Plugin is an implementation of the Plugin interface
-type Plugin struct{}
+type Plugin struct {
+	sdk.ActionPlugin
+}
 
-var (
-	taskCancels   = make(map[string]context.CancelFunc)
-	taskCancelsMu sync.Mutex
-)
+var tasks = sdk.NewTaskRegistry()
 
 func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	stepID := request.Step.ID.String()
+	ctx, done, err := tasks.Register(request)
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+	defer done()
 
-	// Store cancel func
-	taskCancelsMu.Lock()
-	taskCancels[stepID] = cancel
-	taskCancelsMu.Unlock()
-	defer func() {
-		taskCancelsMu.Lock()
-		delete(taskCancels, stepID)
-		taskCancelsMu.Unlock()
-	}()
+	reporter := sdk.NewStepReporter(request)
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err := reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Cancel",
 					Lines: []models.Line{
 						{
-							Content:   "Action canceled",
+							Content:   sdk.CancelReason(ctx),
 							Color:     "danger",
 							Timestamp: time.Now(),
 						},
@@ -60,7 +49,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "canceled",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -70,8 +59,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		return plugins.Response{Success: false, Canceled: true}, nil
 	}
 
-	err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Actions Checks",
@@ -85,7 +73,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		},
 		Status:    "running",
 		StartedAt: time.Now(),
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -102,8 +90,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 
 		if count == 0 {
-			err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
+			err := reporter.Update(models.ExecutionSteps{
 				Messages: []models.Message{
 					{
 						Title: "Actions Checks",
@@ -120,7 +107,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 				CanceledBy: "Flow Action Check",
 				CanceledAt: time.Now(),
 				FinishedAt: time.Now(),
-			}, request.Platform)
+			})
 			if err != nil {
 				return plugins.Response{
 					Success: false,
@@ -133,8 +120,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 				Success: false,
 			}, nil
 		} else {
-			err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
+			err := reporter.Update(models.ExecutionSteps{
 				Messages: []models.Message{
 					{
 						Title: "Actions Checks",
@@ -149,7 +135,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 				},
 				Status:     "success",
 				FinishedAt: time.Now(),
-			}, request.Platform)
+			})
 			if err != nil {
 				return plugins.Response{
 					Success: false,
@@ -160,8 +146,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			}, nil
 		}
 	} else {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err := reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Actions Checks",
@@ -187,7 +172,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			CanceledBy: "Flow Action Check",
 			CanceledAt: time.Now(),
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -203,32 +188,14 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 }
 
 func (p *Plugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
-	stepID := request.Step.ID.String()
-	taskCancelsMu.Lock()
-	cancel, ok := taskCancels[stepID]
-	taskCancelsMu.Unlock()
-
-	if !ok {
-		return plugins.Response{
-			Success: false,
-		}, errors.New("task not found")
-	}
-
-	cancel()
-	return plugins.Response{Success: true}, nil
-}
-
-func (p *Plugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
+	return tasks.Cancel(request)
 }
 
 func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	var plugin = models.Plugin{
 		Name:    "Actions Check",
 		Type:    "action",
-		Version: "1.4.3",
+		Version: "1.5.0",
 		Author:  "JustNZ",
 		Action: models.Action{
 			Name:        "Actions Check",
@@ -236,7 +203,7 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 			Plugin:      "actions_check",
 			Icon:        "hugeicons:blockchain-06",
 			Category:    "Utility",
-			Params:      nil,
+			Params:      []models.Params{sdk.TimeoutParam()},
 		},
 		Endpoint: models.Endpoint{},
 	}
@@ -244,58 +211,6 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	return plugin, nil
 }
 
-// PluginRPCServer is the RPC server for Plugin
-type PluginRPCServer struct {
-	Impl plugins.Plugin
-}
-
-func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.ExecuteTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.CancelTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
-	result, err := s.Impl.EndpointRequest(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *models.Plugin) error {
-	result, err := s.Impl.Info(request)
-	*resp = result
-	return err
-}
-
-// PluginServer is the implementation of plugin.Plugin interface
-type PluginServer struct {
-	Impl plugins.Plugin
-}
-
-func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
-}
-
-func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &plugins.PluginRPC{Client: c}, nil
-}
-
 func main() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: plugin.HandshakeConfig{
-			ProtocolVersion:  1,
-			MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
-			MagicCookieValue: "hello",
-		},
-		Plugins: map[string]plugin.Plugin{
-			"plugin": &PluginServer{Impl: &Plugin{}},
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
-	})
+	sdk.Serve(&Plugin{})
 }
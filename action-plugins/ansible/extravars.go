@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+)
+
+// payloadPlaceholder matches {{ payload.<key> }} in extra vars values. Other
+// {{ }} expressions are left to ansible.
+var payloadPlaceholder = regexp.MustCompile(`\{\{\s*payload\.([^\s{}]+)\s*\}\}`)
+
+// parseExtraVars parses the extra_vars param, a JSON or YAML mapping, and
+// replaces the {{ payload.<key> }} placeholders in its values with the values
+// of the alert payload. A value that is a single placeholder takes the type of
+// the payload value, so it can also insert numbers, lists and objects.
+//
+// The payload is sent by whoever triggers the alert, so every string that
+// contains payload data is an unsafeString and never templated by ansible.
+func parseExtraVars(document string, payload []byte) (map[string]interface{}, error) {
+	var vars map[string]interface{}
+	if err := yaml.Unmarshal([]byte(document), &vars); err != nil {
+		return nil, fmt.Errorf("extra vars are not a JSON or YAML mapping: %w", err)
+	}
+
+	rendered, err := renderPayload(vars, string(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	vars, _ = rendered.(map[string]interface{})
+	return vars, nil
+}
+
+// renderPayload replaces the payload placeholders in every string of v
+func renderPayload(v interface{}, payload string) (interface{}, error) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			rendered, err := renderPayload(item, payload)
+			if err != nil {
+				return nil, err
+			}
+			value[key] = rendered
+		}
+		return value, nil
+	case []interface{}:
+		for i, item := range value {
+			rendered, err := renderPayload(item, payload)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = rendered
+		}
+		return value, nil
+	case string:
+		return renderString(value, payload)
+	default:
+		return v, nil
+	}
+}
+
+func renderString(s string, payload string) (interface{}, error) {
+	if !payloadPlaceholder.MatchString(s) {
+		return s, nil
+	}
+
+	if match := payloadPlaceholder.FindStringSubmatch(s); match[0] == strings.TrimSpace(s) {
+		result, err := lookupPayload(payload, match[1])
+		if err != nil {
+			return nil, err
+		}
+		return unsafeValue(result.Value()), nil
+	}
+
+	var lookupErr error
+	rendered := payloadPlaceholder.ReplaceAllStringFunc(s, func(placeholder string) string {
+		result, err := lookupPayload(payload, payloadPlaceholder.FindStringSubmatch(placeholder)[1])
+		if err != nil {
+			lookupErr = err
+			return placeholder
+		}
+		return result.String()
+	})
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+
+	return unsafeString(rendered), nil
+}
+
+// unsafeString is written with the !unsafe tag, ansible uses it as it is
+// instead of rendering the {{ }} expressions in it
+type unsafeString string
+
+func (s unsafeString) MarshalYAML() (interface{}, error) {
+	return &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   "!unsafe",
+		Style: yaml.DoubleQuotedStyle,
+		Value: string(s),
+	}, nil
+}
+
+// unsafeValue marks every string of a payload value as unsafeString
+func unsafeValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = unsafeValue(item)
+		}
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = unsafeValue(item)
+		}
+		return value
+	case string:
+		return unsafeString(value)
+	default:
+		return v
+	}
+}
+
+func lookupPayload(payload string, key string) (gjson.Result, error) {
+	if payload == "" {
+		return gjson.Result{}, fmt.Errorf("payload.%s is used, but the execution has no alert payload", key)
+	}
+
+	result := gjson.Get(payload, key)
+	if !result.Exists() {
+		return gjson.Result{}, fmt.Errorf("payload.%s does not exist in the alert payload", key)
+	}
+	return result, nil
+}
+
+// writeExtraVars writes vars to a temporary YAML file only readable by the
+// runner user, so they do not show up on the ansible-playbook command line.
+// YAML keeps the !unsafe tags of payload values.
+func writeExtraVars(vars map[string]interface{}) (string, error) {
+	data, err := yaml.Marshal(vars)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.CreateTemp("", "extra-vars-*.yml")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
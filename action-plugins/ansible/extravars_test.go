@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExtraVarsPayloadIsUnsafe(t *testing.T) {
+	payload := []byte(`{"labels":{"instance":"{{ lookup('pipe','id') }}"},"hosts":["web1","{% raw %}"],"port":22}`)
+	document := `
+host: "{{ payload.labels.instance }}"
+url: "https://{{ payload.labels.instance }}/health"
+hosts: "{{ payload.hosts }}"
+port: "{{ payload.port }}"
+user: "{{ ansible_user }}"
+`
+
+	vars, err := parseExtraVars(document, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := writeExtraVars(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file)
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		t.Fatalf("extra vars are not YAML: %v\n%s", err, data)
+	}
+
+	tags := map[string]string{}
+	values := map[string]string{}
+	var walk func(path string, node *yaml.Node)
+	walk = func(path string, node *yaml.Node) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			walk(path, node.Content[0])
+		case yaml.MappingNode:
+			for i := 0; i < len(node.Content); i += 2 {
+				walk(strings.TrimPrefix(path+"."+node.Content[i].Value, "."), node.Content[i+1])
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				walk(path+"."+strconv.Itoa(i), item)
+			}
+		case yaml.ScalarNode:
+			tags[path] = node.Tag
+			values[path] = node.Value
+		}
+	}
+	walk("", &root)
+
+	tests := []struct {
+		path  string
+		tag   string
+		value string
+	}{
+		{path: "host", tag: "!unsafe", value: "{{ lookup('pipe','id') }}"},
+		{path: "url", tag: "!unsafe", value: "https://{{ lookup('pipe','id') }}/health"},
+		{path: "hosts.0", tag: "!unsafe", value: "web1"},
+		{path: "hosts.1", tag: "!unsafe", value: "{% raw %}"},
+		{path: "port", tag: "!!int", value: "22"},
+		// values without payload data are still templated by ansible
+		{path: "user", tag: "!!str", value: "{{ ansible_user }}"},
+	}
+	for _, tt := range tests {
+		if tags[tt.path] != tt.tag || values[tt.path] != tt.value {
+			t.Errorf("%s = %s %q, want %s %q", tt.path, tags[tt.path], values[tt.path], tt.tag, tt.value)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/apenella/go-ansible/v2/pkg/execute"
+	collection "github.com/apenella/go-ansible/v2/pkg/galaxy/collection/install"
+	role "github.com/apenella/go-ansible/v2/pkg/galaxy/role/install"
+	"gopkg.in/yaml.v3"
+)
+
+// SourceNone is the requirements_source without requirements to install
+const SourceNone = "none"
+
+// Default search paths of ansible, kept behind the installed requirements so
+// content installed on the runner is still found
+const (
+	defaultCollectionsPath = "~/.ansible/collections:/usr/share/ansible/collections"
+	defaultRolesPath       = "~/.ansible/roles:/usr/share/ansible/roles:/etc/ansible/roles"
+)
+
+// galaxyCacheMu serializes installs into the cache, steps running at the same
+// time with the same requirements share a directory
+var galaxyCacheMu sync.Mutex
+
+// requirements tells which parts of a requirements.yml list anything to
+// install. The old format is a plain list of roles. An empty document lists
+// nothing.
+func requirements(content []byte) (roles bool, collections bool, err error) {
+	var document interface{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return false, false, fmt.Errorf("requirements are no valid YAML: %w", err)
+	}
+
+	switch value := document.(type) {
+	case nil:
+		return false, false, nil
+	case []interface{}:
+		return len(value) > 0, false, nil
+	case map[string]interface{}:
+		roleList, _ := value["roles"].([]interface{})
+		collectionList, _ := value["collections"].([]interface{})
+		return len(roleList) > 0, len(collectionList) > 0, nil
+	default:
+		return false, false, fmt.Errorf("requirements must be a list of roles or a mapping with roles and collections")
+	}
+}
+
+// galaxyDir returns the directory requirements are installed into: a
+// directory per requirements content in the cache directory of the runner
+// user if cache is set, the workspace of the run otherwise
+func galaxyDir(content []byte, cache bool, ws *workspace) (string, error) {
+	if !cache {
+		return filepath.Join(ws.dir, "galaxy"), nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return filepath.Join(dir, "runner-plugins", "ansible", "galaxy", hex.EncodeToString(sum[:8])), nil
+}
+
+// installRequirements runs ansible-galaxy role install and collection install
+// for the requirements file with content into dir and writes their output to
+// output. It returns the environment pointing ansible-playbook to the
+// installed content, nil if the requirements list nothing to install.
+func installRequirements(ctx context.Context, file string, content []byte, dir string, cache bool, output *CustomWriter) (map[string]string, error) {
+	roles, collections, err := requirements(content)
+	if err != nil {
+		return nil, err
+	}
+	if !roles && !collections {
+		return nil, nil
+	}
+
+	if cache {
+		galaxyCacheMu.Lock()
+		defer galaxyCacheMu.Unlock()
+	}
+
+	rolesPath := filepath.Join(dir, "roles")
+	collectionsPath := filepath.Join(dir, "collections")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	if roles {
+		cmd := role.NewAnsibleGalaxyRoleInstallCmd(
+			role.WithGalaxyRoleInstallOptions(&role.AnsibleGalaxyRoleInstallOptions{
+				RoleFile:  file,
+				RolesPath: rolesPath,
+			}),
+		)
+		err := execute.NewDefaultExecute(
+			execute.WithCmd(cmd),
+			execute.WithWrite(output),
+		).Execute(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("role install failed: %w", err)
+		}
+	}
+
+	if collections {
+		cmd := collection.NewAnsibleGalaxyCollectionInstallCmd(
+			collection.WithGalaxyCollectionInstallOptions(&collection.AnsibleGalaxyCollectionInstallOptions{
+				RequirementsFile: file,
+				CollectionsPath:  collectionsPath,
+			}),
+		)
+		err := execute.NewDefaultExecute(
+			execute.WithCmd(cmd),
+			execute.WithWrite(output),
+		).Execute(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("collection install failed: %w", err)
+		}
+	}
+
+	return map[string]string{
+		"ANSIBLE_COLLECTIONS_PATH": searchPath(collectionsPath, "ANSIBLE_COLLECTIONS_PATH", defaultCollectionsPath),
+		"ANSIBLE_ROLES_PATH":       searchPath(rolesPath, "ANSIBLE_ROLES_PATH", defaultRolesPath),
+	}, nil
+}
+
+// searchPath puts dir in front of the search path the runner configured in
+// env, or the ansible default
+func searchPath(dir string, env string, fallback string) string {
+	paths := os.Getenv(env)
+	if strings.TrimSpace(paths) == "" {
+		paths = fallback
+	}
+	return dir + ":" + paths
+}
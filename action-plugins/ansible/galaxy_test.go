@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRequirements(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		wantRoles       bool
+		wantCollections bool
+		wantErr         bool
+	}{
+		{name: "empty", content: ""},
+		{name: "only comments", content: "# nothing yet\n"},
+		{name: "old role list", content: "- src: geerlingguy.nginx\n", wantRoles: true},
+		{name: "empty role list", content: "[]\n"},
+		{name: "roles and collections", content: "roles:\n  - name: geerlingguy.nginx\ncollections:\n  - community.general\n", wantRoles: true, wantCollections: true},
+		{name: "only collections", content: "collections:\n  - name: community.general\n    version: '>=8.0.0'\n", wantCollections: true},
+		{name: "empty sections", content: "roles: []\ncollections:\n"},
+		{name: "scalar", content: "community.general\n", wantErr: true},
+		{name: "invalid yaml", content: "roles: [\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roles, collections, err := requirements([]byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("requirements() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if roles != tt.wantRoles || collections != tt.wantCollections {
+				t.Errorf("requirements() = %v, %v, want %v, %v", roles, collections, tt.wantRoles, tt.wantCollections)
+			}
+		})
+	}
+}
+
+func TestInstallRequirementsWithoutRequirements(t *testing.T) {
+	for _, content := range []string{"", "roles: []\ncollections: []\n"} {
+		dir := filepath.Join(t.TempDir(), "galaxy")
+		var output []string
+		writer := &CustomWriter{OutputFunc: func(line string, _ string) { output = append(output, line) }}
+
+		// ansible-galaxy is not run, the test passes on runners without ansible
+		env, err := installRequirements(context.Background(), "requirements.yml", []byte(content), dir, false, writer)
+		if err != nil || env != nil {
+			t.Errorf("installRequirements(%q) = %v, %v, want nothing installed", content, env, err)
+		}
+		if len(output) > 0 {
+			t.Errorf("installRequirements(%q) wrote %q", content, output)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("installRequirements(%q) created %s", content, dir)
+		}
+	}
+
+	if _, err := installRequirements(context.Background(), "requirements.yml", []byte("galaxy"), t.TempDir(), false, &CustomWriter{}); err == nil {
+		t.Error("installRequirements() accepted invalid requirements")
+	}
+}
+
+func TestGalaxyDir(t *testing.T) {
+	ws := &workspace{dir: t.TempDir()}
+
+	if got, want := mustGalaxyDir(t, []byte("roles: []"), false, ws), filepath.Join(ws.dir, "galaxy"); got != want {
+		t.Errorf("galaxyDir() = %q, want %q", got, want)
+	}
+
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	first := mustGalaxyDir(t, []byte("roles: [a]"), true, ws)
+	if !strings.HasPrefix(first, filepath.Join(cache, "runner-plugins", "ansible", "galaxy")) {
+		t.Errorf("galaxyDir() = %q, want a directory in the user cache", first)
+	}
+	if got := mustGalaxyDir(t, []byte("roles: [a]"), true, ws); got != first {
+		t.Errorf("galaxyDir() of the same requirements = %q, want %q", got, first)
+	}
+	if got := mustGalaxyDir(t, []byte("roles: [b]"), true, ws); got == first {
+		t.Errorf("galaxyDir() of other requirements = %q, want another directory", got)
+	}
+}
+
+func mustGalaxyDir(t *testing.T, content []byte, cache bool, ws *workspace) string {
+	t.Helper()
+
+	dir, err := galaxyDir(content, cache, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestSearchPath(t *testing.T) {
+	t.Setenv("ANSIBLE_ROLES_PATH", "")
+	if got, want := searchPath("/run/roles", "ANSIBLE_ROLES_PATH", defaultRolesPath), "/run/roles:"+defaultRolesPath; got != want {
+		t.Errorf("searchPath() = %q, want %q", got, want)
+	}
+
+	t.Setenv("ANSIBLE_ROLES_PATH", "/opt/roles")
+	if got, want := searchPath("/run/roles", "ANSIBLE_ROLES_PATH", defaultRolesPath), "/run/roles:/opt/roles"; got != want {
+		t.Errorf("searchPath() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Modes of the host_key_checking param
+const (
+	HostKeyStrict    = "strict"
+	HostKeyAcceptNew = "accept_new"
+	HostKeyInline    = "known_hosts"
+	HostKeyInsecure  = "insecure"
+)
+
+// sshCommonArgs returns the ssh options verifying the host keys of the
+// inventory hosts. Inline known_hosts content is written to a temporary file,
+// the returned func removes it again.
+func sshCommonArgs(mode string, knownHostsFile string, knownHosts string) (string, func(), error) {
+	cleanup := func() {}
+
+	switch mode {
+	case HostKeyStrict, HostKeyAcceptNew:
+		strict := "yes"
+		if mode == HostKeyAcceptNew {
+			strict = "accept-new"
+		}
+
+		args := "-o StrictHostKeyChecking=" + strict
+		if knownHostsFile != "" {
+			args += " -o UserKnownHostsFile=" + quoteArg(knownHostsFile)
+		}
+		return args, cleanup, nil
+	case HostKeyInline:
+		if strings.TrimSpace(knownHosts) == "" {
+			return "", cleanup, fmt.Errorf("no known_hosts lines given")
+		}
+
+		file, err := os.CreateTemp("", "known-hosts")
+		if err != nil {
+			return "", cleanup, err
+		}
+		cleanup = func() { _ = os.Remove(file.Name()) }
+
+		_, err = file.WriteString(strings.TrimSpace(knownHosts) + "\n")
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			cleanup()
+			return "", func() {}, err
+		}
+
+		return "-o StrictHostKeyChecking=yes -o UserKnownHostsFile=" + quoteArg(file.Name()), cleanup, nil
+	case HostKeyInsecure:
+		return "-o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null", cleanup, nil
+	default:
+		return "", cleanup, fmt.Errorf("unknown host key checking mode %q", mode)
+	}
+}
+
+// quoteArg quotes s for the shell-like splitting ansible applies to ssh args
+func quoteArg(s string) string {
+	if !strings.ContainsAny(s, " \t'\"\\") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSSHCommonArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		mode           string
+		knownHostsFile string
+		want           string
+		wantErr        bool
+	}{
+		{name: "strict", mode: HostKeyStrict, want: "-o StrictHostKeyChecking=yes"},
+		{name: "strict with file", mode: HostKeyStrict, knownHostsFile: "/etc/ssh/known_hosts", want: "-o StrictHostKeyChecking=yes -o UserKnownHostsFile=/etc/ssh/known_hosts"},
+		{name: "accept new", mode: HostKeyAcceptNew, want: "-o StrictHostKeyChecking=accept-new"},
+		{name: "accept new with quoted file", mode: HostKeyAcceptNew, knownHostsFile: "/srv/my hosts/it's", want: `-o StrictHostKeyChecking=accept-new -o UserKnownHostsFile='/srv/my hosts/it'"'"'s'`},
+		{name: "insecure", mode: HostKeyInsecure, want: "-o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null"},
+		{name: "insecure ignores file", mode: HostKeyInsecure, knownHostsFile: "/etc/ssh/known_hosts", want: "-o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null"},
+		{name: "unknown mode", mode: "off", wantErr: true},
+		{name: "empty mode", mode: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, cleanup, err := sshCommonArgs(tt.mode, tt.knownHostsFile, "")
+			defer cleanup()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sshCommonArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("sshCommonArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSHCommonArgsKnownHosts(t *testing.T) {
+	content := "\n  web1 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIE\r\n[web2]:2222 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIF\n\n"
+
+	args, cleanup, err := sshCommonArgs(HostKeyInline, "/ignored", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const prefix = "-o StrictHostKeyChecking=yes -o UserKnownHostsFile="
+	if !strings.HasPrefix(args, prefix) {
+		t.Fatalf("sshCommonArgs() = %q, want the inline known_hosts file", args)
+	}
+	file := strings.Trim(strings.TrimPrefix(args, prefix), "'")
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), strings.TrimSpace(content)+"\n"; got != want {
+		t.Errorf("known_hosts file = %q, want %q", got, want)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		t.Errorf("known_hosts file mode = %v, want private", info.Mode().Perm())
+	}
+
+	cleanup()
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("known_hosts file still exists after cleanup: %v", err)
+	}
+
+	if _, _, err := sshCommonArgs(HostKeyInline, "", " \n"); err == nil {
+		t.Error("sshCommonArgs() accepted empty known_hosts content")
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{arg: "/etc/ssh/known_hosts", want: "/etc/ssh/known_hosts"},
+		{arg: "/tmp/a b", want: "'/tmp/a b'"},
+		{arg: "/tmp/a\tb", want: "'/tmp/a\tb'"},
+		{arg: `/tmp/"a"`, want: `'/tmp/"a"'`},
+		{arg: `/tmp/a\b`, want: `'/tmp/a\b'`},
+		{arg: "/tmp/it's", want: `'/tmp/it'"'"'s'`},
+	}
+
+	for _, tt := range tests {
+		if got := quoteArg(tt.arg); got != tt.want {
+			t.Errorf("quoteArg(%q) = %q, want %q", tt.arg, got, tt.want)
+		}
+	}
+}
@@ -1,30 +1,26 @@
 package main
 
 import (
-	"context"
 	"errors"
 	"net"
-	"net/rpc"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
-	"time"
 
 	"github.com/apenella/go-ansible/v2/pkg/execute"
 	"github.com/apenella/go-ansible/v2/pkg/execute/configuration"
 	"github.com/apenella/go-ansible/v2/pkg/playbook"
 
-	"github.com/v1Flows/runner/pkg/executions"
+	"github.com/v1Flows/runner-plugins/sdk"
 	"github.com/v1Flows/runner/pkg/plugins"
 	"github.com/v1Flows/shared-library/pkg/models"
-
-	"github.com/hashicorp/go-plugin"
 )
 
 // Plugin is an implementation of the Plugin interface
-type Plugin struct{}
+type Plugin struct {
+	sdk.ActionPlugin
+}
 
 // Map ANSI color codes to models.Line.Color values
 var ansiToLineColor = map[string]string{
@@ -37,10 +33,46 @@ var ansiToLineColor = map[string]string{
 	"\033[0;0m":  "",           // Reset
 }
 
-var (
-	taskCancels   = make(map[string]context.CancelFunc)
-	taskCancelsMu sync.Mutex
-)
+var tasks = sdk.NewTaskRegistry()
+
+// Params are the action params of the ansible plugin
+type Params struct {
+	PlaybookSource       string   `param:"playbook_source,required" title:"Playbook Source" type:"select" default:"file" options:"file=File,inline=Inline" category:"General" description:"Run a playbook file on the runner or the playbook given inline"`
+	Playbook             string   `param:"playbook,required" title:"Playbook" depends:"playbook_source=file" category:"General" description:"Path to the playbook file"`
+	PlaybookContent      string   `param:"playbook_content,required" title:"Playbook Content" type:"textarea" depends:"playbook_source=inline" category:"General" description:"The YAML playbook. It is written to a private temporary directory that is removed after the run, relative paths in it resolve against that directory"`
+	InventorySource      string   `param:"inventory_source,required" title:"Inventory Source" type:"select" default:"file" options:"file=File or Host List,inline=Inline,payload=Alert Payload" category:"General" description:"Use an inventory file on the runner or a host list, an inline inventory, or generate the inventory from the alert payload"`
+	Inventory            string   `param:"inventory,required" title:"Inventory" depends:"inventory_source=file" category:"General" description:"Path to the inventory file or comma separated host list"`
+	InventoryContent     string   `param:"inventory_content,required" title:"Inventory Content" type:"textarea" depends:"inventory_source=inline" category:"General" description:"The inventory in YAML or INI format"`
+	InventoryKeys        []string `param:"inventory_keys,required" title:"Inventory Payload Keys" default:"labels.instance" depends:"inventory_source=payload" category:"General" description:"Keys of the alert payload holding the hosts, one per line, e.g. labels.instance or alerts.#.labels.instance for all alerts of a group"`
+	InventoryGroup       string   `param:"inventory_group" title:"Inventory Group" default:"alert_hosts" depends:"inventory_source=payload" category:"General" description:"The group the hosts of the alert payload are added to"`
+	InventoryStripPort   bool     `param:"inventory_strip_port" title:"Strip Port" default:"true" depends:"inventory_source=payload" category:"General" description:"Remove the port of host:port values, like the exporter port in the instance label of Prometheus alerts"`
+	RequirementsSource   string   `param:"requirements_source,required" title:"Requirements Source" type:"select" default:"none" options:"none=None,file=File,inline=Inline" category:"Galaxy" description:"Install roles and collections with ansible-galaxy before the playbook runs, from a requirements.yml on the runner or given inline"`
+	Requirements         string   `param:"requirements,required" title:"Requirements" depends:"requirements_source=file" category:"Galaxy" description:"Path to the requirements.yml file"`
+	RequirementsContent  string   `param:"requirements_content,required" title:"Requirements Content" type:"textarea" depends:"requirements_source=inline" category:"Galaxy" description:"The content of a requirements.yml with roles and collections"`
+	RequirementsCache    bool     `param:"requirements_cache" title:"Cache Requirements" default:"false" category:"Galaxy" description:"Install the requirements into a cache directory of the runner user that is reused by later runs with the same requirements. Otherwise they are installed for this run only and removed afterwards"`
+	Authentication       bool     `param:"authentication,required" title:"Authentication" default:"false" category:"Authentication" description:"Use authentication for the Ansible connection. If enabled, you must provide a authentication method."`
+	AuthenticationMethod string   `param:"authentication_method" title:"Authentication Method" type:"select" default:"none" options:"none=None,password=Password,private_key_file=Private Key File" depends:"authentication=true" category:"Authentication" description:"The authentication method to use"`
+	User                 string   `param:"user" title:"User" depends:"authentication_method=password" category:"Authentication" description:"Connect as this user"`
+	Password             string   `param:"password" title:"Password" type:"password" depends:"authentication_method=password" category:"Authentication" description:"Connection user password"`
+	PrivateKey           string   `param:"private_key" title:"Private Key" depends:"authentication_method=private_key_file" category:"Authentication" description:"Path to the private key file"`
+	Limit                string   `param:"limit" title:"Limit" category:"General" description:"Further limit selected hosts to an additional pattern"`
+	ExtraVars            string   `param:"extra_vars" title:"Extra Vars" type:"textarea" category:"Variables" description:"Variables passed to the playbook as a JSON or YAML mapping. {{ payload.<key> }} in a value is replaced with the value of the alert payload, e.g. host: \"{{ payload.labels.instance }}\". Ansible does not render other {{ }} expressions in such a value, so the payload cannot inject templates"`
+	Tags                 []string `param:"tags" title:"Tags" category:"Tasks" description:"Only run plays and tasks tagged with these tags, one per line"`
+	SkipTags             []string `param:"skip_tags" title:"Skip Tags" category:"Tasks" description:"Only run plays and tasks whose tags do not match these tags, one per line"`
+	StartAtTask          string   `param:"start_at_task" title:"Start At Task" category:"Tasks" description:"Start the playbook at the task with this name"`
+	Forks                int      `param:"forks" title:"Forks" default:"0" category:"Tasks" description:"The number of parallel processes to use. 0 keeps the ansible default"`
+	Become               bool     `param:"become,required" title:"Become" default:"false" depends:"authentication=true" category:"Sudo" description:"Run playbook with become. Requires authentication to be enabled"`
+	BecomeUser           string   `param:"become_user" title:"Become User" default:"root" depends:"become=true" category:"Sudo" description:"User to run become tasks with"`
+	BecomePass           string   `param:"become_pass" title:"Become Password" type:"password" depends:"become=true" category:"Sudo" description:"Become user password"`
+	HostKeyChecking      string   `param:"host_key_checking,required" title:"Host Key Checking" type:"select" default:"accept_new" options:"accept_new=Accept New,strict=Strict,known_hosts=Known Hosts,insecure=Disabled (insecure)" category:"Host Key" description:"How the host keys of the inventory hosts are verified. Accept new records the keys of new hosts in the known hosts file and rejects changed keys. Strict only accepts hosts already in the known hosts file"`
+	KnownHostsFile       string   `param:"known_hosts_file" title:"Known Hosts File" category:"Host Key" description:"Path of the known_hosts file on the runner used by accept new and strict. Defaults to ~/.ssh/known_hosts of the runner user"`
+	KnownHosts           string   `param:"known_hosts,required" title:"Known Hosts" type:"textarea" depends:"host_key_checking=known_hosts" category:"Host Key" description:"known_hosts lines of the inventory hosts, e.g. the output of ssh-keyscan. Only these keys are accepted"`
+	VaultPasswordFile    string   `param:"vault_password_file" title:"Vault Password File" category:"Vault" description:"Path to Vault Password File"`
+	VaultPassword        string   `param:"vault_password" title:"Vault Password" type:"password" category:"Vault" description:"Vault Password. This will override the vault_password_file"`
+	Check                bool     `param:"check" title:"Check" default:"false" category:"Utility" description:"Don't make any changes; instead, try to predict some of the changes that may occur"`
+	Diff                 bool     `param:"diff" title:"Diff" default:"false" category:"Utility" description:"When changing (small) files and templates, show the differences in those files"`
+	Verbose              int      `param:"verbose" title:"Verbose" default:"0" category:"Utility" description:"Set the verbosity level. Default is 0"`
+}
 
 var ansiRegexp = regexp.MustCompile(`\x1b\[[0-9;]*m`)
 
@@ -71,148 +103,55 @@ func (cw *CustomWriter) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
-func handleOutput(output string, color string, request plugins.ExecuteTaskRequest) error {
-	err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
-		Messages: []models.Message{
-			{
-				Title: "Ansible Playbook",
-				Lines: []models.Line{
-					{
-						Content:   output,
-						Color:     color,
-						Timestamp: time.Now(),
-					},
-				},
-			},
-		},
-		Status: "running",
-	}, request.Platform)
+func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
+	ctx, done, err := tasks.Register(request)
 	if err != nil {
-		return err
+		return plugins.Response{
+			Success: false,
+		}, err
 	}
+	defer done()
 
-	return nil
-}
+	reporter := sdk.NewStepReporter(request)
 
-func sanitizeErrorMessage(msg, password, becomePass string) string {
-	if password != "" {
-		msg = strings.ReplaceAll(msg, password, "****")
+	info, err := p.Info(plugins.InfoRequest{Config: request.Config, Workspace: request.Workspace})
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
 	}
-	if becomePass != "" {
-		msg = strings.ReplaceAll(msg, becomePass, "****")
+
+	var params Params
+	err = reporter.DecodeParams(info.Action.Params, &params)
+	if err != nil {
+		_ = reporter.InvalidParams(err)
+		return plugins.Response{
+			Success: false,
+		}, err
 	}
-	return msg
-}
 
-func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	stepID := request.Step.ID.String()
-
-	// Store cancel func
-	taskCancelsMu.Lock()
-	taskCancels[stepID] = cancel
-	taskCancelsMu.Unlock()
-	defer func() {
-		taskCancelsMu.Lock()
-		delete(taskCancels, stepID)
-		taskCancelsMu.Unlock()
-	}()
-
-	play := ""
-	inventory := ""
-	become := false
-	limit := ""
-	check := false
-	diff := false
-	authentication := false
-	user := ""
-	password := ""
-	becomeUser := ""
-	becomePass := ""
-	verbose := 0
-	private_key := ""
-	vault_password_file := ""
-	vault_password := ""
-
-	// access action params
-	for _, param := range request.Step.Action.Params {
-		if param.Key == "playbook" {
-			if strings.Contains(param.Value, "/") {
-				play = param.Value
-			} else {
-				play = param.Value
-			}
-		}
-		if param.Key == "inventory" {
-			// if inventory is a path prefix with workspace
-			if strings.Contains(param.Value, "/") {
-				inventory = param.Value
-			} else {
-				inventory = param.Value
-			}
-		}
-		if param.Key == "become" {
-			become = param.Value == "true"
-		}
-		if param.Key == "limit" {
-			limit = param.Value
-		}
-		if param.Key == "check" {
-			check = param.Value == "true"
-		}
-		if param.Key == "diff" {
-			diff = param.Value == "true"
-		}
-		if param.Key == "authentication" {
-			authentication = param.Value == "true"
-		}
-		if param.Key == "user" {
-			user = param.Value
-		}
-		if param.Key == "password" {
-			password = param.Value
-		}
-		if param.Key == "become_user" {
-			becomeUser = param.Value
-		}
-		if param.Key == "become_pass" {
-			becomePass = param.Value
-		}
-		if param.Key == "verbose" {
-			verbose, _ = strconv.Atoi(param.Value)
-		}
-		if param.Key == "private_key" {
-			private_key = param.Value
-		}
-		if param.Key == "vault_password_file" {
-			vault_password_file = param.Value
-		}
-		if param.Key == "vault_password" {
-			vault_password = param.Value
+	// the vault password file defaults to the workspace directory, which is not a file
+	if strings.HasSuffix(params.VaultPasswordFile, "/") {
+		params.VaultPasswordFile = ""
+	}
+
+	// fail reports a sanitized error message and finishes the step with status error
+	fail := func(message string, err error) (plugins.Response, error) {
+		updateErr := reporter.Finish(sdk.StatusError, "Ansible Playbook", message, err.Error())
+		if updateErr != nil {
+			return plugins.Response{
+				Success: false,
+			}, updateErr
 		}
+
+		return plugins.Response{
+			Success: false,
+		}, errors.New(strings.ToLower(message))
 	}
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Cancel",
-					Lines: []models.Line{
-						{
-							Content:   "Action canceled",
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status:     "canceled",
-			FinishedAt: time.Now(),
-		}, request.Platform)
-		if err != nil {
+		if err := reporter.Cancelled(ctx); err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
@@ -221,257 +160,238 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		return plugins.Response{Success: false, Canceled: true}, nil
 	}
 
-	err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
-		Messages: []models.Message{
-			{
-				Title: "Ansible Playbook",
-				Lines: []models.Line{
-					{
-						Content:   "Starting Ansible Playbook",
-						Timestamp: time.Now(),
-					},
-					{
-						Content:   "Playbook: " + play,
-						Timestamp: time.Now(),
-					},
-					{
-						Content:   "Inventory: " + inventory,
-						Timestamp: time.Now(),
-					},
-				},
-			},
-		},
-		Status:    "running",
-		StartedAt: time.Now(),
-	}, request.Platform)
+	playbookName, inventoryName := params.Playbook, params.Inventory
+	if params.PlaybookSource == SourceInline {
+		playbookName = "inline"
+	}
+	switch params.InventorySource {
+	case SourceInline:
+		inventoryName = "inline"
+	case SourcePayload:
+		inventoryName = "alert payload (" + strings.Join(params.InventoryKeys, ", ") + ")"
+	}
+
+	err = reporter.Start("Ansible Playbook",
+		"Starting Ansible Playbook",
+		"Playbook: "+playbookName,
+		"Inventory: "+inventoryName,
+	)
 	if err != nil {
 		return plugins.Response{
 			Success: false,
 		}, err
 	}
 
-	// check if playbook file exists
-	if _, err := os.Stat(play); errors.Is(err, os.ErrNotExist) {
-		err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Ansible Playbook",
-					Lines: []models.Line{
-						{
-							Content:   "Playbook file does not exist",
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-						{
-							Content:   sanitizeErrorMessage(err.Error(), password, becomePass),
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status:     "error",
-			FinishedAt: time.Now(),
-		}, request.Platform)
+	// inline playbooks and inventories are written to a private workspace
+	var ws *workspace
+	if params.PlaybookSource != SourceFile || params.InventorySource != SourceFile || params.RequirementsSource != SourceNone {
+		ws, err = newWorkspace()
+		if err != nil {
+			return fail("Failed to create temporary workspace", err)
+		}
+		defer ws.Remove()
+	}
+
+	if params.PlaybookSource == SourceInline {
+		params.Playbook, err = ws.WriteFile("playbook.yml", params.PlaybookContent)
+		if err != nil {
+			return fail("Failed to write playbook", err)
+		}
+	}
+
+	switch params.InventorySource {
+	case SourceInline:
+		params.Inventory, err = ws.WriteFile(inventoryFileName(params.InventoryContent), params.InventoryContent)
+		if err != nil {
+			return fail("Failed to write inventory", err)
+		}
+	case SourcePayload:
+		content, hosts, err := payloadInventory(request.Alert.Payload, params.InventoryKeys, params.InventoryGroup, params.InventoryStripPort)
+		if err != nil {
+			return fail("Failed to generate inventory from alert payload", err)
+		}
+
+		params.Inventory, err = ws.WriteFile("inventory.yml", content)
+		if err != nil {
+			return fail("Failed to write inventory", err)
+		}
+
+		err = reporter.Info("Ansible Playbook", "Inventory hosts: "+strings.Join(hosts, ", "))
 		if err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
 		}
-		return plugins.Response{
-			Success: false,
-		}, errors.New("playbook file does not exist")
 	}
 
-	// if inventory is a file check and not a comma separated list check if file exists
-	if !strings.Contains(inventory, ",") && net.ParseIP(inventory) == nil {
-		if _, err := os.Stat(inventory); errors.Is(err, os.ErrNotExist) {
-			err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
-				Messages: []models.Message{
-					{
-						Title: "Ansible Playbook",
-						Lines: []models.Line{
-							{
-								Content:   "Inventory file does not exist",
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-							{
-								Content:   sanitizeErrorMessage(err.Error(), password, becomePass),
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-						},
-					},
-				},
-				Status:     "error",
-				FinishedAt: time.Now(),
-			}, request.Platform)
+	var galaxyEnv map[string]string
+	if params.RequirementsSource != SourceNone {
+		if params.RequirementsSource == SourceInline {
+			params.Requirements, err = ws.WriteFile("requirements.yml", params.RequirementsContent)
 			if err != nil {
+				return fail("Failed to write requirements", err)
+			}
+		}
+
+		content, err := os.ReadFile(params.Requirements)
+		if err != nil {
+			return fail("Failed to read requirements", err)
+		}
+		dir, err := galaxyDir(content, params.RequirementsCache, ws)
+		if err != nil {
+			return fail("Failed to determine the requirements directory", err)
+		}
+
+		// the install output is shown under its own title
+		galaxyOutput := sdk.NewLineSink(reporter, "Ansible Galaxy", sdk.LineSinkConfig{})
+		_ = galaxyOutput.Add("Installing requirements into "+dir, "")
+
+		galaxyEnv, err = installRequirements(ctx, params.Requirements, content, dir, params.RequirementsCache, &CustomWriter{
+			OutputFunc: func(line string, color string) {
+				_ = galaxyOutput.Add(strings.TrimSuffix(line, "\n"), color)
+			},
+		})
+		if err == nil && galaxyEnv == nil {
+			_ = galaxyOutput.Add("The requirements list no roles or collections, nothing to install", "")
+		}
+		_ = galaxyOutput.Close()
+
+		if err != nil && ctx.Err() != nil {
+			if err := reporter.Cancelled(ctx); err != nil {
 				return plugins.Response{
 					Success: false,
 				}, err
 			}
+
+			return plugins.Response{Success: false, Canceled: true}, nil
+		}
+		if err != nil {
+			return fail("Failed to install requirements", err)
+		}
+	}
+
+	// check if playbook file exists
+	if _, err := os.Stat(params.Playbook); errors.Is(err, os.ErrNotExist) {
+		return fail("Playbook file does not exist", err)
+	}
+
+	// if inventory is a file check and not a comma separated list check if file exists
+	if !strings.Contains(params.Inventory, ",") && net.ParseIP(params.Inventory) == nil {
+		if _, err := os.Stat(params.Inventory); errors.Is(err, os.ErrNotExist) {
+			return fail("Inventory file does not exist", err)
+		}
+	}
+
+	var extraVars map[string]interface{}
+	if strings.TrimSpace(params.ExtraVars) != "" {
+		extraVars, err = parseExtraVars(params.ExtraVars, request.Alert.Payload)
+		if err != nil {
+			return fail("Invalid extra vars", err)
+		}
+	}
+
+	sshArgs, removeKnownHosts, err := sshCommonArgs(params.HostKeyChecking, params.KnownHostsFile, params.KnownHosts)
+	if err != nil {
+		return fail("Invalid host key checking", err)
+	}
+	defer removeKnownHosts()
+
+	if params.HostKeyChecking == HostKeyInsecure {
+		err = reporter.Warn("Ansible Playbook", "Host key checking is disabled, the identity of the inventory hosts is not checked")
+		if err != nil {
 			return plugins.Response{
 				Success: false,
-			}, errors.New("inventory file does not exist")
+			}, err
 		}
 	}
 
 	var ansiblePlaybookOptions *playbook.AnsiblePlaybookOptions
-	if !authentication {
+	if !params.Authentication {
 		ansiblePlaybookOptions = &playbook.AnsiblePlaybookOptions{
 			Connection:    "ssh",
-			Inventory:     inventory,
-			Become:        become,
-			Limit:         limit,
-			Check:         check,
-			Diff:          diff,
-			SSHCommonArgs: "-o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null",
-			PrivateKey:    private_key,
+			Inventory:     params.Inventory,
+			Become:        params.Become,
+			Limit:         params.Limit,
+			Check:         params.Check,
+			Diff:          params.Diff,
+			SSHCommonArgs: sshArgs,
+			PrivateKey:    params.PrivateKey,
 		}
 	} else {
 		ansiblePlaybookOptions = &playbook.AnsiblePlaybookOptions{
 			Connection:    "ssh",
-			Inventory:     inventory,
-			Become:        become,
-			Limit:         limit,
-			Check:         check,
-			Diff:          diff,
-			User:          user,
-			BecomeUser:    becomeUser,
-			SSHCommonArgs: "-o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null",
+			Inventory:     params.Inventory,
+			Become:        params.Become,
+			Limit:         params.Limit,
+			Check:         params.Check,
+			Diff:          params.Diff,
+			User:          params.User,
+			BecomeUser:    params.BecomeUser,
+			SSHCommonArgs: sshArgs,
 			ExtraVars: map[string]interface{}{
-				"ansible_password":    password,
-				"ansible_become_pass": becomePass,
+				"ansible_password":    params.Password,
+				"ansible_become_pass": params.BecomePass,
 			},
-			PrivateKey: private_key,
+			PrivateKey: params.PrivateKey,
 		}
 	}
 
-	if verbose == 1 {
+	ansiblePlaybookOptions.Tags = strings.Join(params.Tags, ",")
+	ansiblePlaybookOptions.SkipTags = strings.Join(params.SkipTags, ",")
+	ansiblePlaybookOptions.StartAtTask = params.StartAtTask
+	if params.Forks > 0 {
+		ansiblePlaybookOptions.Forks = strconv.Itoa(params.Forks)
+	}
+
+	if len(extraVars) > 0 {
+		extraVarsFile, err := writeExtraVars(extraVars)
+		if err != nil {
+			return fail("Failed to write extra vars to temporary file", err)
+		}
+		defer os.Remove(extraVarsFile)
+
+		err = ansiblePlaybookOptions.AddExtraVarsFile(extraVarsFile)
+		if err != nil {
+			return fail("Failed to add extra vars file", err)
+		}
+	}
+
+	if params.Verbose == 1 {
 		ansiblePlaybookOptions.Verbose = true
 		ansiblePlaybookOptions.VerboseV = true
-	} else if verbose == 2 {
+	} else if params.Verbose == 2 {
 		ansiblePlaybookOptions.Verbose = true
 		ansiblePlaybookOptions.VerboseVV = true
-	} else if verbose == 3 {
+	} else if params.Verbose == 3 {
 		ansiblePlaybookOptions.Verbose = true
 		ansiblePlaybookOptions.VerboseVVV = true
-	} else if verbose == 4 {
+	} else if params.Verbose == 4 {
 		ansiblePlaybookOptions.Verbose = true
 		ansiblePlaybookOptions.VerboseVVVV = true
 	}
 
-	if vault_password_file != "" && vault_password == "" {
-		ansiblePlaybookOptions.VaultPasswordFile = vault_password_file
-	} else if vault_password != "" {
+	if params.VaultPasswordFile != "" && params.VaultPassword == "" {
+		ansiblePlaybookOptions.VaultPasswordFile = params.VaultPasswordFile
+	} else if params.VaultPassword != "" {
 		// create a temporary file with the vault password
 		tmpfile, err := os.CreateTemp("", "vault-password")
 		if err != nil {
-			err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
-				Messages: []models.Message{
-					{
-						Title: "Ansible Playbook",
-						Lines: []models.Line{
-							{
-								Content:   "Failed to create temporary file for vault password",
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-							{
-								Content:   sanitizeErrorMessage(err.Error(), password, becomePass),
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-						},
-					},
-				},
-				Status:     "error",
-				FinishedAt: time.Now(),
-			}, request.Platform)
-			if err != nil {
-				return plugins.Response{
-					Success: false,
-				}, err
-			}
-			return plugins.Response{
-				Success: false,
-			}, errors.New("failed to create temporary file for vault password")
+			return fail("Failed to create temporary file for vault password", err)
 		}
+		// removed however the step ends, the password must not stay on disk
+		defer os.Remove(tmpfile.Name())
 
 		// write the vault password to the temporary file
-		_, err = tmpfile.WriteString(vault_password)
+		_, err = tmpfile.WriteString(params.VaultPassword)
 		if err != nil {
-			err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
-				Messages: []models.Message{
-					{
-						Title: "Ansible Playbook",
-						Lines: []models.Line{
-							{
-								Content:   "Failed to write vault password to temporary file",
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-							{
-								Content:   sanitizeErrorMessage(err.Error(), password, becomePass),
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-						},
-					},
-				},
-				Status:     "error",
-				FinishedAt: time.Now(),
-			}, request.Platform)
-			if err != nil {
-				return plugins.Response{
-					Success: false,
-				}, err
-			}
-			return plugins.Response{
-				Success: false,
-			}, errors.New("failed to write vault password to temporary file")
+			_ = tmpfile.Close()
+			return fail("Failed to write vault password to temporary file", err)
 		}
 
 		// close the temporary file
 		err = tmpfile.Close()
 		if err != nil {
-			err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
-				Messages: []models.Message{
-					{
-						Title: "Ansible Playbook",
-						Lines: []models.Line{
-							{
-								Content:   "Failed to close temporary file for vault password",
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-							{
-								Content:   sanitizeErrorMessage(err.Error(), password, becomePass),
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-						},
-					},
-				},
-				Status:     "error",
-				FinishedAt: time.Now(),
-			}, request.Platform)
-			if err != nil {
-				return plugins.Response{
-					Success: false,
-				}, err
-			}
-			return plugins.Response{
-				Success: false,
-			}, errors.New("failed to close temporary file for vault password")
+			return fail("Failed to close temporary file for vault password", err)
 		}
 
 		// set the vault password file to the temporary file
@@ -479,38 +399,23 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	playbookCmd := playbook.NewAnsiblePlaybookCmd(
-		playbook.WithPlaybooks(play),
+		playbook.WithPlaybooks(params.Playbook),
 		playbook.WithPlaybookOptions(ansiblePlaybookOptions),
 	)
 
+	// Collect the playbook output and send it to the step in batches.
+	// The sink is flushed and closed when the step is finished.
+	output := sdk.NewLineSink(reporter, "Ansible Playbook", sdk.LineSinkConfig{})
+	defer output.Close()
+
+	// The PLAY RECAP is parsed from the output into per host results
+	recap := &recapParser{}
+
 	// Use a custom writer to capture output
 	customWriter := &CustomWriter{
-		OutputFunc: func(output string, color string) {
-			err := handleOutput(output, color, request)
-			if err != nil {
-				_ = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
-					Messages: []models.Message{
-						{
-							Title: "Ansible Playbook",
-							Lines: []models.Line{
-								{
-									Content:   "Ansible Playbook failed",
-									Color:     "danger",
-									Timestamp: time.Now(),
-								},
-								{
-									Content:   sanitizeErrorMessage(err.Error(), password, becomePass),
-									Color:     "danger",
-									Timestamp: time.Now(),
-								},
-							},
-						},
-					},
-					Status:     "error",
-					FinishedAt: time.Now(),
-				}, request.Platform)
-			}
+		OutputFunc: func(line string, color string) {
+			recap.Write(line)
+			_ = output.Add(strings.TrimSuffix(line, "\n"), color)
 		},
 	}
 
@@ -519,30 +424,14 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			execute.WithCmd(playbookCmd),
 			execute.WithErrorEnrich(playbook.NewAnsiblePlaybookErrorEnrich()),
 			execute.WithWrite(customWriter), // Redirect both stdout and stderr to custom writer.
+			execute.WithEnvVars(galaxyEnv),
 		),
 		configuration.WithAnsibleForceColor(),
 	)
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Cancel",
-					Lines: []models.Line{
-						{
-							Content:   "Action canceled",
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status:     "canceled",
-			FinishedAt: time.Now(),
-		}, request.Platform)
-		if err != nil {
+		if err := reporter.Cancelled(ctx); err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
@@ -552,123 +441,50 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	err = exec.Execute(ctx)
-	if err != nil {
-		err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Ansible Playbook",
-					Lines: []models.Line{
-						{
-							Content:   "Ansible Playbook failed",
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-						{
-							Content:   sanitizeErrorMessage(err.Error(), password, becomePass),
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status:     "error",
-			FinishedAt: time.Now(),
-		}, request.Platform)
-		if err != nil {
+	if err != nil && ctx.Err() != nil {
+		if err := reporter.Cancelled(ctx); err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
 		}
 
+		return plugins.Response{Success: false, Canceled: true}, nil
+	}
+
+	// publish the recap of the hosts, also when the playbook failed on some of them
+	data, updateErr := publishRecap(reporter, output, recap)
+	if updateErr != nil {
 		return plugins.Response{
 			Success: false,
-		}, err
+		}, updateErr
 	}
 
-	// Check for cancellation before each major step
-	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Cancel",
-					Lines: []models.Line{
-						{
-							Content:   "Action canceled",
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status:     "canceled",
-			FinishedAt: time.Now(),
-		}, request.Platform)
-		if err != nil {
+	if err != nil {
+		if updateErr := reporter.Finish(sdk.StatusError, "Ansible Playbook", "Ansible Playbook failed", err.Error()); updateErr != nil {
 			return plugins.Response{
 				Success: false,
-			}, err
+			}, updateErr
 		}
 
-		return plugins.Response{Success: false, Canceled: true}, nil
+		return plugins.Response{
+			Data:    data,
+			Success: false,
+		}, err
 	}
 
-	// remove the temporary file if it was created
-	if vault_password_file == "" && vault_password != "" {
-		err = os.Remove(ansiblePlaybookOptions.VaultPasswordFile)
-		if err != nil {
-			err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
-				Messages: []models.Message{
-					{
-						Title: "Ansible Playbook",
-						Lines: []models.Line{
-							{
-								Content:   "Failed to remove temporary file for vault password",
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-							{
-								Content:   sanitizeErrorMessage(err.Error(), password, becomePass),
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-						},
-					},
-				},
-				Status:     "error",
-				FinishedAt: time.Now(),
-			}, request.Platform)
-			if err != nil {
-				return plugins.Response{
-					Success: false,
-				}, err
-			}
+	// Check for cancellation before each major step
+	if ctx.Err() != nil {
+		if err := reporter.Cancelled(ctx); err != nil {
 			return plugins.Response{
 				Success: false,
-			}, errors.New("failed to remove temporary file for vault password")
+			}, err
 		}
+
+		return plugins.Response{Success: false, Canceled: true}, nil
 	}
 
 	// update the step with the messages
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
-		Messages: []models.Message{
-			{
-				Title: "Ansible Playbook",
-				Lines: []models.Line{
-					{
-						Content:   "Ansible Playbook executed successfully",
-						Color:     "success",
-						Timestamp: time.Now(),
-					},
-				},
-			},
-		},
-		Status:     "success",
-		FinishedAt: time.Now(),
-	}, request.Platform)
+	err = reporter.Finish(sdk.StatusSuccess, "Ansible Playbook", "Ansible Playbook executed successfully")
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -676,37 +492,20 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	return plugins.Response{
+		Data:    data,
 		Success: true,
 	}, nil
 }
 
 func (p *Plugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
-	stepID := request.Step.ID.String()
-	taskCancelsMu.Lock()
-	cancel, ok := taskCancels[stepID]
-	taskCancelsMu.Unlock()
-
-	if !ok {
-		return plugins.Response{
-			Success: false,
-		}, errors.New("task not found")
-	}
-
-	cancel()
-	return plugins.Response{Success: true}, nil
-}
-
-func (p *Plugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
+	return tasks.Cancel(request)
 }
 
 func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	var plugin = models.Plugin{
 		Name:    "Ansible",
 		Type:    "action",
-		Version: "1.4.5",
+		Version: "1.5.0",
 		Author:  "JustNZ",
 		Action: models.Action{
 			Name:        "Ansible",
@@ -714,194 +513,11 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 			Plugin:      "ansible",
 			Icon:        "mdi:ansible",
 			Category:    "Automation",
-			Params: []models.Params{
-				{
-					Key:         "playbook",
-					Title:       "Playbook",
-					Category:    "General",
-					Type:        "text",
-					Default:     request.Workspace + "/",
-					Required:    true,
-					Description: "Path to the playbook file",
-				},
-				{
-					Key:         "inventory",
-					Title:       "Inventory",
-					Category:    "General",
-					Type:        "text",
-					Default:     request.Workspace + "/",
-					Required:    true,
-					Description: "Path to the inventory file or comma separated host list",
-				},
-				{
-					Key:         "authentication",
-					Title:       "Authentication",
-					Category:    "Authentication",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    true,
-					Description: "Use authentication for the Ansible connection. If enabled, you must provide a authentication method.",
-				},
-				{
-					Key:         "authentication_method",
-					Title:       "Authentication Method",
-					Type:        "select",
-					Default:     "none",
-					Required:    false,
-					Description: "The authentication method to use",
-					Options: []models.Option{
-						{
-							Key:   "none",
-							Value: "None",
-						},
-						{
-							Key:   "password",
-							Value: "Password",
-						},
-						{
-							Key:   "private_key_file",
-							Value: "Private Key File",
-						},
-					},
-					Category: "Authentication",
-					DependsOn: models.DependsOn{
-						Key:   "authentication",
-						Value: "true",
-					},
-				},
-				{
-					Key:         "user",
-					Title:       "User",
-					Category:    "Authentication",
-					Type:        "text",
-					Default:     "",
-					Required:    false,
-					Description: "Connect as this user",
-					DependsOn: models.DependsOn{
-						Key:   "authentication_method",
-						Value: "password",
-					},
-				},
-				{
-					Key:         "password",
-					Title:       "Password",
-					Category:    "Authentication",
-					Type:        "password",
-					Default:     "",
-					Required:    false,
-					Description: "Connection user password",
-					DependsOn: models.DependsOn{
-						Key:   "authentication_method",
-						Value: "password",
-					},
-				},
-				{
-					Key:         "private_key",
-					Title:       "Private Key",
-					Category:    "Authentication",
-					Type:        "text",
-					Default:     "",
-					Required:    false,
-					Description: "Path to the private key file",
-					DependsOn: models.DependsOn{
-						Key:   "authentication_method",
-						Value: "private_key_file",
-					},
-				},
-				{
-					Key:         "limit",
-					Title:       "Limit",
-					Category:    "General",
-					Type:        "text",
-					Default:     "",
-					Required:    false,
-					Description: "Further limit selected hosts to an additional pattern",
-				},
-				{
-					Key:         "become",
-					Title:       "Become",
-					Category:    "Sudo",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    true,
-					Description: "Run playbook with become. Requires authentication to be enabled",
-					DependsOn: models.DependsOn{
-						Key:   "authentication",
-						Value: "true",
-					},
-				},
-				{
-					Key:         "become_user",
-					Title:       "Become User",
-					Category:    "Sudo",
-					Type:        "text",
-					Default:     "root",
-					Required:    false,
-					Description: "User to run become tasks with",
-					DependsOn: models.DependsOn{
-						Key:   "become",
-						Value: "true",
-					},
-				},
-				{
-					Key:         "become_pass",
-					Title:       "Become Password",
-					Category:    "Sudo",
-					Type:        "password",
-					Default:     "",
-					Required:    false,
-					Description: "Become user password",
-					DependsOn: models.DependsOn{
-						Key:   "become",
-						Value: "true",
-					},
-				},
-				{
-					Key:         "vault_password_file",
-					Title:       "Vault Password File",
-					Category:    "Vault",
-					Type:        "text",
-					Default:     request.Workspace + "/",
-					Required:    false,
-					Description: "Path to Vault Password File",
-				},
-				{
-					Key:         "vault_password",
-					Title:       "Vault Password",
-					Category:    "Vault",
-					Type:        "password",
-					Default:     "",
-					Required:    false,
-					Description: "Vault Password. This will override the vault_password_file",
-				},
-				{
-					Key:         "check",
-					Title:       "Check",
-					Category:    "Utility",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    false,
-					Description: "Don't make any changes; instead, try to predict some of the changes that may occur",
-				},
-				{
-					Key:         "diff",
-					Title:       "Diff",
-					Category:    "Utility",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    false,
-					Description: "When changing (small) files and templates, show the differences in those files",
-				},
-				{
-					Key:         "verbose",
-					Title:       "Verbose",
-					Category:    "Utility",
-					Type:        "number",
-					Default:     "0",
-					Required:    false,
-					Description: "Set the verbosity level. Default is 0",
-				},
-			},
+			Params: append(sdk.ParamSchema(Params{
+				Playbook:          request.Workspace + "/",
+				Inventory:         request.Workspace + "/",
+				VaultPasswordFile: request.Workspace + "/",
+			}), sdk.TimeoutParam()),
 		},
 		Endpoint: models.Endpoint{},
 	}
@@ -909,58 +525,6 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	return plugin, nil
 }
 
-// PluginRPCServer is the RPC server for Plugin
-type PluginRPCServer struct {
-	Impl plugins.Plugin
-}
-
-func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.ExecuteTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.CancelTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
-	result, err := s.Impl.EndpointRequest(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *models.Plugin) error {
-	result, err := s.Impl.Info(request)
-	*resp = result
-	return err
-}
-
-// PluginServer is the implementation of plugin.Plugin interface
-type PluginServer struct {
-	Impl plugins.Plugin
-}
-
-func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
-}
-
-func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &plugins.PluginRPC{Client: c}, nil
-}
-
 func main() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: plugin.HandshakeConfig{
-			ProtocolVersion:  1,
-			MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
-			MagicCookieValue: "hello",
-		},
-		Plugins: map[string]plugin.Plugin{
-			"plugin": &PluginServer{Impl: &Plugin{}},
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
-	})
+	sdk.Serve(&Plugin{})
 }
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/v1Flows/runner-plugins/sdk"
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+// Output is published in Response.Data, see sdk.Outputs for the key layout.
+// The counts are the totals of all hosts of the PLAY RECAP, FailedHosts and
+// ChangedHosts list the hosts separated by commas.
+type Output struct {
+	Hosts        []HostRecap `json:"hosts"`
+	Ok           int         `json:"ok"`
+	Changed      int         `json:"changed"`
+	Unreachable  int         `json:"unreachable"`
+	Failed       int         `json:"failed"`
+	Skipped      int         `json:"skipped"`
+	Rescued      int         `json:"rescued"`
+	Ignored      int         `json:"ignored"`
+	FailedHosts  string      `json:"failed_hosts"`
+	ChangedHosts string      `json:"changed_hosts"`
+}
+
+// HostRecap is the PLAY RECAP line of a single host
+type HostRecap struct {
+	Host        string `json:"host"`
+	Ok          int    `json:"ok"`
+	Changed     int    `json:"changed"`
+	Unreachable int    `json:"unreachable"`
+	Failed      int    `json:"failed"`
+	Skipped     int    `json:"skipped"`
+	Rescued     int    `json:"rescued"`
+	Ignored     int    `json:"ignored"`
+}
+
+// recapLine matches a host line of the PLAY RECAP like
+// "web1 : ok=2 changed=1 unreachable=0 failed=0 skipped=0 rescued=0 ignored=0"
+var recapLine = regexp.MustCompile(`^(\S+)\s+:\s+((?:\w+=\d+\s*)+)$`)
+
+// recapParser collects the PLAY RECAP from the playbook output. The output
+// arrives in chunks that do not necessarily end at a line break.
+type recapParser struct {
+	mu      sync.Mutex
+	partial string
+	inRecap bool
+	hosts   []HostRecap
+}
+
+func (r *recapParser) Write(output string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lines := strings.Split(r.partial+output, "\n")
+	r.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		r.parseLine(line)
+	}
+}
+
+func (r *recapParser) parseLine(line string) {
+	// the writer strips colors per chunk, an escape code split across two
+	// chunks is only complete here
+	line = strings.TrimSpace(ansiRegexp.ReplaceAllString(line, ""))
+	if strings.HasPrefix(line, "PLAY RECAP") {
+		// every playbook run prints a single recap, start over if there are more
+		r.inRecap = true
+		r.hosts = nil
+		return
+	}
+	if !r.inRecap {
+		return
+	}
+
+	match := recapLine.FindStringSubmatch(line)
+	if match == nil {
+		if line != "" {
+			r.inRecap = false
+		}
+		return
+	}
+
+	host := HostRecap{Host: match[1]}
+	counts := map[string]*int{
+		"ok":          &host.Ok,
+		"changed":     &host.Changed,
+		"unreachable": &host.Unreachable,
+		"failed":      &host.Failed,
+		"skipped":     &host.Skipped,
+		"rescued":     &host.Rescued,
+		"ignored":     &host.Ignored,
+	}
+	for _, field := range strings.Fields(match[2]) {
+		key, value, _ := strings.Cut(field, "=")
+		if count, ok := counts[key]; ok {
+			*count, _ = strconv.Atoi(value)
+		}
+	}
+
+	r.hosts = append(r.hosts, host)
+}
+
+// Output returns the recap of all hosts, nil if the playbook printed none
+func (r *recapParser) Output() *Output {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.partial != "" {
+		r.parseLine(r.partial)
+		r.partial = ""
+	}
+	if len(r.hosts) == 0 {
+		return nil
+	}
+
+	out := &Output{Hosts: r.hosts}
+	var failed, changed []string
+	for _, host := range r.hosts {
+		out.Ok += host.Ok
+		out.Changed += host.Changed
+		out.Unreachable += host.Unreachable
+		out.Failed += host.Failed
+		out.Skipped += host.Skipped
+		out.Rescued += host.Rescued
+		out.Ignored += host.Ignored
+
+		if host.Failed > 0 || host.Unreachable > 0 {
+			failed = append(failed, host.Host)
+		}
+		if host.Changed > 0 {
+			changed = append(changed, host.Host)
+		}
+	}
+	out.FailedHosts = strings.Join(failed, ",")
+	out.ChangedHosts = strings.Join(changed, ",")
+
+	return out
+}
+
+// recapLines formats the recap as a table, a row per host colored by its
+// worst result
+func recapLines(out *Output) []models.Line {
+	width := len("Host")
+	for _, host := range out.Hosts {
+		width = max(width, len(host.Host))
+	}
+
+	row := func(host string, counts ...interface{}) string {
+		return fmt.Sprintf("%-*s  %11v  %11v  %11v  %11v  %11v  %11v  %11v", append([]interface{}{width, host}, counts...)...)
+	}
+
+	lines := []models.Line{
+		{Content: row("Host", "ok", "changed", "unreachable", "failed", "skipped", "rescued", "ignored")},
+	}
+	for _, host := range out.Hosts {
+		line := models.Line{
+			Content: row(host.Host, host.Ok, host.Changed, host.Unreachable, host.Failed, host.Skipped, host.Rescued, host.Ignored),
+			Color:   sdk.ColorSuccess,
+		}
+		switch {
+		case host.Failed > 0 || host.Unreachable > 0:
+			line.Color = sdk.ColorDanger
+		case host.Changed > 0:
+			line.Color = sdk.ColorWarning
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// publishRecap adds the recap table to the step after the playbook output and
+// returns the recap as outputs. It does nothing if the playbook printed no recap.
+func publishRecap(reporter *sdk.StepReporter, output *sdk.LineSink, recap *recapParser) (map[string]interface{}, error) {
+	out := recap.Output()
+	if out == nil {
+		return nil, nil
+	}
+
+	if err := output.Flush(); err != nil {
+		return nil, err
+	}
+	if err := reporter.Message("Ansible Recap", recapLines(out)...); err != nil {
+		return nil, err
+	}
+
+	return reporter.Outputs(out)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/v1Flows/runner-plugins/sdk"
+)
+
+func TestRecapParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   *Output
+	}{
+		{
+			name:   "no recap",
+			chunks: []string{"PLAY [all] ***\n", "TASK [ping] ***\n", "fatal: [web1]: UNREACHABLE!\n"},
+			want:   nil,
+		},
+		{
+			name:   "recap without hosts",
+			chunks: []string{"PLAY RECAP ***\n", "\n"},
+			want:   nil,
+		},
+		{
+			name: "all counts",
+			chunks: []string{
+				"PLAY RECAP *********\n",
+				"web1                       : ok=3    changed=1    unreachable=0    failed=0    skipped=2    rescued=1    ignored=4   \n",
+				"web2                       : ok=1    changed=0    unreachable=1    failed=0    skipped=0    rescued=0    ignored=0   \n",
+				"\n",
+			},
+			want: &Output{
+				Hosts: []HostRecap{
+					{Host: "web1", Ok: 3, Changed: 1, Skipped: 2, Rescued: 1, Ignored: 4},
+					{Host: "web2", Ok: 1, Unreachable: 1},
+				},
+				Ok: 4, Changed: 1, Unreachable: 1, Skipped: 2, Rescued: 1, Ignored: 4,
+				FailedHosts: "web2", ChangedHosts: "web1",
+			},
+		},
+		{
+			name: "ansible before rescued and ignored",
+			chunks: []string{
+				"PLAY RECAP\n",
+				"db1 : ok=2 changed=2 unreachable=0 failed=1 skipped=0\n",
+			},
+			want: &Output{
+				Hosts:       []HostRecap{{Host: "db1", Ok: 2, Changed: 2, Failed: 1}},
+				Ok:          2,
+				Changed:     2,
+				Failed:      1,
+				FailedHosts: "db1", ChangedHosts: "db1",
+			},
+		},
+		{
+			name: "colors",
+			chunks: []string{
+				"\x1b[0;33mPLAY RECAP\x1b[0m *****\n",
+				"\x1b[0;33mweb1\x1b[0m                       : \x1b[0;32mok=2   \x1b[0m \x1b[0;33mchanged=1   \x1b[0m unreachable=0    failed=0    skipped=0    rescued=0    \x1b[0;35mignored=1   \x1b[0m\n",
+			},
+			want: &Output{
+				Hosts:        []HostRecap{{Host: "web1", Ok: 2, Changed: 1, Ignored: 1}},
+				Ok:           2,
+				Changed:      1,
+				Ignored:      1,
+				ChangedHosts: "web1",
+			},
+		},
+		{
+			name: "chunks split lines and escape codes",
+			chunks: []string{
+				"PLAY RE", "CAP ***\nweb1 : ok=1 cha", "nged=0 failed=0 rescued=2\n\x1b[0;3",
+				"1mweb2\x1b[0m : ok=0 failed=1",
+			},
+			want: &Output{
+				Hosts:       []HostRecap{{Host: "web1", Ok: 1, Rescued: 2}, {Host: "web2", Failed: 1}},
+				Ok:          1,
+				Failed:      1,
+				Rescued:     2,
+				FailedHosts: "web2",
+			},
+		},
+		{
+			name: "later recap replaces earlier one",
+			chunks: []string{
+				"PLAY RECAP\nweb1 : ok=1\n\n",
+				"PLAY RECAP\nweb2 : ok=5\n",
+			},
+			want: &Output{Hosts: []HostRecap{{Host: "web2", Ok: 5}}, Ok: 5},
+		},
+		{
+			name: "recap ends at other output",
+			chunks: []string{
+				"PLAY RECAP\nweb1 : ok=1\n",
+				"Playbook run took 0 days, 0 hours\n",
+				"web2 : ok=5\n",
+			},
+			want: &Output{Hosts: []HostRecap{{Host: "web1", Ok: 1}}, Ok: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recap := &recapParser{}
+			for _, chunk := range tt.chunks {
+				recap.Write(chunk)
+			}
+			if got := recap.Output(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Output() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecapLines(t *testing.T) {
+	out := &Output{Hosts: []HostRecap{
+		{Host: "web1.example.com", Ok: 2},
+		{Host: "web2", Ok: 2, Changed: 1},
+		{Host: "web3", Unreachable: 1},
+		{Host: "web4", Changed: 1, Failed: 1},
+	}}
+
+	lines := recapLines(out)
+	if len(lines) != 5 {
+		t.Fatalf("recapLines() returned %d lines, want 5", len(lines))
+	}
+	if !strings.HasPrefix(lines[0].Content, "Host            ") || !strings.HasSuffix(lines[0].Content, "ignored") {
+		t.Errorf("header = %q", lines[0].Content)
+	}
+
+	wantColors := []string{"", sdk.ColorSuccess, sdk.ColorWarning, sdk.ColorDanger, sdk.ColorDanger}
+	for i, line := range lines {
+		if len(line.Content) != len(lines[0].Content) {
+			t.Errorf("line %d = %q, not aligned with the header", i, line.Content)
+		}
+		if line.Color != wantColors[i] {
+			t.Errorf("line %d color = %q, want %q", i, line.Color, wantColors[i])
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+)
+
+// Sources of the playbook_source and inventory_source params
+const (
+	SourceFile    = "file"
+	SourceInline  = "inline"
+	SourcePayload = "payload"
+)
+
+// workspace is a private temporary directory holding the files the plugin
+// generates for a single run. Remove deletes it with all its content.
+type workspace struct {
+	dir string
+}
+
+func newWorkspace() (*workspace, error) {
+	dir, err := os.MkdirTemp("", "ansible-workspace-")
+	if err != nil {
+		return nil, err
+	}
+	return &workspace{dir: dir}, nil
+}
+
+// WriteFile writes content to name inside the workspace, only readable by the
+// runner user, and returns its path
+func (w *workspace) WriteFile(name string, content string) (string, error) {
+	path := filepath.Join(w.dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (w *workspace) Remove() error {
+	return os.RemoveAll(w.dir)
+}
+
+// inventoryFileName returns the file name for inline inventory content. The
+// YAML inventory plugin of ansible only reads files with a YAML extension,
+// everything that is no YAML mapping is left to the INI plugin.
+func inventoryFileName(content string) string {
+	var mapping map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &mapping); err == nil && len(mapping) > 0 {
+		return "inventory.yml"
+	}
+	return "inventory.ini"
+}
+
+// payloadInventory generates a YAML inventory with a single group from the
+// values of keys in the alert payload. keys are gjson paths like
+// labels.instance, or alerts.#.labels.instance for every alert of a group.
+// With stripPort the port of host:port values is removed, e.g. of the
+// instance label of Prometheus alerts, which holds the port of the exporter.
+func payloadInventory(payload []byte, keys []string, group string, stripPort bool) (string, []string, error) {
+	if len(payload) == 0 {
+		return "", nil, fmt.Errorf("the execution has no alert payload")
+	}
+
+	var hosts []string
+	seen := make(map[string]bool)
+	add := func(value gjson.Result) {
+		host := strings.TrimSpace(value.String())
+		if stripPort {
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+		}
+		if host != "" && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	for _, key := range keys {
+		result := gjson.GetBytes(payload, key)
+		if !result.Exists() {
+			return "", nil, fmt.Errorf("payload.%s does not exist in the alert payload", key)
+		}
+		if result.IsArray() {
+			result.ForEach(func(_, value gjson.Result) bool {
+				add(value)
+				return true
+			})
+			continue
+		}
+		add(result)
+	}
+
+	if len(hosts) == 0 {
+		return "", nil, fmt.Errorf("the alert payload has no hosts at %s", strings.Join(keys, ", "))
+	}
+
+	groupHosts := make(map[string]interface{}, len(hosts))
+	for _, host := range hosts {
+		groupHosts[host] = nil
+	}
+	inventory := map[string]interface{}{
+		"all": map[string]interface{}{
+			"children": map[string]interface{}{
+				group: map[string]interface{}{
+					"hosts": groupHosts,
+				},
+			},
+		},
+	}
+
+	content, err := yaml.Marshal(inventory)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return string(content), hosts, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestPayloadInventory(t *testing.T) {
+	payload := []byte(`{
+		"labels": {"instance": "web1:9100", "host": "db1", "ipv6": "[2001:db8::1]:9100", "empty": " "},
+		"alerts": [
+			{"labels": {"instance": "web1:9100"}},
+			{"labels": {"instance": "web2:9100"}},
+			{"labels": {"instance": "web3"}}
+		]
+	}`)
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		keys      []string
+		stripPort bool
+		want      []string
+		wantErr   string
+	}{
+		{name: "single label", payload: payload, keys: []string{"labels.host"}, want: []string{"db1"}},
+		{name: "port is kept", payload: payload, keys: []string{"labels.instance"}, want: []string{"web1:9100"}},
+		{name: "port is stripped", payload: payload, keys: []string{"labels.instance"}, stripPort: true, want: []string{"web1"}},
+		{name: "ipv6", payload: payload, keys: []string{"labels.ipv6"}, stripPort: true, want: []string{"2001:db8::1"}},
+		{name: "every alert of a group", payload: payload, keys: []string{"alerts.#.labels.instance"}, stripPort: true, want: []string{"web1", "web2", "web3"}},
+		{name: "several keys without duplicates", payload: payload, keys: []string{"labels.instance", "alerts.#.labels.instance", "labels.host"}, stripPort: true, want: []string{"web1", "web2", "web3", "db1"}},
+		{name: "missing key", payload: payload, keys: []string{"labels.missing"}, wantErr: "payload.labels.missing does not exist"},
+		{name: "only empty values", payload: payload, keys: []string{"labels.empty"}, wantErr: "the alert payload has no hosts at labels.empty"},
+		{name: "no payload", keys: []string{"labels.host"}, wantErr: "the execution has no alert payload"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, hosts, err := payloadInventory(tt.payload, tt.keys, "alerting", tt.stripPort)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("payloadInventory() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("payloadInventory() error = %v", err)
+			}
+			if !reflect.DeepEqual(hosts, tt.want) {
+				t.Errorf("payloadInventory() hosts = %q, want %q", hosts, tt.want)
+			}
+
+			var inventory struct {
+				All struct {
+					Children map[string]struct {
+						Hosts map[string]interface{} `yaml:"hosts"`
+					} `yaml:"children"`
+				} `yaml:"all"`
+			}
+			if err := yaml.Unmarshal([]byte(content), &inventory); err != nil {
+				t.Fatalf("inventory is not YAML: %v\n%s", err, content)
+			}
+			var inventoryHosts []string
+			for host := range inventory.All.Children["alerting"].Hosts {
+				inventoryHosts = append(inventoryHosts, host)
+			}
+			want := append([]string(nil), tt.want...)
+			sort.Strings(inventoryHosts)
+			sort.Strings(want)
+			if !reflect.DeepEqual(inventoryHosts, want) {
+				t.Errorf("inventory hosts of group alerting = %q, want %q\n%s", inventoryHosts, want, content)
+			}
+		})
+	}
+}
+
+func TestInventoryFileName(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{content: "all:\n  hosts:\n    web1:\n", want: "inventory.yml"},
+		{content: "[web]\nweb1 ansible_host=10.0.0.1\n", want: "inventory.ini"},
+		{content: "web1\nweb2\n", want: "inventory.ini"},
+		{content: "", want: "inventory.ini"},
+	}
+
+	for _, tt := range tests {
+		if got := inventoryFileName(tt.content); got != tt.want {
+			t.Errorf("inventoryFileName(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestWorkspace(t *testing.T) {
+	w, err := newWorkspace()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := w.WriteFile("playbook.yml", "- hosts: all\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode = %v, want 0600", info.Mode().Perm())
+	}
+	dir, err := os.Stat(w.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir.Mode().Perm()&0o077 != 0 {
+		t.Errorf("workspace mode = %v, want private", dir.Mode().Perm())
+	}
+
+	if err := w.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(w.dir); !os.IsNotExist(err) {
+		t.Errorf("workspace still exists after Remove: %v", err)
+	}
+}
@@ -1,24 +1,19 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/rpc"
 	"strconv"
-	"sync"
 	"time"
 
+	"github.com/v1Flows/runner-plugins/sdk"
 	"github.com/v1Flows/runner/pkg/alerts"
-	"github.com/v1Flows/runner/pkg/executions"
 	"github.com/v1Flows/runner/pkg/flows"
 	"github.com/v1Flows/runner/pkg/plugins"
 
 	af_models "github.com/v1Flows/alertFlow/services/backend/pkg/models"
 	"github.com/v1Flows/shared-library/pkg/models"
-
-	"github.com/hashicorp/go-plugin"
 )
 
 type Receiver struct {
@@ -29,27 +24,42 @@ type IncomingFlow struct {
 	Flow models.Flows `json:"flow"`
 }
 
-var (
-	taskCancels   = make(map[string]context.CancelFunc)
-	taskCancelsMu sync.Mutex
-)
+var tasks = sdk.NewTaskRegistry()
+
+// Params are the action params of the collect data plugin. Args of the
+// request take precedence over them.
+type Params struct {
+	LogData bool   `param:"LogData" default:"false" category:"General" description:"Show collected data in the output messages"`
+	FlowID  string `param:"FlowID,required" default:"00000000-0000-0000-0000-00000000" category:"General" description:"The Flow ID to collect data from"`
+	AlertID string `param:"AlertID" default:"00000000-0000-0000-0000-00000000" category:"General" description:"The Alert ID to collect data from. Required for AlertFlow platform"`
+}
+
+// Output is published in Response.Data, see sdk.Outputs for the key layout.
+// Collected lists the collected data separated by commas, "flow" or "flow,alert".
+type Output struct {
+	Collected   string `json:"collected"`
+	FlowID      string `json:"flow_id"`
+	FlowName    string `json:"flow_name"`
+	AlertID     string `json:"alert_id,omitempty"`
+	AlertName   string `json:"alert_name,omitempty"`
+	AlertStatus string `json:"alert_status,omitempty"`
+}
 
 // Plugin is an implementation of the Plugin interface
-type Plugin struct{}
+type Plugin struct {
+	sdk.ActionPlugin
+}
 
 func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	stepID := request.Step.ID.String()
-
-	// Store cancel func
-	taskCancelsMu.Lock()
-	taskCancels[stepID] = cancel
-	taskCancelsMu.Unlock()
-	defer func() {
-		taskCancelsMu.Lock()
-		delete(taskCancels, stepID)
-		taskCancelsMu.Unlock()
-	}()
+	ctx, done, err := tasks.Register(request)
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+	defer done()
+
+	reporter := sdk.NewStepReporter(request)
 
 	flowID := ""
 	alertID := ""
@@ -68,22 +78,29 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	if request.Step.Action.Params != nil && flowID == "" && alertID == "" {
-		for _, param := range request.Step.Action.Params {
-			if param.Key == "LogData" {
-				logData, _ = strconv.ParseBool(param.Value)
-			}
-			if param.Key == "FlowID" {
-				flowID = param.Value
-			}
-			if param.Key == "AlertID" {
-				alertID = param.Value
-			}
+		info, err := p.Info(plugins.InfoRequest{Config: request.Config, Workspace: request.Workspace})
+		if err != nil {
+			return plugins.Response{
+				Success: false,
+			}, err
 		}
+
+		var params Params
+		err = reporter.DecodeParams(info.Action.Params, &params)
+		if err != nil {
+			_ = reporter.InvalidParams(err)
+			return plugins.Response{
+				Success: false,
+			}, err
+		}
+
+		logData = params.LogData
+		flowID = params.FlowID
+		alertID = params.AlertID
 	}
 
 	if flowID == "" || (request.Platform == "alertflow" && alertID == "") {
-		_ = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		_ = reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Collecting Data",
@@ -98,15 +115,14 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "error",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 
 		return plugins.Response{
 			Success: false,
 		}, errors.New("flowid and alertid are required")
 	}
 
-	err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Collecting Data",
@@ -120,7 +136,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		},
 		Status:    "running",
 		StartedAt: time.Now(),
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -129,14 +145,13 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err := reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Collecting Data",
 					Lines: []models.Line{
 						{
-							Content:   "Action canceled",
+							Content:   sdk.CancelReason(ctx),
 							Color:     "danger",
 							Timestamp: time.Now(),
 						},
@@ -145,7 +160,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "canceled",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -158,8 +173,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	// Get Flow Data
 	flowBytes, err := flows.GetFlowData(request.Config, flowID, request.Platform)
 	if err != nil && flowBytes == nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err := reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Collecting Data",
@@ -179,7 +193,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "error",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -194,8 +208,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	flow := IncomingFlow{}
 	err = json.Unmarshal(flowBytes, &flow)
 	if err != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err := reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Collecting Data",
@@ -215,7 +228,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "error",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -223,8 +236,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 	}
 
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Collecting Data",
@@ -236,7 +248,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 				},
 			},
 		},
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -247,14 +259,13 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	if request.Platform == "alertflow" && alertID != "" {
 		// Check for cancellation before each major step
 		if ctx.Err() != nil {
-			err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
+			err := reporter.Update(models.ExecutionSteps{
 				Messages: []models.Message{
 					{
 						Title: "Collecting Data",
 						Lines: []models.Line{
 							{
-								Content:   "Action canceled",
+								Content:   sdk.CancelReason(ctx),
 								Color:     "danger",
 								Timestamp: time.Now(),
 							},
@@ -263,7 +274,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 				},
 				Status:     "canceled",
 				FinishedAt: time.Now(),
-			}, request.Platform)
+			})
 			if err != nil {
 				return plugins.Response{
 					Success: false,
@@ -276,8 +287,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		// Get Alert Data
 		alert, err = alerts.GetData(request.Config, alertID)
 		if err != nil {
-			err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
+			err := reporter.Update(models.ExecutionSteps{
 				Messages: []models.Message{
 					{
 						Title: "Collecting Data",
@@ -297,7 +307,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 				},
 				Status:     "error",
 				FinishedAt: time.Now(),
-			}, request.Platform)
+			})
 			if err != nil {
 				return plugins.Response{
 					Success: false,
@@ -309,8 +319,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			}, err
 		}
 
-		err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err = reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Collecting Data",
@@ -323,7 +332,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 					},
 				},
 			},
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -331,6 +340,24 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 	}
 
+	out := Output{
+		Collected: "flow",
+		FlowID:    flowID,
+		FlowName:  flow.Flow.Name,
+	}
+	if request.Platform == "alertflow" && alertID != "" {
+		out.Collected = "flow,alert"
+		out.AlertID = alertID
+		out.AlertName = alert.Name
+		out.AlertStatus = alert.Status
+	}
+	data, err := reporter.Outputs(out)
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
 	finalMessages := []models.Line{}
 
 	if logData {
@@ -365,8 +392,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		})
 	}
 
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Collecting Data",
@@ -375,7 +401,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		},
 		Status:     "success",
 		FinishedAt: time.Now(),
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -383,6 +409,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	return plugins.Response{
+		Data:      data,
 		Flow:      &flow.Flow,
 		FlowBytes: flowBytes,
 		Alert:     &alert,
@@ -391,32 +418,14 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 }
 
 func (p *Plugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
-	stepID := request.Step.ID.String()
-	taskCancelsMu.Lock()
-	cancel, ok := taskCancels[stepID]
-	taskCancelsMu.Unlock()
-
-	if !ok {
-		return plugins.Response{
-			Success: false,
-		}, errors.New("task not found")
-	}
-
-	cancel()
-	return plugins.Response{Success: true}, nil
-}
-
-func (p *Plugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
+	return tasks.Cancel(request)
 }
 
 func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	var plugin = models.Plugin{
 		Name:    "Collect Data",
 		Type:    "action",
-		Version: "1.3.5",
+		Version: "1.4.0",
 		Author:  "JustNZ",
 		Action: models.Action{
 			Name:        "Collect Data",
@@ -424,32 +433,7 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 			Plugin:      "collect_data",
 			Icon:        "hugeicons:package-receive",
 			Category:    "Data",
-			Params: []models.Params{
-				{
-					Key:         "LogData",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    false,
-					Description: "Show collected data in the output messages",
-					Category:    "General",
-				},
-				{
-					Key:         "FlowID",
-					Type:        "text",
-					Default:     "00000000-0000-0000-0000-00000000",
-					Required:    true,
-					Description: "The Flow ID to collect data from",
-					Category:    "General",
-				},
-				{
-					Key:         "AlertID",
-					Type:        "text",
-					Default:     "00000000-0000-0000-0000-00000000",
-					Required:    false,
-					Description: "The Alert ID to collect data from. Required for AlertFlow platform",
-					Category:    "General",
-				},
-			},
+			Params:      append(sdk.ParamSchema(Params{}), sdk.TimeoutParam()),
 		},
 		Endpoint: models.Endpoint{},
 	}
@@ -457,58 +441,6 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	return plugin, nil
 }
 
-// PluginRPCServer is the RPC server for Plugin
-type PluginRPCServer struct {
-	Impl plugins.Plugin
-}
-
-func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.ExecuteTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.CancelTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
-	result, err := s.Impl.EndpointRequest(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *models.Plugin) error {
-	result, err := s.Impl.Info(request)
-	*resp = result
-	return err
-}
-
-// PluginServer is the implementation of plugin.Plugin interface
-type PluginServer struct {
-	Impl plugins.Plugin
-}
-
-func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
-}
-
-func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &plugins.PluginRPC{Client: c}, nil
-}
-
 func main() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: plugin.HandshakeConfig{
-			ProtocolVersion:  1,
-			MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
-			MagicCookieValue: "hello",
-		},
-		Plugins: map[string]plugin.Plugin{
-			"plugin": &PluginServer{Impl: &Plugin{}},
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
-	})
+	sdk.Serve(&Plugin{})
 }
@@ -3,9 +3,8 @@ module github.com/v1Flows/runner-plugins/action-plugins/debug
 go 1.24.0
 
 require (
-	github.com/hashicorp/go-plugin v1.6.3
-	github.com/v1Flows/alertFlow/services/backend v0.0.0-20250317112742-7a11f04dd445
-	github.com/v1Flows/runner v1.2.4
+	github.com/v1Flows/runner v1.3.0
+	github.com/v1Flows/runner-plugins/sdk v0.0.0
 	github.com/v1Flows/shared-library v1.0.25
 )
 
@@ -17,6 +16,7 @@ require (
 	github.com/golang/protobuf v1.5.4 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/hashicorp/go-hclog v1.6.3 // indirect
+	github.com/hashicorp/go-plugin v1.6.3 // indirect
 	github.com/hashicorp/yamux v0.1.1 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/mattn/go-colorable v0.1.14 // indirect
@@ -34,6 +34,7 @@ require (
 	github.com/subosito/gotenv v1.6.0 // indirect
 	github.com/tmthrgd/go-hex v0.0.0-20190904060850-447a3041c3bc // indirect
 	github.com/uptrace/bun v1.2.11 // indirect
+	github.com/v1Flows/alertFlow/services/backend v0.0.0-20250317112742-7a11f04dd445 // indirect
 	github.com/v1Flows/exFlow/services/backend v0.0.0-20250729085929-95657664575c // indirect
 	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
@@ -48,3 +49,5 @@ require (
 	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/v1Flows/runner-plugins/sdk => ../../sdk
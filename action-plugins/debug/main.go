@@ -1,37 +1,49 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
-	"errors"
-	"net/rpc"
-	"strconv"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/v1Flows/runner/pkg/executions"
+	"github.com/v1Flows/runner-plugins/sdk"
 	"github.com/v1Flows/runner/pkg/plugins"
 
 	"github.com/v1Flows/shared-library/pkg/models"
-
-	"github.com/hashicorp/go-plugin"
 )
 
 type Receiver struct {
 	Receiver string `json:"receiver"`
 }
 
-var (
-	taskCancels   = make(map[string]context.CancelFunc)
-	taskCancelsMu sync.Mutex
-)
+var tasks = sdk.NewTaskRegistry()
+
+// Params are the action params of the debug plugin
+type Params struct {
+	ShowSensitiveInformations bool `param:"show_sensitive_informations,required" title:"Show Sensitive Informations" default:"false" category:"General" description:"This will show sensitive information like password params in the output messages. !CAUTION: This can leak sensitive information."`
+	Flow                      bool `param:"flow" title:"Flow" default:"true" category:"General" description:"Show flow data in the output messages. !CAUTION: This can leak sensitive information."`
+	Execution                 bool `param:"execution" title:"Execution" default:"false" category:"General" description:"Show execution data in the output messages. !CAUTION: This can leak sensitive information."`
+	Step                      bool `param:"step" default:"false" category:"General" description:"Show step data in the output messages. !CAUTION: This can leak sensitive information."`
+	Platform                  bool `param:"platform" title:"Platform" default:"false" category:"General" description:"Show platform data in the output messages"`
+	Workspace                 bool `param:"workspace" title:"Workspace" default:"false" category:"General" description:"Show workspace data in the output messages"`
+	Alert                     bool `param:"alert" title:"Alert" default:"false" category:"General" description:"Show alert data in the output messages. Only available for AlertFlow platform."`
+}
+
+// Output is published in Response.Data, see sdk.Outputs for the key layout.
+// Sections lists the shown sections separated by commas, e.g. "flow,step".
+type Output struct {
+	Sections  string `json:"sections"`
+	Platform  string `json:"platform"`
+	Workspace string `json:"workspace"`
+	Lines     int    `json:"lines"`
+}
 
 // Plugin is an implementation of the Plugin interface
-type Plugin struct{}
+type Plugin struct {
+	sdk.ActionPlugin
+}
 
 // Helper function to add JSON lines with preserved indentation
-func addJSONLines(showSensitiveInformations bool, finalMessages *[]models.Line, data interface{}, title string) error {
+func addJSONLines(finalMessages *[]models.Line, data interface{}, title string) error {
 	// add separator
 	*finalMessages = append(*finalMessages, models.Line{
 		Content:   "",
@@ -47,16 +59,8 @@ func addJSONLines(showSensitiveInformations bool, finalMessages *[]models.Line,
 		Timestamp: time.Now(),
 	})
 
-	// Redact password values before marshaling
-	var redactedData interface{}
-	if !showSensitiveInformations {
-		redactedData = redactPasswords(data)
-	} else {
-		redactedData = data
-	}
-
 	// Marshal JSON with indentation
-	jsonData, err := json.MarshalIndent(redactedData, "", "  ")
+	jsonData, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		return err
 	}
@@ -73,127 +77,56 @@ func addJSONLines(showSensitiveInformations bool, finalMessages *[]models.Line,
 	return nil
 }
 
-// Helper function to redact password values from data
-func redactPasswords(data interface{}) interface{} {
-	// Convert to JSON and back to get a generic map structure
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return data
+// flowSecrets returns the password params of every action of flow. The
+// reporter only knows the params of this step, but the flow data shows all.
+func flowSecrets(flow models.Flows) []string {
+	var secrets []string
+	for _, action := range flow.Actions {
+		secrets = append(secrets, sdk.SecretParams(action.Params, nil)...)
+	}
+	for _, pipeline := range flow.FailurePipelines {
+		for _, action := range pipeline.Actions {
+			secrets = append(secrets, sdk.SecretParams(action.Params, nil)...)
+		}
 	}
+	return secrets
+}
 
-	var genericData interface{}
-	if err := json.Unmarshal(jsonData, &genericData); err != nil {
-		return data
+func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
+	ctx, done, err := tasks.Register(request)
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
 	}
+	defer done()
 
-	// Recursively process the generic data
-	return redactPasswordsInMap(genericData)
-}
+	reporter := sdk.NewStepReporter(request)
 
-// Helper function to recursively redact passwords in map structures
-func redactPasswordsInMap(data interface{}) interface{} {
-	switch v := data.(type) {
-	case map[string]interface{}:
-		result := make(map[string]interface{})
-		for key, value := range v {
-			if key == "params" {
-				// Handle params array
-				if paramsArray, ok := value.([]interface{}); ok {
-					newParams := make([]interface{}, len(paramsArray))
-					for i, param := range paramsArray {
-						if paramMap, ok := param.(map[string]interface{}); ok {
-							newParam := make(map[string]interface{})
-							for paramKey, paramValue := range paramMap {
-								if paramKey == "value" && getParamType(paramMap) == "password" {
-									newParam[paramKey] = "[REDACTED]"
-								} else {
-									newParam[paramKey] = redactPasswordsInMap(paramValue)
-								}
-							}
-							newParams[i] = newParam
-						} else {
-							newParams[i] = redactPasswordsInMap(param)
-						}
-					}
-					result[key] = newParams
-				} else {
-					result[key] = redactPasswordsInMap(value)
-				}
-			} else {
-				result[key] = redactPasswordsInMap(value)
-			}
-		}
-		return result
-	case []interface{}:
-		result := make([]interface{}, len(v))
-		for i, item := range v {
-			result[i] = redactPasswordsInMap(item)
-		}
-		return result
-	default:
-		return data
+	info, err := p.Info(plugins.InfoRequest{Config: request.Config, Workspace: request.Workspace})
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
 	}
-}
 
-// Helper function to get the type of a parameter
-func getParamType(paramMap map[string]interface{}) string {
-	if typeValue, ok := paramMap["type"]; ok {
-		if typeStr, ok := typeValue.(string); ok {
-			return typeStr
-		}
+	var params Params
+	err = reporter.DecodeParams(info.Action.Params, &params)
+	if err != nil {
+		_ = reporter.InvalidParams(err)
+		return plugins.Response{
+			Success: false,
+		}, err
 	}
-	return ""
-}
 
-func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	stepID := request.Step.ID.String()
-
-	// Store cancel func
-	taskCancelsMu.Lock()
-	taskCancels[stepID] = cancel
-	taskCancelsMu.Unlock()
-	defer func() {
-		taskCancelsMu.Lock()
-		delete(taskCancels, stepID)
-		taskCancelsMu.Unlock()
-	}()
-
-	show_sensitive_informations := false
-	flow := false
-	execution := false
-	step := false
-	platform := false
-	workspace := false
-	alert := false
-
-	// access action params
-	for _, param := range request.Step.Action.Params {
-		if param.Key == "show_sensitive_informations" {
-			show_sensitive_informations, _ = strconv.ParseBool(param.Value)
-		}
-		if param.Key == "flow" {
-			flow, _ = strconv.ParseBool(param.Value)
-		}
-		if param.Key == "execution" {
-			execution, _ = strconv.ParseBool(param.Value)
-		}
-		if param.Key == "step" {
-			step, _ = strconv.ParseBool(param.Value)
-		}
-		if param.Key == "platform" {
-			platform, _ = strconv.ParseBool(param.Value)
-		}
-		if param.Key == "workspace" {
-			workspace, _ = strconv.ParseBool(param.Value)
-		}
-		if param.Key == "alert" {
-			alert, _ = strconv.ParseBool(param.Value)
-		}
+	// the user explicitly asked to see the secrets
+	if params.ShowSensitiveInformations {
+		reporter.Redactor = nil
+	} else {
+		reporter.Redact(flowSecrets(request.Flow)...)
 	}
 
-	err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Debugging",
@@ -207,7 +140,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		},
 		Status:    "running",
 		StartedAt: time.Now(),
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -216,14 +149,13 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err := reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Debugging",
 					Lines: []models.Line{
 						{
-							Content:   "Action canceled",
+							Content:   sdk.CancelReason(ctx),
 							Color:     "danger",
 							Timestamp: time.Now(),
 						},
@@ -232,7 +164,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "canceled",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -243,9 +175,11 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	finalMessages := []models.Line{}
+	var sections []string
 
-	if flow {
-		err := addJSONLines(show_sensitive_informations, &finalMessages, request.Flow, "Flow")
+	if params.Flow {
+		sections = append(sections, "flow")
+		err := addJSONLines(&finalMessages, request.Flow, "Flow")
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -253,8 +187,9 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 	}
 
-	if execution {
-		err := addJSONLines(show_sensitive_informations, &finalMessages, request.Execution, "Execution")
+	if params.Execution {
+		sections = append(sections, "execution")
+		err := addJSONLines(&finalMessages, request.Execution, "Execution")
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -262,8 +197,9 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 	}
 
-	if step {
-		err := addJSONLines(show_sensitive_informations, &finalMessages, request.Step, "Step")
+	if params.Step {
+		sections = append(sections, "step")
+		err := addJSONLines(&finalMessages, request.Step, "Step")
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -271,7 +207,9 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 	}
 
-	if platform {
+	if params.Platform {
+		sections = append(sections, "platform")
+
 		// add separator
 		finalMessages = append(finalMessages, models.Line{
 			Content:   "-------------------- Platform --------------------",
@@ -284,7 +222,9 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		})
 	}
 
-	if workspace {
+	if params.Workspace {
+		sections = append(sections, "workspace")
+
 		// add separator
 		finalMessages = append(finalMessages, models.Line{
 			Content:   "-------------------- Workspace --------------------",
@@ -297,8 +237,9 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		})
 	}
 
-	if alert && request.Platform == "AlertFlow" {
-		err := addJSONLines(show_sensitive_informations, &finalMessages, request.Alert, "Alert")
+	if params.Alert && request.Platform == "AlertFlow" {
+		sections = append(sections, "alert")
+		err := addJSONLines(&finalMessages, request.Alert, "Alert")
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -306,9 +247,20 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 	}
 
+	data, err := reporter.Outputs(Output{
+		Sections:  strings.Join(sections, ","),
+		Platform:  request.Platform,
+		Workspace: request.Workspace,
+		Lines:     len(finalMessages),
+	})
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
 	// Update the step with the final messages
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Debugging",
@@ -327,7 +279,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		},
 		Status:     "success",
 		FinishedAt: time.Now(),
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -335,37 +287,20 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	return plugins.Response{
+		Data:    data,
 		Success: true,
 	}, nil
 }
 
 func (p *Plugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
-	stepID := request.Step.ID.String()
-	taskCancelsMu.Lock()
-	cancel, ok := taskCancels[stepID]
-	taskCancelsMu.Unlock()
-
-	if !ok {
-		return plugins.Response{
-			Success: false,
-		}, errors.New("task not found")
-	}
-
-	cancel()
-	return plugins.Response{Success: true}, nil
-}
-
-func (p *Plugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
+	return tasks.Cancel(request)
 }
 
 func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	var plugin = models.Plugin{
 		Name:    "Debug",
 		Type:    "action",
-		Version: "1.0.0",
+		Version: "1.1.0",
 		Author:  "JustNZ",
 		Action: models.Action{
 			Name:        "Debug",
@@ -373,70 +308,7 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 			Plugin:      "debug",
 			Icon:        "hugeicons:bug-02",
 			Category:    "Debug",
-			Params: []models.Params{
-				{
-					Key:         "show_sensitive_informations",
-					Title:       "Show Sensitive Informations",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    true,
-					Description: "This will show sensitive information like password params in the output messages. !CAUTION: This can leak sensitive information.",
-					Category:    "General",
-				},
-				{
-					Key:         "flow",
-					Title:       "Flow",
-					Type:        "boolean",
-					Default:     "true",
-					Required:    false,
-					Description: "Show flow data in the output messages. !CAUTION: This can leak sensitive information.",
-					Category:    "General",
-				},
-				{
-					Key:         "execution",
-					Title:       "Execution",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    false,
-					Description: "Show execution data in the output messages. !CAUTION: This can leak sensitive information.",
-					Category:    "General",
-				},
-				{
-					Key:         "step",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    false,
-					Description: "Show step data in the output messages. !CAUTION: This can leak sensitive information.",
-					Category:    "General",
-				},
-				{
-					Key:         "platform",
-					Title:       "Platform",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    false,
-					Description: "Show platform data in the output messages",
-					Category:    "General",
-				},
-				{
-					Key:         "workspace",
-					Title:       "Workspace",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    false,
-					Description: "Show workspace data in the output messages",
-					Category:    "General",
-				},
-				{
-					Key:         "alert",
-					Title:       "Alert",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    false,
-					Description: "Show alert data in the output messages. Only available for AlertFlow platform.",
-					Category:    "General",
-				},
-			},
+			Params:      append(sdk.ParamSchema(Params{}), sdk.TimeoutParam()),
 		},
 		Endpoint: models.Endpoint{},
 	}
@@ -444,58 +316,6 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	return plugin, nil
 }
 
-// PluginRPCServer is the RPC server for Plugin
-type PluginRPCServer struct {
-	Impl plugins.Plugin
-}
-
-func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.ExecuteTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.CancelTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
-	result, err := s.Impl.EndpointRequest(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *models.Plugin) error {
-	result, err := s.Impl.Info(request)
-	*resp = result
-	return err
-}
-
-// PluginServer is the implementation of plugin.Plugin interface
-type PluginServer struct {
-	Impl plugins.Plugin
-}
-
-func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
-}
-
-func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &plugins.PluginRPC{Client: c}, nil
-}
-
 func main() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: plugin.HandshakeConfig{
-			ProtocolVersion:  1,
-			MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
-			MagicCookieValue: "hello",
-		},
-		Plugins: map[string]plugin.Plugin{
-			"plugin": &PluginServer{Impl: &Plugin{}},
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
-	})
+	sdk.Serve(&Plugin{})
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/v1Flows/runner-plugins/sdk/plugintest"
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+func TestParams(t *testing.T) {
+	plugintest.CheckInfo(t, &Plugin{}, Params{})
+}
+
+func TestFlowPasswordsAreRedacted(t *testing.T) {
+	flow := models.Flows{
+		Name: "deploy",
+		Actions: []models.Action{
+			{Name: "ssh", Params: []models.Params{
+				{Key: "user", Value: "root"},
+				{Key: "password", Type: "password", Value: "ssh-secret"},
+			}},
+		},
+		FailurePipelines: []models.FailurePipeline{
+			{Actions: []models.Action{
+				{Name: "mail", Params: []models.Params{{Key: "Password", Type: "password", Value: "mail-secret"}}},
+			}},
+		},
+	}
+
+	tests := []struct {
+		show  string
+		shown bool
+	}{
+		{show: "false", shown: false},
+		{show: "true", shown: true},
+	}
+
+	for _, tt := range tests {
+		t.Run("show_sensitive_informations="+tt.show, func(t *testing.T) {
+			h := plugintest.New(&Plugin{})
+			defer h.Close()
+
+			request := h.Request(map[string]string{"show_sensitive_informations": tt.show})
+			request.Flow = flow
+			run := h.Start(request)
+			if _, err := run.Wait(); err != nil {
+				t.Fatal(err)
+			}
+
+			output := strings.Join(run.Lines("Debugging"), "\n")
+			if !strings.Contains(output, `"value": "root"`) {
+				t.Fatalf("flow is not shown:\n%s", output)
+			}
+			for _, secret := range []string{"ssh-secret", "mail-secret"} {
+				if strings.Contains(output, secret) != tt.shown {
+					t.Errorf("%s shown = %v, want %v", secret, !tt.shown, tt.shown)
+				}
+			}
+		})
+	}
+}
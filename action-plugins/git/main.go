@@ -1,14 +1,11 @@
 package main
 
 import (
-	"context"
 	"errors"
-	"net/rpc"
 	"os"
-	"sync"
 	"time"
 
-	"github.com/v1Flows/runner/pkg/executions"
+	"github.com/v1Flows/runner-plugins/sdk"
 	"github.com/v1Flows/runner/pkg/plugins"
 	"github.com/v1Flows/shared-library/pkg/models"
 
@@ -16,90 +13,94 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
-	"github.com/hashicorp/go-plugin"
 )
 
 // Plugin is an implementation of the Plugin interface
-type Plugin struct{}
+type Plugin struct {
+	sdk.ActionPlugin
+}
 
-var (
-	taskCancels   = make(map[string]context.CancelFunc)
-	taskCancelsMu sync.Mutex
-)
+var tasks = sdk.NewTaskRegistry()
+
+// Params are the action params of the git plugin
+type Params struct {
+	URL                  string `param:"url,required" title:"URL" category:"Repository" description:"URL of the repository to clone"`
+	RemoteName           string `param:"remote_name,required" title:"Remote Name" default:"origin" category:"Repository" description:"Name of the remote to clone"`
+	Branch               string `param:"branch,required" title:"Branch" default:"main" category:"Repository" description:"Branch to clone"`
+	Directory            string `param:"directory,required" title:"Directory" category:"Repository" description:"Path to clone the repository to"`
+	Authentication       bool   `param:"authentication,required" title:"Authentication" default:"false" category:"Authentication" description:"Enable authentication for the repository"`
+	AuthenticationMethod string `param:"authentication_method" title:"Authentication Method" type:"select" options:"password=Password,token=Token,private_key=Private Key" depends:"authentication=true" category:"Authentication" description:"The authentication method to use"`
+	Username             string `param:"username" title:"Username" depends:"authentication_method=password" category:"Authentication" description:"Username for authentication"`
+	Password             string `param:"password" title:"Password" type:"password" depends:"authentication_method=password" category:"Authentication" description:"Password for authentication"`
+	Token                string `param:"token" title:"Token" type:"password" depends:"authentication_method=token" category:"Authentication" description:"Token for authentication. If provided, username and password will be ignored"`
+	PrivateKey           string `param:"private_key" title:"Private Key" depends:"authentication_method=private_key" category:"Authentication" description:"Private key for authentication"`
+	PrivateKeyPassphrase string `param:"private_key_passphrase" title:"Private Key Passphrase" type:"password" depends:"authentication_method=private_key" category:"Authentication" description:"Passphrase for the private key"`
+}
+
+// Output is published in Response.Data, see sdk.Outputs for the key layout
+type Output struct {
+	URL       string `json:"url"`
+	Directory string `json:"directory"`
+	Reference string `json:"reference"`
+	Commit    string `json:"commit"`
+}
+
+// resolveHead returns the output for the checked out HEAD of the clone in directory
+func resolveHead(params Params) (Output, error) {
+	repo, err := git.PlainOpen(params.Directory)
+	if err != nil {
+		return Output{}, err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return Output{}, err
+	}
+
+	return Output{
+		URL:       params.URL,
+		Directory: params.Directory,
+		Reference: head.Name().String(),
+		Commit:    head.Hash().String(),
+	}, nil
+}
 
 func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	stepID := request.Step.ID.String()
+	ctx, done, err := tasks.Register(request)
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+	defer done()
 
-	// Store cancel func
-	taskCancelsMu.Lock()
-	taskCancels[stepID] = cancel
-	taskCancelsMu.Unlock()
-	defer func() {
-		taskCancelsMu.Lock()
-		delete(taskCancels, stepID)
-		taskCancelsMu.Unlock()
-	}()
+	reporter := sdk.NewStepReporter(request)
 
-	url := ""
-	remoteName := ""
-	branch := ""
-	directory := ""
-	username := ""
-	authentication := false
-	password := ""
-	token := ""
-	privateKey := ""
-	privateKeyPassphrase := ""
+	info, err := p.Info(plugins.InfoRequest{Config: request.Config, Workspace: request.Workspace})
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
 
-	// access action params
-	for _, param := range request.Step.Action.Params {
-		if param.Key == "url" {
-			url = param.Value
-		}
-		if param.Key == "remote_name" {
-			remoteName = param.Value
-		}
-		if param.Key == "branch" {
-			branch = param.Value
-		}
-		if param.Key == "directory" {
-			directory = param.Value
-		}
-		if param.Key == "username" {
-			username = param.Value
-		}
-		if param.Key == "authentication" {
-			if param.Value == "true" {
-				authentication = true
-			} else {
-				authentication = false
-			}
-		}
-		if param.Key == "password" {
-			password = param.Value
-		}
-		if param.Key == "token" {
-			token = param.Value
-		}
-		if param.Key == "private_key" {
-			privateKey = param.Value
-		}
-		if param.Key == "private_key_passphrase" {
-			privateKeyPassphrase = param.Value
-		}
+	var params Params
+	err = reporter.DecodeParams(info.Action.Params, &params)
+	if err != nil {
+		_ = reporter.InvalidParams(err)
+		return plugins.Response{
+			Success: false,
+		}, err
 	}
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err := reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Cancel",
 					Lines: []models.Line{
 						{
-							Content:   "Action canceled",
+							Content:   sdk.CancelReason(ctx),
 							Color:     "danger",
 							Timestamp: time.Now(),
 						},
@@ -108,7 +109,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "canceled",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -119,14 +120,13 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	// update the step with the messages
-	err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Git",
 				Lines: []models.Line{
 					{
-						Content:   "Cloning repository " + url + " to " + directory,
+						Content:   "Cloning repository " + params.URL + " to " + params.Directory,
 						Timestamp: time.Now(),
 					},
 				},
@@ -134,23 +134,26 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		},
 		Status:    "running",
 		StartedAt: time.Now(),
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
 		}, err
 	}
 
-	if !authentication {
-		_, err = git.PlainClone(directory, false, &git.CloneOptions{
-			URL:           url,
+	if !params.Authentication {
+		_, err = git.PlainCloneContext(ctx, params.Directory, false, &git.CloneOptions{
+			URL:           params.URL,
 			Progress:      os.Stdout,
-			RemoteName:    remoteName,
-			ReferenceName: plumbing.ReferenceName(branch),
+			RemoteName:    params.RemoteName,
+			ReferenceName: plumbing.ReferenceName(params.Branch),
 		})
 		if err != nil {
-			err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
+			if ctx.Err() != nil {
+				_ = reporter.Cancelled(ctx)
+				return plugins.Response{Success: false, Canceled: true}, nil
+			}
+			err := reporter.Update(models.ExecutionSteps{
 				Messages: []models.Message{
 					{
 						Title: "Git",
@@ -170,7 +173,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 				},
 				Status:     "error",
 				FinishedAt: time.Now(),
-			}, request.Platform)
+			})
 			if err != nil {
 				return plugins.Response{
 					Success: false,
@@ -182,31 +185,34 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 	} else {
 
-		if privateKey == "" {
+		if params.PrivateKey == "" {
 			// clone the repository with basic auth (username and password or token)
-			_, err = git.PlainClone(directory, false, &git.CloneOptions{
+			_, err = git.PlainCloneContext(ctx, params.Directory, false, &git.CloneOptions{
 				Auth: &http.BasicAuth{
 					Username: func() string {
-						if token != "" {
+						if params.Token != "" {
 							return "abc123"
 						}
-						return username
+						return params.Username
 					}(),
 					Password: func() string {
-						if token != "" {
-							return token
+						if params.Token != "" {
+							return params.Token
 						}
-						return password
+						return params.Password
 					}(),
 				},
-				URL:           url,
+				URL:           params.URL,
 				Progress:      os.Stdout,
-				RemoteName:    remoteName,
-				ReferenceName: plumbing.ReferenceName(branch),
+				RemoteName:    params.RemoteName,
+				ReferenceName: plumbing.ReferenceName(params.Branch),
 			})
 			if err != nil {
-				err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
+				if ctx.Err() != nil {
+					_ = reporter.Cancelled(ctx)
+					return plugins.Response{Success: false, Canceled: true}, nil
+				}
+				err := reporter.Update(models.ExecutionSteps{
 					Messages: []models.Message{
 						{
 							Title: "Git",
@@ -226,7 +232,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 					},
 					Status:     "error",
 					FinishedAt: time.Now(),
-				}, request.Platform)
+				})
 				if err != nil {
 					return plugins.Response{
 						Success: false,
@@ -240,9 +246,8 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			// clone the repository with ssh key
 
 			// check if private key file exists
-			if _, err := os.Stat(privateKey); os.IsNotExist(err) {
-				err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
+			if _, err := os.Stat(params.PrivateKey); os.IsNotExist(err) {
+				err := reporter.Update(models.ExecutionSteps{
 					Messages: []models.Message{
 						{
 							Title: "Git",
@@ -262,7 +267,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 					},
 					Status:     "error",
 					FinishedAt: time.Now(),
-				}, request.Platform)
+				})
 				if err != nil {
 					return plugins.Response{
 						Success: false,
@@ -273,10 +278,9 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 				}, errors.New("private key file does not exist")
 			}
 
-			publicKeys, err := ssh.NewPublicKeysFromFile("git", privateKey, privateKeyPassphrase)
+			publicKeys, err := ssh.NewPublicKeysFromFile("git", params.PrivateKey, params.PrivateKeyPassphrase)
 			if err != nil {
-				err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
+				err := reporter.Update(models.ExecutionSteps{
 					Messages: []models.Message{
 						{
 							Title: "Git",
@@ -296,7 +300,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 					},
 					Status:     "error",
 					FinishedAt: time.Now(),
-				}, request.Platform)
+				})
 				if err != nil {
 					return plugins.Response{
 						Success: false,
@@ -307,16 +311,19 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 				}, err
 			}
 
-			_, err = git.PlainClone(directory, false, &git.CloneOptions{
+			_, err = git.PlainCloneContext(ctx, params.Directory, false, &git.CloneOptions{
 				Auth:          publicKeys,
-				URL:           url,
+				URL:           params.URL,
 				Progress:      os.Stdout,
-				RemoteName:    remoteName,
-				ReferenceName: plumbing.ReferenceName(branch),
+				RemoteName:    params.RemoteName,
+				ReferenceName: plumbing.ReferenceName(params.Branch),
 			})
 			if err != nil {
-				err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
+				if ctx.Err() != nil {
+					_ = reporter.Cancelled(ctx)
+					return plugins.Response{Success: false, Canceled: true}, nil
+				}
+				err := reporter.Update(models.ExecutionSteps{
 					Messages: []models.Message{
 						{
 							Title: "Git",
@@ -336,7 +343,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 					},
 					Status:     "error",
 					FinishedAt: time.Now(),
-				}, request.Platform)
+				})
 				if err != nil {
 					return plugins.Response{
 						Success: false,
@@ -349,12 +356,34 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 	}
 
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	out, err := resolveHead(params)
+	if err != nil {
+		if err := reporter.Fail("Git", err, "Failed to resolve the cloned commit"); err != nil {
+			return plugins.Response{
+				Success: false,
+			}, err
+		}
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
+	data, err := reporter.Outputs(out)
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Git",
 				Lines: []models.Line{
+					{
+						Content:   "Checked out " + out.Reference + " at " + out.Commit,
+						Timestamp: time.Now(),
+					},
 					{
 						Content:   "Repository cloned successfully",
 						Color:     "success",
@@ -365,7 +394,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		},
 		Status:     "success",
 		FinishedAt: time.Now(),
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -373,37 +402,20 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	return plugins.Response{
+		Data:    data,
 		Success: true,
 	}, nil
 }
 
 func (p *Plugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
-	stepID := request.Step.ID.String()
-	taskCancelsMu.Lock()
-	cancel, ok := taskCancels[stepID]
-	taskCancelsMu.Unlock()
-
-	if !ok {
-		return plugins.Response{
-			Success: false,
-		}, errors.New("task not found")
-	}
-
-	cancel()
-	return plugins.Response{Success: true}, nil
-}
-
-func (p *Plugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
+	return tasks.Cancel(request)
 }
 
 func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	var plugin = models.Plugin{
 		Name:    "Git",
 		Type:    "action",
-		Version: "1.3.5",
+		Version: "1.4.0",
 		Author:  "JustNZ",
 		Action: models.Action{
 			Name:        "Git",
@@ -411,145 +423,7 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 			Plugin:      "git",
 			Icon:        "mdi:git",
 			Category:    "Utility",
-			Params: []models.Params{
-				{
-					Key:         "url",
-					Title:       "URL",
-					Type:        "text",
-					Default:     "",
-					Required:    true,
-					Description: "URL of the repository to clone",
-					Category:    "Repository",
-				},
-				{
-					Key:         "remote_name",
-					Title:       "Remote Name",
-					Type:        "text",
-					Default:     "origin",
-					Required:    true,
-					Description: "Name of the remote to clone",
-					Category:    "Repository",
-				},
-				{
-					Key:         "branch",
-					Title:       "Branch",
-					Type:        "text",
-					Default:     "main",
-					Required:    true,
-					Description: "Branch to clone",
-					Category:    "Repository",
-				},
-				{
-					Key:         "directory",
-					Title:       "Directory",
-					Type:        "text",
-					Default:     request.Workspace + "/",
-					Required:    true,
-					Description: "Path to clone the repository to",
-					Category:    "Repository",
-				},
-				{
-					Key:         "authentication",
-					Title:       "Authentication",
-					Category:    "Authentication",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    true,
-					Description: "Enable authentication for the repository",
-				},
-				{
-					Key:         "authentication_method",
-					Title:       "Authentication Method",
-					Type:        "select",
-					Default:     "",
-					Required:    false,
-					Description: "The authentication method to use",
-					Options: []models.Option{
-						{
-							Key:   "password",
-							Value: "Password",
-						},
-						{
-							Key:   "token",
-							Value: "Token",
-						},
-						{
-							Key:   "private_key",
-							Value: "Private Key",
-						},
-					},
-					Category: "Authentication",
-					DependsOn: models.DependsOn{
-						Key:   "authentication",
-						Value: "true",
-					},
-				},
-				{
-					Key:         "username",
-					Title:       "Username",
-					Type:        "text",
-					Default:     "",
-					Required:    false,
-					Description: "Username for authentication",
-					Category:    "Authentication",
-					DependsOn: models.DependsOn{
-						Key:   "authentication_method",
-						Value: "password",
-					},
-				},
-				{
-					Key:         "password",
-					Title:       "Password",
-					Type:        "password",
-					Default:     "",
-					Required:    false,
-					Description: "Password for authentication",
-					Category:    "Authentication",
-					DependsOn: models.DependsOn{
-						Key:   "authentication_method",
-						Value: "password",
-					},
-				},
-				{
-					Key:         "token",
-					Title:       "Token",
-					Type:        "password",
-					Default:     "",
-					Required:    false,
-					Description: "Token for authentication. If provided, username and password will be ignored",
-					Category:    "Authentication",
-					DependsOn: models.DependsOn{
-						Key:   "authentication_method",
-						Value: "token",
-					},
-				},
-				{
-					Key:         "private_key",
-					Title:       "Private Key",
-					Type:        "text",
-					Default:     "",
-					Required:    false,
-					Description: "Private key for authentication",
-					Category:    "Authentication",
-					DependsOn: models.DependsOn{
-						Key:   "authentication_method",
-						Value: "private_key",
-					},
-				},
-				{
-					Key:         "private_key_passphrase",
-					Title:       "Private Key Passphrase",
-					Type:        "password",
-					Default:     "",
-					Required:    false,
-					Description: "Passphrase for the private key",
-					Category:    "Authentication",
-					DependsOn: models.DependsOn{
-						Key:   "authentication_method",
-						Value: "private_key",
-					},
-				},
-			},
+			Params:      append(sdk.ParamSchema(Params{Directory: request.Workspace + "/"}), sdk.TimeoutParam()),
 		},
 		Endpoint: models.Endpoint{},
 	}
@@ -557,58 +431,6 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	return plugin, nil
 }
 
-// PluginRPCServer is the RPC server for Plugin
-type PluginRPCServer struct {
-	Impl plugins.Plugin
-}
-
-func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.ExecuteTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.CancelTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
-	result, err := s.Impl.EndpointRequest(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *models.Plugin) error {
-	result, err := s.Impl.Info(request)
-	*resp = result
-	return err
-}
-
-// PluginServer is the implementation of plugin.Plugin interface
-type PluginServer struct {
-	Impl plugins.Plugin
-}
-
-func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
-}
-
-func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &plugins.PluginRPC{Client: c}, nil
-}
-
 func main() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: plugin.HandshakeConfig{
-			ProtocolVersion:  1,
-			MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
-			MagicCookieValue: "hello",
-		},
-		Plugins: map[string]plugin.Plugin{
-			"plugin": &PluginServer{Impl: &Plugin{}},
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
-	})
+	sdk.Serve(&Plugin{})
 }
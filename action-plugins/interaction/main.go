@@ -1,60 +1,64 @@
 package main
 
 import (
-	"context"
-	"errors"
-	"net/rpc"
 	"strconv"
-	"sync"
 	"time"
 
+	"github.com/v1Flows/runner-plugins/sdk"
 	"github.com/v1Flows/runner/pkg/executions"
 	"github.com/v1Flows/runner/pkg/plugins"
 
 	"github.com/v1Flows/shared-library/pkg/models"
-
-	"github.com/hashicorp/go-plugin"
 )
 
 // Plugin is an implementation of the Plugin interface
-type Plugin struct{}
+type Plugin struct {
+	sdk.ActionPlugin
+}
 
-var (
-	taskCancels   = make(map[string]context.CancelFunc)
-	taskCancelsMu sync.Mutex
-)
+var tasks = sdk.NewTaskRegistry()
+
+// Params are the action params of the interaction plugin
+type Params struct {
+	InteractionTimeout int `param:"interaction_timeout,required" formerly:"Timeout" title:"Interaction Timeout" default:"0" category:"General" description:"Continue to the next step after the specified time (in seconds). 0 to disable"`
+}
 
 func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	stepID := request.Step.ID.String()
+	ctx, done, err := tasks.Register(request)
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+	defer done()
 
-	// Store cancel func
-	taskCancelsMu.Lock()
-	taskCancels[stepID] = cancel
-	taskCancelsMu.Unlock()
-	defer func() {
-		taskCancelsMu.Lock()
-		delete(taskCancels, stepID)
-		taskCancelsMu.Unlock()
-	}()
+	reporter := sdk.NewStepReporter(request)
 
-	timeout := 0
-	for _, param := range request.Step.Action.Params {
-		if param.Key == "Timeout" {
-			timeout, _ = strconv.Atoi(param.Value)
-		}
+	info, err := p.Info(plugins.InfoRequest{Config: request.Config, Workspace: request.Workspace})
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
+	var params Params
+	err = reporter.DecodeParams(info.Action.Params, &params)
+	if err != nil {
+		_ = reporter.InvalidParams(err)
+		return plugins.Response{
+			Success: false,
+		}, err
 	}
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err := reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Cancel",
 					Lines: []models.Line{
 						{
-							Content:   "Action canceled",
+							Content:   sdk.CancelReason(ctx),
 							Color:     "danger",
 							Timestamp: time.Now(),
 						},
@@ -63,7 +67,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "canceled",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -73,8 +77,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		return plugins.Response{Success: false, Canceled: true}, nil
 	}
 
-	err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Interaction",
@@ -85,7 +88,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 						Timestamp: time.Now(),
 					},
 					{
-						Content:   "Timeout: " + strconv.Itoa(timeout) + " seconds",
+						Content:   "Interaction timeout: " + strconv.Itoa(params.InteractionTimeout) + " seconds",
 						Timestamp: time.Now(),
 					},
 				},
@@ -94,7 +97,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		Interactive: true,
 		Status:      "interactionWaiting",
 		StartedAt:   time.Now(),
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -105,26 +108,22 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 
 	var stepData models.ExecutionSteps
 
-	// pull current action status from backend every 10 seconds
+	// pull current action status from backend every 5 seconds
 	startTime := time.Now()
 	for {
 		stepData, err = executions.GetStep(request.Config, request.Execution.ID.String(), request.Step.ID.String(), request.Platform)
-
-		if stepData.Interacted {
-			break
-		} else {
-			time.Sleep(5 * time.Second)
-		}
-
 		if err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
 		}
 
-		if timeout > 0 && time.Since(startTime).Seconds() >= float64(timeout) {
-			err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
+		if stepData.Interacted {
+			break
+		}
+
+		if params.InteractionTimeout > 0 && time.Since(startTime).Seconds() >= float64(params.InteractionTimeout) {
+			err = reporter.Update(models.ExecutionSteps{
 				Messages: []models.Message{
 					{
 						Title: "Interaction",
@@ -147,7 +146,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 				Interacted:          true,
 				InteractionApproved: true,
 				InteractionRejected: false,
-			}, request.Platform)
+			})
 			if err != nil {
 				return plugins.Response{
 					Success: false,
@@ -158,13 +157,25 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			stepData.InteractionApproved = true
 			break
 		}
+
+		select {
+		case <-ctx.Done():
+			err = reporter.Cancelled(ctx)
+			if err != nil {
+				return plugins.Response{
+					Success: false,
+				}, err
+			}
+
+			return plugins.Response{Success: false, Canceled: true}, nil
+		case <-time.After(5 * time.Second):
+		}
 	}
 
 	executions.SetToRunning(request.Config, request.Execution, request.Platform)
 
 	if stepData.InteractionRejected {
-		err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err = reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Interaction",
@@ -187,7 +198,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			Interacted:          true,
 			InteractionRejected: true,
 			InteractionApproved: false,
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -195,13 +206,13 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 		return plugins.Response{
 			Data: map[string]interface{}{
-				"status": "canceled",
+				"status":   "canceled",
+				"approved": false,
 			},
 			Success: false,
 		}, nil
 	} else if stepData.InteractionApproved {
-		err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err = reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Interaction",
@@ -219,13 +230,16 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			Interacted:          true,
 			InteractionRejected: false,
 			InteractionApproved: true,
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
 		}
 		return plugins.Response{
+			Data: map[string]interface{}{
+				"approved": true,
+			},
 			Success: true,
 		}, nil
 	}
@@ -236,32 +250,14 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 }
 
 func (p *Plugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
-	stepID := request.Step.ID.String()
-	taskCancelsMu.Lock()
-	cancel, ok := taskCancels[stepID]
-	taskCancelsMu.Unlock()
-
-	if !ok {
-		return plugins.Response{
-			Success: false,
-		}, errors.New("task not found")
-	}
-
-	cancel()
-	return plugins.Response{Success: true}, nil
-}
-
-func (p *Plugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
+	return tasks.Cancel(request)
 }
 
 func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	var plugin = models.Plugin{
 		Name:    "Interaction",
 		Type:    "action",
-		Version: "1.4.3",
+		Version: "1.5.0",
 		Author:  "JustNZ",
 		Action: models.Action{
 			Name:        "Interaction",
@@ -269,16 +265,7 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 			Plugin:      "interaction",
 			Icon:        "hugeicons:waving-hand-01",
 			Category:    "Utility",
-			Params: []models.Params{
-				{
-					Key:         "Timeout",
-					Type:        "number",
-					Default:     "0",
-					Required:    true,
-					Description: "Continue to the next step after the specified time (in seconds). 0 to disable",
-					Category:    "General",
-				},
-			},
+			Params:      append(sdk.ParamSchema(Params{}), sdk.TimeoutParam()),
 		},
 		Endpoint: models.Endpoint{},
 	}
@@ -286,58 +273,6 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	return plugin, nil
 }
 
-// PluginRPCServer is the RPC server for Plugin
-type PluginRPCServer struct {
-	Impl plugins.Plugin
-}
-
-func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.ExecuteTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.CancelTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
-	result, err := s.Impl.EndpointRequest(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *models.Plugin) error {
-	result, err := s.Impl.Info(request)
-	*resp = result
-	return err
-}
-
-// PluginServer is the implementation of plugin.Plugin interface
-type PluginServer struct {
-	Impl plugins.Plugin
-}
-
-func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
-}
-
-func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &plugins.PluginRPC{Client: c}, nil
-}
-
 func main() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: plugin.HandshakeConfig{
-			ProtocolVersion:  1,
-			MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
-			MagicCookieValue: "hello",
-		},
-		Plugins: map[string]plugin.Plugin{
-			"plugin": &PluginServer{Impl: &Plugin{}},
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
-	})
+	sdk.Serve(&Plugin{})
 }
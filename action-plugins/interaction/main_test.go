@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/v1Flows/runner-plugins/sdk/plugintest"
+)
+
+func TestParams(t *testing.T) {
+	plugintest.CheckInfo(t, &Plugin{}, Params{})
+}
+
+func TestCancelWhileWaitingForInteraction(t *testing.T) {
+	h := plugintest.New(&Plugin{})
+	defer h.Close()
+
+	run := h.Start(h.Request(nil))
+
+	deadline := time.Now().Add(10 * time.Second)
+	for run.Step().Status != "interactionWaiting" {
+		if time.Now().After(deadline) {
+			t.Fatalf("step status = %q, want interactionWaiting", run.Step().Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := run.Cancel("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-run.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the step kept waiting for the interaction after it was canceled")
+	}
+
+	response, err := run.Wait()
+	if err != nil || !response.Canceled {
+		t.Errorf("ExecuteTask() = %+v, %v, want a canceled response", response, err)
+	}
+	if got, want := run.Lines("Cancel"), []string{"Action canceled by alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cancel lines = %q, want %q", got, want)
+	}
+}
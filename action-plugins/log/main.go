@@ -1,54 +1,63 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
-	"errors"
-	"net/rpc"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/tidwall/gjson"
-	"github.com/v1Flows/runner/pkg/executions"
+	"github.com/v1Flows/runner-plugins/sdk"
 	"github.com/v1Flows/runner/pkg/plugins"
 
 	"github.com/v1Flows/shared-library/pkg/models"
-
-	"github.com/hashicorp/go-plugin"
 )
 
 // Plugin is an implementation of the Plugin interface
-type Plugin struct{}
+type Plugin struct {
+	sdk.ActionPlugin
+}
 
-var (
-	taskCancels   = make(map[string]context.CancelFunc)
-	taskCancelsMu sync.Mutex
-)
+var tasks = sdk.NewTaskRegistry()
+
+// Params are the action params of the log plugin
+type Params struct {
+	AdditionalMessage string `param:"additionalMessage" title:"Additional Message" category:"General" description:"Additional message to log. If you are using Alertflow, you can access the alert payload data with payload.<key>"`
+}
+
+// Output is published in Response.Data, see sdk.Outputs for the key layout.
+// Message is the logged additional message with the payload value resolved.
+type Output struct {
+	Message string `json:"message"`
+}
 
 func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	stepID := request.Step.ID.String()
-
-	// Store cancel func
-	taskCancelsMu.Lock()
-	taskCancels[stepID] = cancel
-	taskCancelsMu.Unlock()
-	defer func() {
-		taskCancelsMu.Lock()
-		delete(taskCancels, stepID)
-		taskCancelsMu.Unlock()
-	}()
-
-	additionalMessage := ""
-
-	for _, param := range request.Step.Action.Params {
-		if param.Key == "additionalMessage" {
-			additionalMessage = param.Value
-		}
+	ctx, done, err := tasks.Register(request)
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
 	}
+	defer done()
 
-	if strings.Contains(additionalMessage, "payload.") && request.Platform == "alertflow" {
+	reporter := sdk.NewStepReporter(request)
+
+	info, err := p.Info(plugins.InfoRequest{Config: request.Config, Workspace: request.Workspace})
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
+	var params Params
+	err = reporter.DecodeParams(info.Action.Params, &params)
+	if err != nil {
+		_ = reporter.InvalidParams(err)
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
+	if strings.Contains(params.AdditionalMessage, "payload.") && request.Platform == "alertflow" {
 		// convert payload to string
 		payloadBytes, err := json.Marshal(request.Alert.Payload)
 		if err != nil {
@@ -58,19 +67,18 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 		payloadString := string(payloadBytes)
 
-		additionalMessage = gjson.Get(payloadString, strings.Replace(additionalMessage, "payload.", "", 1)).String()
+		params.AdditionalMessage = gjson.Get(payloadString, strings.Replace(params.AdditionalMessage, "payload.", "", 1)).String()
 	}
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err := reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Cancel",
 					Lines: []models.Line{
 						{
-							Content:   "Action canceled",
+							Content:   sdk.CancelReason(ctx),
 							Color:     "danger",
 							Timestamp: time.Now(),
 						},
@@ -79,7 +87,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "canceled",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -89,8 +97,14 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		return plugins.Response{Success: false, Canceled: true}, nil
 	}
 
-	err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	data, err := reporter.Outputs(Output{Message: params.AdditionalMessage})
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Log",
@@ -112,7 +126,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 						Timestamp: time.Now(),
 					},
 					{
-						Content:   additionalMessage,
+						Content:   params.AdditionalMessage,
 						Timestamp: time.Now(),
 					},
 					{
@@ -126,7 +140,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		Status:     "success",
 		StartedAt:  time.Now(),
 		FinishedAt: time.Now(),
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -134,37 +148,20 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	return plugins.Response{
+		Data:    data,
 		Success: true,
 	}, nil
 }
 
 func (p *Plugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
-	stepID := request.Step.ID.String()
-	taskCancelsMu.Lock()
-	cancel, ok := taskCancels[stepID]
-	taskCancelsMu.Unlock()
-
-	if !ok {
-		return plugins.Response{
-			Success: false,
-		}, errors.New("task not found")
-	}
-
-	cancel()
-	return plugins.Response{Success: true}, nil
-}
-
-func (p *Plugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
+	return tasks.Cancel(request)
 }
 
 func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	var plugin = models.Plugin{
 		Name:    "Log",
 		Type:    "action",
-		Version: "1.4.3",
+		Version: "1.5.0",
 		Author:  "JustNZ",
 		Action: models.Action{
 			Name:        "Log Message",
@@ -172,17 +169,7 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 			Plugin:      "log",
 			Icon:        "hugeicons:files-01",
 			Category:    "Utility",
-			Params: []models.Params{
-				{
-					Key:         "additionalMessage",
-					Title:       "Additional Message",
-					Type:        "text",
-					Default:     "",
-					Required:    false,
-					Description: "Additional message to log. If you are using Alertflow, you can access the alert payload data with payload.<key>",
-					Category:    "General",
-				},
-			},
+			Params:      append(sdk.ParamSchema(Params{}), sdk.TimeoutParam()),
 		},
 		Endpoint: models.Endpoint{},
 	}
@@ -190,58 +177,6 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	return plugin, nil
 }
 
-// PluginRPCServer is the RPC server for Plugin
-type PluginRPCServer struct {
-	Impl plugins.Plugin
-}
-
-func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.ExecuteTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.CancelTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
-	result, err := s.Impl.EndpointRequest(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *models.Plugin) error {
-	result, err := s.Impl.Info(request)
-	*resp = result
-	return err
-}
-
-// PluginServer is the implementation of plugin.Plugin interface
-type PluginServer struct {
-	Impl plugins.Plugin
-}
-
-func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
-}
-
-func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &plugins.PluginRPC{Client: c}, nil
-}
-
 func main() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: plugin.HandshakeConfig{
-			ProtocolVersion:  1,
-			MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
-			MagicCookieValue: "hello",
-		},
-		Plugins: map[string]plugin.Plugin{
-			"plugin": &PluginServer{Impl: &Plugin{}},
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
-	})
+	sdk.Serve(&Plugin{})
 }
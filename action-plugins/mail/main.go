@@ -1,83 +1,79 @@
 package main
 
 import (
-	"context"
-	"errors"
-	"net/rpc"
 	"net/smtp"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/v1Flows/runner/pkg/executions"
+	"github.com/v1Flows/runner-plugins/sdk"
 	"github.com/v1Flows/runner/pkg/plugins"
 
 	"github.com/v1Flows/shared-library/pkg/models"
-
-	"github.com/hashicorp/go-plugin"
 )
 
 // Plugin is an implementation of the Plugin interface
-type Plugin struct{}
+type Plugin struct {
+	sdk.ActionPlugin
+}
 
-var (
-	taskCancels   = make(map[string]context.CancelFunc)
-	taskCancelsMu sync.Mutex
-)
+var tasks = sdk.NewTaskRegistry()
+
+// Params are the action params of the mail plugin
+type Params struct {
+	From     string `param:"From,required" default:"from@mail.com" category:"General" description:"Sender email address"`
+	To       string `param:"To" default:"to@mail.com" category:"General" description:"Recipient email address. Multiple emails can be separated by comma"`
+	SmtpHost string `param:"SmtpHost,required" default:"smtp.mail.com" category:"SMTP" description:"SMTP server host"`
+	SmtpPort int    `param:"SmtpPort,required" default:"587" category:"SMTP" description:"SMTP server port"`
+	Password string `param:"Password" type:"password" default:"***" category:"Credentials" description:"Sender email password"`
+	Message  string `param:"Message,required" type:"textarea" default:"Email message" description:"Email message"`
+}
+
+// Output is published in Response.Data, see sdk.Outputs for the key layout
+type Output struct {
+	Recipients []string `json:"recipients"`
+	Sent       bool     `json:"sent"`
+	Error      string   `json:"error,omitempty"`
+}
 
 func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	stepID := request.Step.ID.String()
+	ctx, done, err := tasks.Register(request)
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+	defer done()
 
-	// Store cancel func
-	taskCancelsMu.Lock()
-	taskCancels[stepID] = cancel
-	taskCancelsMu.Unlock()
-	defer func() {
-		taskCancelsMu.Lock()
-		delete(taskCancels, stepID)
-		taskCancelsMu.Unlock()
-	}()
+	reporter := sdk.NewStepReporter(request)
 
-	from := ""
-	password := ""
-	to := []string{}
-	smtpHost := ""
-	smtpPort := 0
-	message := ""
+	info, err := p.Info(plugins.InfoRequest{Config: request.Config, Workspace: request.Workspace})
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
 
-	for _, param := range request.Step.Action.Params {
-		if param.Key == "From" {
-			from = param.Value
-		}
-		if param.Key == "Password" {
-			password = param.Value
-		}
-		if param.Key == "To" {
-			to = strings.Split(param.Value, ",")
-		}
-		if param.Key == "SmtpHost" {
-			smtpHost = param.Value
-		}
-		if param.Key == "SmtpPort" {
-			smtpPort, _ = strconv.Atoi(param.Value)
-		}
-		if param.Key == "Message" {
-			message = param.Value
-		}
+	var params Params
+	err = reporter.DecodeParams(info.Action.Params, &params)
+	if err != nil {
+		_ = reporter.InvalidParams(err)
+		return plugins.Response{
+			Success: false,
+		}, err
 	}
 
+	to := strings.Split(params.To, ",")
+
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err := reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Cancel",
 					Lines: []models.Line{
 						{
-							Content:   "Action canceled",
+							Content:   sdk.CancelReason(ctx),
 							Color:     "danger",
 							Timestamp: time.Now(),
 						},
@@ -86,7 +82,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "canceled",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -96,14 +92,13 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		return plugins.Response{Success: false, Canceled: true}, nil
 	}
 
-	err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Mail",
 				Lines: []models.Line{
 					{
-						Content:   `Authenticate on SMTP Server: ` + smtpHost + `:` + strconv.Itoa(smtpPort),
+						Content:   `Authenticate on SMTP Server: ` + params.SmtpHost + `:` + strconv.Itoa(params.SmtpPort),
 						Timestamp: time.Now(),
 					},
 				},
@@ -111,7 +106,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		},
 		StartedAt: time.Now(),
 		Status:    "running",
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -119,13 +114,27 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	// Create authentication
-	auth := smtp.PlainAuth("", from, password, smtpHost+":"+strconv.Itoa(smtpPort))
+	auth := smtp.PlainAuth("", params.From, params.Password, params.SmtpHost+":"+strconv.Itoa(params.SmtpPort))
+
+	out := Output{
+		Recipients: to,
+	}
 
 	// Send actual message
-	err = smtp.SendMail(smtpHost+":"+strconv.Itoa(smtpPort), auth, from, to, []byte(message))
+	err = smtp.SendMail(params.SmtpHost+":"+strconv.Itoa(params.SmtpPort), auth, params.From, to, []byte(params.Message))
+	out.Sent = err == nil
 	if err != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		out.Error = err.Error()
+	}
+	data, outErr := reporter.Outputs(out)
+	if outErr != nil {
+		return plugins.Response{
+			Success: false,
+		}, outErr
+	}
+
+	if err != nil {
+		err := reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Mail",
@@ -145,7 +154,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "error",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -153,12 +162,12 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 
 		return plugins.Response{
+			Data:    data,
 			Success: false,
 		}, nil
 	}
 
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Mail",
@@ -173,7 +182,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		},
 		Status:     "success",
 		FinishedAt: time.Now(),
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -181,37 +190,20 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	return plugins.Response{
+		Data:    data,
 		Success: true,
 	}, nil
 }
 
 func (p *Plugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
-	stepID := request.Step.ID.String()
-	taskCancelsMu.Lock()
-	cancel, ok := taskCancels[stepID]
-	taskCancelsMu.Unlock()
-
-	if !ok {
-		return plugins.Response{
-			Success: false,
-		}, errors.New("task not found")
-	}
-
-	cancel()
-	return plugins.Response{Success: true}, nil
-}
-
-func (p *Plugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
+	return tasks.Cancel(request)
 }
 
 func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	var plugin = models.Plugin{
 		Name:    "Mail",
 		Type:    "action",
-		Version: "1.4.3",
+		Version: "1.5.0",
 		Author:  "JustNZ",
 		Action: models.Action{
 			Name:        "Mail",
@@ -219,58 +211,7 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 			Plugin:      "mail",
 			Icon:        "hugeicons:mail-02",
 			Category:    "Notification",
-			Params: []models.Params{
-				{
-					Key:         "From",
-					Type:        "text",
-					Default:     "from@mail.com",
-					Required:    true,
-					Description: "Sender email address",
-					Category:    "General",
-				},
-				{
-					Key:         "To",
-					Type:        "text",
-					Default:     "to@mail.com",
-					Required:    false,
-					Description: "Recipient email address. Multiple emails can be separated by comma",
-					Category:    "General",
-				},
-				{
-					Key:         "SmtpHost",
-					Type:        "text",
-					Default:     "smtp.mail.com",
-					Required:    true,
-					Description: "SMTP server host",
-					Category:    "SMTP",
-				},
-				{
-					Key:         "SmtpPort",
-					Type:        "number",
-					Default:     "587",
-					Required:    true,
-					Description: "SMTP server port",
-					Options:     nil,
-					Category:    "SMTP",
-				},
-				{
-					Key:         "Password",
-					Type:        "password",
-					Default:     "***",
-					Required:    false,
-					Description: "Sender email password",
-					Options:     nil,
-					Category:    "Credentials",
-				},
-				{
-					Key:         "Message",
-					Type:        "textarea",
-					Default:     "Email message",
-					Required:    true,
-					Description: "Email message",
-					Options:     nil,
-				},
-			},
+			Params:      append(sdk.ParamSchema(Params{}), sdk.TimeoutParam()),
 		},
 		Endpoint: models.Endpoint{},
 	}
@@ -278,58 +219,6 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	return plugin, nil
 }
 
-// PluginRPCServer is the RPC server for Plugin
-type PluginRPCServer struct {
-	Impl plugins.Plugin
-}
-
-func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.ExecuteTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.CancelTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
-	result, err := s.Impl.EndpointRequest(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *models.Plugin) error {
-	result, err := s.Impl.Info(request)
-	*resp = result
-	return err
-}
-
-// PluginServer is the implementation of plugin.Plugin interface
-type PluginServer struct {
-	Impl plugins.Plugin
-}
-
-func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
-}
-
-func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &plugins.PluginRPC{Client: c}, nil
-}
-
 func main() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: plugin.HandshakeConfig{
-			ProtocolVersion:  1,
-			MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
-			MagicCookieValue: "hello",
-		},
-		Plugins: map[string]plugin.Plugin{
-			"plugin": &PluginServer{Impl: &Plugin{}},
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
-	})
+	sdk.Serve(&Plugin{})
 }
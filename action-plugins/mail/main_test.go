@@ -0,0 +1,11 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/v1Flows/runner-plugins/sdk/plugintest"
+)
+
+func TestParams(t *testing.T) {
+	plugintest.CheckInfo(t, &Plugin{}, Params{})
+}
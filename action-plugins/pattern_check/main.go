@@ -1,22 +1,17 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
-	"net/rpc"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/tidwall/gjson"
-	"github.com/v1Flows/runner/pkg/executions"
+	"github.com/v1Flows/runner-plugins/sdk"
 	"github.com/v1Flows/runner/pkg/plugins"
 
 	af_models "github.com/v1Flows/alertFlow/services/backend/pkg/models"
 	"github.com/v1Flows/shared-library/pkg/models"
-
-	"github.com/hashicorp/go-plugin"
 )
 
 type Receiver struct {
@@ -28,26 +23,22 @@ type IncomingFlow struct {
 }
 
 // Plugin is an implementation of the Plugin interface
-type Plugin struct{}
+type Plugin struct {
+	sdk.ActionPlugin
+}
 
-var (
-	taskCancels   = make(map[string]context.CancelFunc)
-	taskCancelsMu sync.Mutex
-)
+var tasks = sdk.NewTaskRegistry()
 
 func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	stepID := request.Step.ID.String()
+	ctx, done, err := tasks.Register(request)
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+	defer done()
 
-	// Store cancel func
-	taskCancelsMu.Lock()
-	taskCancels[stepID] = cancel
-	taskCancelsMu.Unlock()
-	defer func() {
-		taskCancelsMu.Lock()
-		delete(taskCancels, stepID)
-		taskCancelsMu.Unlock()
-	}()
+	reporter := sdk.NewStepReporter(request)
 
 	if request.Platform != "alertflow" {
 		return plugins.Response{
@@ -57,14 +48,13 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err := reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Cancel",
 					Lines: []models.Line{
 						{
-							Content:   "Action canceled",
+							Content:   sdk.CancelReason(ctx),
 							Color:     "danger",
 							Timestamp: time.Now(),
 						},
@@ -73,7 +63,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "canceled",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -83,8 +73,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		return plugins.Response{Success: false, Canceled: true}, nil
 	}
 
-	err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Pattern Check",
@@ -98,7 +87,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		},
 		Status:    "running",
 		StartedAt: time.Now(),
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{}, err
 	}
@@ -113,8 +102,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 
 	// end if there are no patterns
 	if len(flow.Flow.Patterns) == 0 {
-		err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err = reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Pattern Check",
@@ -133,7 +121,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "success",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -161,8 +149,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 
 		if pattern.Type == "equals" {
 			if value.String() == pattern.Value {
-				err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
+				err := reporter.Update(models.ExecutionSteps{
 					Messages: []models.Message{
 						{
 							Title: "Pattern Check",
@@ -180,15 +167,14 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 							},
 						},
 					},
-				}, request.Platform)
+				})
 				if err != nil {
 					return plugins.Response{
 						Success: false,
 					}, err
 				}
 			} else {
-				err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
+				err = reporter.Update(models.ExecutionSteps{
 					Messages: []models.Message{
 						{
 							Title: "Pattern Check",
@@ -203,7 +189,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 					},
 					Status:     "canceled",
 					FinishedAt: time.Now(),
-				}, request.Platform)
+				})
 				if err != nil {
 					return plugins.Response{
 						Success: false,
@@ -213,8 +199,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			}
 		} else if pattern.Type == "not_equals" {
 			if value.String() != pattern.Value {
-				err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
+				err := reporter.Update(models.ExecutionSteps{
 					Messages: []models.Message{
 						{
 							Title: "Pattern Check",
@@ -232,15 +217,14 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 							},
 						},
 					},
-				}, request.Platform)
+				})
 				if err != nil {
 					return plugins.Response{
 						Success: false,
 					}, err
 				}
 			} else {
-				err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
+				err = reporter.Update(models.ExecutionSteps{
 					Messages: []models.Message{
 						{
 							Title: "Pattern Check",
@@ -255,7 +239,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 					},
 					Status:     "canceled",
 					FinishedAt: time.Now(),
-				}, request.Platform)
+				})
 				if err != nil {
 					return plugins.Response{
 						Success: false,
@@ -265,8 +249,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			}
 		} else if pattern.Type == "contains" {
 			if !strings.Contains(value.String(), pattern.Value) {
-				err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
+				err := reporter.Update(models.ExecutionSteps{
 					Messages: []models.Message{
 						{
 							Title: "Pattern Check",
@@ -284,13 +267,12 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 							},
 						},
 					},
-				}, request.Platform)
+				})
 				if err != nil {
 					return plugins.Response{}, err
 				}
 			} else {
-				err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
+				err = reporter.Update(models.ExecutionSteps{
 					Messages: []models.Message{
 						{
 							Title: "Pattern Check",
@@ -305,7 +287,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 					},
 					Status:     "canceled",
 					FinishedAt: time.Now(),
-				}, request.Platform)
+				})
 				if err != nil {
 					return plugins.Response{
 						Success: false,
@@ -315,8 +297,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			}
 		} else if pattern.Type == "not_contains" {
 			if strings.Contains(value.String(), pattern.Value) {
-				err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
+				err := reporter.Update(models.ExecutionSteps{
 					Messages: []models.Message{
 						{
 							Title: "Pattern Check",
@@ -334,15 +315,14 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 							},
 						},
 					},
-				}, request.Platform)
+				})
 				if err != nil {
 					return plugins.Response{
 						Success: false,
 					}, err
 				}
 			} else {
-				err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
+				err = reporter.Update(models.ExecutionSteps{
 					Messages: []models.Message{
 						{
 							Title: "Pattern Check",
@@ -357,7 +337,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 					},
 					Status:     "canceled",
 					FinishedAt: time.Now(),
-				}, request.Platform)
+				})
 				if err != nil {
 					return plugins.Response{
 						Success: false,
@@ -369,8 +349,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	if patternMissMatched > 0 {
-		err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err = reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Pattern Check",
@@ -390,7 +369,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "noPatternMatch",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -403,8 +382,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			Success: false,
 		}, nil
 	} else {
-		err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err = reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Pattern Check",
@@ -424,7 +402,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "success",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -437,32 +415,14 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 }
 
 func (p *Plugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
-	stepID := request.Step.ID.String()
-	taskCancelsMu.Lock()
-	cancel, ok := taskCancels[stepID]
-	taskCancelsMu.Unlock()
-
-	if !ok {
-		return plugins.Response{
-			Success: false,
-		}, errors.New("task not found")
-	}
-
-	cancel()
-	return plugins.Response{Success: true}, nil
-}
-
-func (p *Plugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
+	return tasks.Cancel(request)
 }
 
 func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	var plugin = models.Plugin{
 		Name:    "Pattern Check",
 		Type:    "action",
-		Version: "1.4.3",
+		Version: "1.5.0",
 		Author:  "JustNZ",
 		Action: models.Action{
 			Name:        "Pattern Check",
@@ -470,7 +430,7 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 			Plugin:      "pattern_check",
 			Icon:        "solar:list-check-minimalistic-bold",
 			Category:    "Utility",
-			Params:      nil,
+			Params:      []models.Params{sdk.TimeoutParam()},
 		},
 		Endpoint: models.Endpoint{},
 	}
@@ -478,58 +438,6 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	return plugin, nil
 }
 
-// PluginRPCServer is the RPC server for Plugin
-type PluginRPCServer struct {
-	Impl plugins.Plugin
-}
-
-func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.ExecuteTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.CancelTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
-	result, err := s.Impl.EndpointRequest(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *models.Plugin) error {
-	result, err := s.Impl.Info(request)
-	*resp = result
-	return err
-}
-
-// PluginServer is the implementation of plugin.Plugin interface
-type PluginServer struct {
-	Impl plugins.Plugin
-}
-
-func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
-}
-
-func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &plugins.PluginRPC{Client: c}, nil
-}
-
 func main() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: plugin.HandshakeConfig{
-			ProtocolVersion:  1,
-			MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
-			MagicCookieValue: "hello",
-		},
-		Plugins: map[string]plugin.Plugin{
-			"plugin": &PluginServer{Impl: &Plugin{}},
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
-	})
+	sdk.Serve(&Plugin{})
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	af_models "github.com/v1Flows/alertFlow/services/backend/pkg/models"
+	"github.com/v1Flows/runner-plugins/sdk"
+	"github.com/v1Flows/runner-plugins/sdk/plugintest"
+)
+
+func TestParams(t *testing.T) {
+	plugintest.CheckInfo(t, &Plugin{}, struct{}{})
+}
+
+func TestPatterns(t *testing.T) {
+	payload := json.RawMessage(`{"status":"firing","labels":{"severity":"critical","instance":"web1:9100"}}`)
+
+	tests := []struct {
+		name     string
+		patterns []af_models.Pattern
+		success  bool
+		status   string
+	}{
+		{
+			name:    "no patterns",
+			success: true,
+			status:  sdk.StatusSuccess,
+		},
+		{
+			name:     "equals matches",
+			patterns: []af_models.Pattern{{Key: "labels.severity", Type: "equals", Value: "critical"}},
+			success:  true,
+			status:   sdk.StatusSuccess,
+		},
+		{
+			name:     "equals does not match",
+			patterns: []af_models.Pattern{{Key: "labels.severity", Type: "equals", Value: "warning"}},
+			status:   "noPatternMatch",
+		},
+		{
+			name:     "not equals matches",
+			patterns: []af_models.Pattern{{Key: "labels.severity", Type: "not_equals", Value: "warning"}},
+			success:  true,
+			status:   sdk.StatusSuccess,
+		},
+		{
+			name:     "not equals does not match",
+			patterns: []af_models.Pattern{{Key: "status", Type: "not_equals", Value: "firing"}},
+			status:   "noPatternMatch",
+		},
+		{
+			name:     "missing key does not equal",
+			patterns: []af_models.Pattern{{Key: "labels.team", Type: "equals", Value: "ops"}},
+			status:   "noPatternMatch",
+		},
+		{
+			name: "every pattern must match",
+			patterns: []af_models.Pattern{
+				{Key: "labels.severity", Type: "equals", Value: "critical"},
+				{Key: "status", Type: "equals", Value: "resolved"},
+			},
+			status: "noPatternMatch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := plugintest.New(&Plugin{})
+			defer h.Close()
+			h.Platform = "alertflow"
+
+			flow, err := json.Marshal(IncomingFlow{Flow: af_models.Flows{Patterns: tt.patterns}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request := h.Request(nil)
+			request.FlowBytes = flow
+			request.Alert = af_models.Alerts{Payload: payload}
+
+			response, err := h.Execute(request)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if response.Success != tt.success {
+				t.Errorf("Success = %t, want %t", response.Success, tt.success)
+			}
+
+			step, _ := h.Backend.Step(request.Step.ID.String())
+			if step.Status != tt.status {
+				t.Errorf("step status = %q, want %q", step.Status, tt.status)
+			}
+			if !tt.success && response.Data["status"] != "noPatternMatch" {
+				t.Errorf("Data = %v, want status noPatternMatch", response.Data)
+			}
+		})
+	}
+}
+
+func TestPatternsRequireAlertflow(t *testing.T) {
+	h := plugintest.New(&Plugin{})
+	defer h.Close()
+
+	if _, err := h.Execute(h.Request(nil)); err == nil {
+		t.Error("Execute() on exflow succeeded")
+	}
+}
@@ -3,68 +3,84 @@ package main
 import (
 	"context"
 	"errors"
-	"net/rpc"
 	"strconv"
-	"sync"
 	"time"
 
 	probing "github.com/prometheus-community/pro-bing"
-	"github.com/v1Flows/runner/pkg/executions"
+	"github.com/v1Flows/runner-plugins/sdk"
 	"github.com/v1Flows/runner/pkg/plugins"
 
 	"github.com/v1Flows/shared-library/pkg/models"
-
-	"github.com/hashicorp/go-plugin"
 )
 
 // Plugin is an implementation of the Plugin interface
-type Plugin struct{}
+type Plugin struct {
+	sdk.ActionPlugin
+}
 
-var (
-	taskCancels   = make(map[string]context.CancelFunc)
-	taskCancelsMu sync.Mutex
-)
+var tasks = sdk.NewTaskRegistry()
+
+// Params are the action params of the ping plugin
+type Params struct {
+	Target          string `param:"target,required" title:"Target" default:"www.alertflow.org" category:"General" description:"The target to ping"`
+	Count           int    `param:"count" title:"Count" default:"3" category:"General" description:"Number of packages to send"`
+	MaxLostPackages int    `param:"maxLostPackages" title:"Max Lost Packages" default:"0" category:"General" description:"Max lost packages to consider the ping failed"`
+}
+
+// Output is published in Response.Data, see sdk.Outputs for the key layout.
+// Round-trip times are in milliseconds.
+type Output struct {
+	Target          string  `json:"target"`
+	Addr            string  `json:"addr"`
+	PacketsSent     int     `json:"packets_sent"`
+	PacketsReceived int     `json:"packets_received"`
+	PacketLoss      float64 `json:"packet_loss"`
+	RttMin          float64 `json:"rtt_min_ms"`
+	RttMax          float64 `json:"rtt_max_ms"`
+	RttAvg          float64 `json:"rtt_avg_ms"`
+	RttStdDev       float64 `json:"rtt_stddev_ms"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
 
 func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	stepID := request.Step.ID.String()
+	ctx, done, err := tasks.Register(request)
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+	defer done()
 
-	// Store cancel func
-	taskCancelsMu.Lock()
-	taskCancels[stepID] = cancel
-	taskCancelsMu.Unlock()
-	defer func() {
-		taskCancelsMu.Lock()
-		delete(taskCancels, stepID)
-		taskCancelsMu.Unlock()
-	}()
+	reporter := sdk.NewStepReporter(request)
 
-	target := "www.alertflow.org"
-	count := 3
-	maxLostPackages := 0
+	info, err := p.Info(plugins.InfoRequest{Config: request.Config, Workspace: request.Workspace})
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
 
-	for _, param := range request.Step.Action.Params {
-		if param.Key == "target" {
-			target = param.Value
-		}
-		if param.Key == "count" {
-			count, _ = strconv.Atoi(param.Value)
-		}
-		if param.Key == "maxLostPackages" {
-			maxLostPackages, _ = strconv.Atoi(param.Value)
-		}
+	var params Params
+	err = reporter.DecodeParams(info.Action.Params, &params)
+	if err != nil {
+		_ = reporter.InvalidParams(err)
+		return plugins.Response{
+			Success: false,
+		}, err
 	}
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err := reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Cancel",
 					Lines: []models.Line{
 						{
-							Content:   "Action canceled",
+							Content:   sdk.CancelReason(ctx),
 							Color:     "danger",
 							Timestamp: time.Now(),
 						},
@@ -73,7 +89,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "canceled",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -83,14 +99,13 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		return plugins.Response{Success: false, Canceled: true}, nil
 	}
 
-	err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Ping",
 				Lines: []models.Line{
 					{
-						Content:   "Start Ping on target: " + target,
+						Content:   "Start Ping on target: " + params.Target,
 						Timestamp: time.Now(),
 					},
 				},
@@ -98,17 +113,16 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		},
 		Status:    "running",
 		StartedAt: time.Now(),
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
 		}, err
 	}
 
-	pinger, err := probing.NewPinger(target)
+	pinger, err := probing.NewPinger(params.Target)
 	if err != nil {
-		err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err = reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Ping",
@@ -128,7 +142,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "error",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -138,10 +152,20 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			Success: false,
 		}, err
 	}
-	pinger.Count = count
-	timeout := time.Duration(count) * time.Second
+	pinger.Count = params.Count
+	timeout := time.Duration(params.Count) * time.Second
 	pinger.Timeout = timeout
-	err = pinger.Run()
+	err = pinger.RunWithContext(ctx)
+	if ctx.Err() != nil {
+		err = reporter.Cancelled(ctx)
+		if err != nil {
+			return plugins.Response{
+				Success: false,
+			}, err
+		}
+
+		return plugins.Response{Success: false, Canceled: true}, nil
+	}
 	if err != nil {
 		msg := ""
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -149,8 +173,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		} else {
 			msg = "Error running pinger"
 		}
-		err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+		err = reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Ping",
@@ -170,7 +193,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "error",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -182,8 +205,24 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	stats := pinger.Statistics() // get send/receive/duplicate/rtt stats
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	data, err := reporter.Outputs(Output{
+		Target:          params.Target,
+		Addr:            stats.Addr,
+		PacketsSent:     stats.PacketsSent,
+		PacketsReceived: stats.PacketsRecv,
+		PacketLoss:      stats.PacketLoss,
+		RttMin:          milliseconds(stats.MinRtt),
+		RttMax:          milliseconds(stats.MaxRtt),
+		RttAvg:          milliseconds(stats.AvgRtt),
+		RttStdDev:       milliseconds(stats.StdDevRtt),
+	})
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Ping",
@@ -219,16 +258,15 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 				},
 			},
 		},
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
 		}, err
 	}
 
-	if stats.PacketLoss > float64(maxLostPackages) {
-		err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
+	if stats.PacketLoss > float64(params.MaxLostPackages) {
+		err = reporter.Update(models.ExecutionSteps{
 			Messages: []models.Message{
 				{
 					Title: "Ping",
@@ -243,7 +281,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			},
 			Status:     "error",
 			FinishedAt: time.Now(),
-		}, request.Platform)
+		})
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -251,12 +289,12 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 
 		return plugins.Response{
+			Data:    data,
 			Success: false,
 		}, nil
 	}
 
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
+	err = reporter.Update(models.ExecutionSteps{
 		Messages: []models.Message{
 			{
 				Title: "Ping",
@@ -271,7 +309,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		},
 		Status:     "success",
 		FinishedAt: time.Now(),
-	}, request.Platform)
+	})
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -279,37 +317,20 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	return plugins.Response{
+		Data:    data,
 		Success: true,
 	}, nil
 }
 
 func (p *Plugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
-	stepID := request.Step.ID.String()
-	taskCancelsMu.Lock()
-	cancel, ok := taskCancels[stepID]
-	taskCancelsMu.Unlock()
-
-	if !ok {
-		return plugins.Response{
-			Success: false,
-		}, errors.New("task not found")
-	}
-
-	cancel()
-	return plugins.Response{Success: true}, nil
-}
-
-func (p *Plugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
+	return tasks.Cancel(request)
 }
 
 func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	var plugin = models.Plugin{
 		Name:    "Ping",
 		Type:    "action",
-		Version: "1.5.3",
+		Version: "1.6.0",
 		Author:  "JustNZ",
 		Action: models.Action{
 			Name:        "Ping",
@@ -317,35 +338,7 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 			Plugin:      "ping",
 			Icon:        "hugeicons:router-01",
 			Category:    "Network",
-			Params: []models.Params{
-				{
-					Key:         "target",
-					Title:       "Target",
-					Type:        "text",
-					Default:     "www.alertflow.org",
-					Required:    true,
-					Description: "The target to ping",
-					Category:    "General",
-				},
-				{
-					Key:         "count",
-					Title:       "Count",
-					Type:        "number",
-					Default:     "3",
-					Required:    false,
-					Description: "Number of packages to send",
-					Category:    "General",
-				},
-				{
-					Key:         "maxLostPackages",
-					Title:       "Max Lost Packages",
-					Type:        "number",
-					Default:     "0",
-					Required:    false,
-					Description: "Max lost packages to consider the ping failed",
-					Category:    "General",
-				},
-			},
+			Params:      append(sdk.ParamSchema(Params{}), sdk.TimeoutParam()),
 		},
 		Endpoint: models.Endpoint{},
 	}
@@ -353,58 +346,6 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	return plugin, nil
 }
 
-// PluginRPCServer is the RPC server for Plugin
-type PluginRPCServer struct {
-	Impl plugins.Plugin
-}
-
-func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.ExecuteTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.CancelTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
-	result, err := s.Impl.EndpointRequest(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *models.Plugin) error {
-	result, err := s.Impl.Info(request)
-	*resp = result
-	return err
-}
-
-// PluginServer is the implementation of plugin.Plugin interface
-type PluginServer struct {
-	Impl plugins.Plugin
-}
-
-func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
-}
-
-func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &plugins.PluginRPC{Client: c}, nil
-}
-
 func main() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: plugin.HandshakeConfig{
-			ProtocolVersion:  1,
-			MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
-			MagicCookieValue: "hello",
-		},
-		Plugins: map[string]plugin.Plugin{
-			"plugin": &PluginServer{Impl: &Plugin{}},
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
-	})
+	sdk.Serve(&Plugin{})
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/v1Flows/runner-plugins/sdk/plugintest"
+)
+
+func TestParams(t *testing.T) {
+	plugintest.CheckInfo(t, &Plugin{}, Params{})
+}
+
+func TestPortCheck(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	// a closed listener leaves a port nothing listens on
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, closedPort, _ := net.SplitHostPort(closed.Addr().String())
+	closed.Close()
+	defer listener.Close()
+
+	tests := []struct {
+		name    string
+		params  map[string]string
+		open    bool
+		timeout string
+	}{
+		{name: "open", params: map[string]string{"Host": "127.0.0.1", "Port": port}, open: true, timeout: "3"},
+		{name: "closed", params: map[string]string{"Host": "127.0.0.1", "Port": closedPort}, open: false, timeout: "3"},
+		{name: "former timeout key", params: map[string]string{"Host": "127.0.0.1", "Port": port, "Timeout": "1"}, open: true, timeout: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := plugintest.New(&Plugin{})
+			defer h.Close()
+
+			run := h.Start(h.Request(tt.params))
+			response, err := run.Wait()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.Success != tt.open || response.Data["open"] != tt.open {
+				t.Errorf("response = %+v, want open %v", response, tt.open)
+			}
+			if got, want := run.Lines("Port Check")[1], "Connect timeout: "+tt.timeout+" seconds"; got != want {
+				t.Errorf("timeout line = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/melbahja/goph"
+	"golang.org/x/crypto/ssh"
+)
+
+// keyAuth authenticates with the PEM encoded private key, decrypted with
+// passphrase if it is encrypted. A non-empty certificate is an OpenSSH user
+// certificate of the key in authorized_keys format, the key is then presented
+// together with the certificate.
+func keyAuth(key []byte, passphrase string, certificate []byte) (goph.Auth, error) {
+	key = bytes.ReplaceAll(bytes.TrimSpace(key), []byte("\r\n"), []byte("\n"))
+
+	var signer ssh.Signer
+	var err error
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, errors.New("the private key is encrypted, a password is required")
+		}
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	if len(bytes.TrimSpace(certificate)) > 0 {
+		signer, err = certSigner(signer, certificate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return goph.Auth{ssh.PublicKeys(signer)}, nil
+}
+
+// loadCertificate returns the inline certificate, or the content of the
+// certificate file if one is given. Both empty means no certificate.
+func loadCertificate(inline string, file string) ([]byte, error) {
+	if file == "" {
+		return []byte(inline), nil
+	}
+	return os.ReadFile(file)
+}
+
+// certSigner combines signer with the OpenSSH user certificate of its key
+func certSigner(signer ssh.Signer, certificate []byte) (ssh.Signer, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(certificate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("the certificate is a plain %s public key, not a certificate", pub.Type())
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, errors.New("the certificate is a host certificate, a user certificate is required")
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity && time.Now().Unix() >= int64(cert.ValidBefore) {
+		return nil, fmt.Errorf("the certificate %q expired at %s", cert.KeyId, time.Unix(int64(cert.ValidBefore), 0).UTC().Format(time.RFC3339))
+	}
+
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("the certificate does not belong to the private key: %w", err)
+	}
+
+	return certSigner, nil
+}
+
+// describeCert describes the certificate for the step output
+func describeCert(certificate []byte) string {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(certificate)
+	if err != nil {
+		return ""
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return ""
+	}
+
+	description := fmt.Sprintf("certificate %q", cert.KeyId)
+	if len(cert.ValidPrincipals) > 0 {
+		description += " for " + strings.Join(cert.ValidPrincipals, ", ")
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity {
+		description += ", valid until " + time.Unix(int64(cert.ValidBefore), 0).UTC().Format(time.RFC3339)
+	}
+	return description
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// newCertificate returns the authorized_keys line of a certificate of key
+// signed by a new CA
+func newCertificate(t *testing.T, key ssh.PublicKey, certType uint32, validBefore uint64) []byte {
+	t.Helper()
+
+	ca, _ := newPrivateKey(t, "")
+	cert := &ssh.Certificate{
+		Key:             key,
+		CertType:        certType,
+		KeyId:           "deploy",
+		ValidPrincipals: []string{"root", "deploy"},
+		ValidBefore:     validBefore,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	return ssh.MarshalAuthorizedKey(cert)
+}
+
+func TestKeyAuth(t *testing.T) {
+	_, plain := newPrivateKey(t, "")
+	_, encrypted := newPrivateKey(t, "secret")
+
+	tests := []struct {
+		name       string
+		key        []byte
+		passphrase string
+		wantErr    string
+	}{
+		{name: "plain key", key: plain},
+		{name: "surrounding whitespace", key: append(append([]byte("\n  "), plain...), "\n\n"...)},
+		{name: "windows line endings", key: bytes.ReplaceAll(plain, []byte("\n"), []byte("\r\n"))},
+		{name: "encrypted key", key: encrypted, passphrase: "secret"},
+		{name: "missing passphrase", key: encrypted, wantErr: "the private key is encrypted, a password is required"},
+		{name: "wrong passphrase", key: encrypted, passphrase: "wrong", wantErr: "failed to parse private key"},
+		{name: "not a key", key: []byte("ssh-ed25519 AAAA"), wantErr: "failed to parse private key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := keyAuth(tt.key, tt.passphrase, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("keyAuth() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || len(auth) != 1 {
+				t.Fatalf("keyAuth() = %v, %v", auth, err)
+			}
+		})
+	}
+}
+
+func TestCertSigner(t *testing.T) {
+	signer, key := newPrivateKey(t, "")
+	other, _ := newPrivateKey(t, "")
+	future := uint64(time.Now().Add(time.Hour).Unix())
+	past := uint64(time.Now().Add(-time.Hour).Unix())
+
+	tests := []struct {
+		name        string
+		certificate []byte
+		wantErr     string
+	}{
+		{name: "user certificate", certificate: newCertificate(t, signer.PublicKey(), ssh.UserCert, future)},
+		{name: "without expiry", certificate: newCertificate(t, signer.PublicKey(), ssh.UserCert, ssh.CertTimeInfinity)},
+		{name: "host certificate", certificate: newCertificate(t, signer.PublicKey(), ssh.HostCert, future), wantErr: "a user certificate is required"},
+		{name: "expired", certificate: newCertificate(t, signer.PublicKey(), ssh.UserCert, past), wantErr: `the certificate "deploy" expired at`},
+		{name: "other key", certificate: newCertificate(t, other.PublicKey(), ssh.UserCert, future), wantErr: "the certificate does not belong to the private key"},
+		{name: "plain public key", certificate: ssh.MarshalAuthorizedKey(signer.PublicKey()), wantErr: "public key, not a certificate"},
+		{name: "garbage", certificate: []byte("not a certificate"), wantErr: "failed to parse certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := certSigner(signer, tt.certificate)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("certSigner() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("certSigner() error = %v", err)
+			}
+			if _, ok := got.PublicKey().(*ssh.Certificate); !ok {
+				t.Errorf("certSigner() presents %s, want a certificate", got.PublicKey().Type())
+			}
+
+			// keyAuth takes the same certificate next to the inline key
+			if _, err := keyAuth(key, "", tt.certificate); err != nil {
+				t.Errorf("keyAuth() with certificate error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadCertificate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "id_ed25519-cert.pub")
+	if err := os.WriteFile(file, []byte("from file"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := loadCertificate("inline", ""); err != nil || string(got) != "inline" {
+		t.Errorf("loadCertificate() = %q, %v, want the inline certificate", got, err)
+	}
+	if got, err := loadCertificate("inline", file); err != nil || string(got) != "from file" {
+		t.Errorf("loadCertificate() = %q, %v, want the certificate file", got, err)
+	}
+	if _, err := loadCertificate("", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("loadCertificate() of a missing file succeeded")
+	}
+}
+
+func TestDescribeCert(t *testing.T) {
+	signer, _ := newPrivateKey(t, "")
+	validBefore := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if got, want := describeCert(newCertificate(t, signer.PublicKey(), ssh.UserCert, uint64(validBefore.Unix()))), `certificate "deploy" for root, deploy, valid until 2030-01-02T03:04:05Z`; got != want {
+		t.Errorf("describeCert() = %q, want %q", got, want)
+	}
+	if got, want := describeCert(newCertificate(t, signer.PublicKey(), ssh.UserCert, ssh.CertTimeInfinity)), `certificate "deploy" for root, deploy`; got != want {
+		t.Errorf("describeCert() = %q, want %q", got, want)
+	}
+	if got := describeCert(ssh.MarshalAuthorizedKey(signer.PublicKey())); got != "" {
+		t.Errorf("describeCert() of a public key = %q, want empty", got)
+	}
+}
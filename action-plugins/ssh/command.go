@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/melbahja/goph"
+	"github.com/v1Flows/runner-plugins/sdk"
+	"golang.org/x/crypto/ssh"
+)
+
+// cancelGrace is how long a canceled command gets to exit after SIGINT before
+// its session is abandoned
+const cancelGrace = 2 * time.Second
+
+// runCommand executes command in its own session, through sudo if it is set. stdout and stderr are
+// streamed line by line to output while the command runs, stderr colored as a
+// warning, and kept apart in the result together with the exit status. The
+// returned error is nil only for exit status 0.
+func runCommand(ctx context.Context, client *goph.Client, command string, sudo *sudo, output *sdk.LineSink) (CommandOutput, error) {
+	result := CommandOutput{ExitCode: -1}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return result, err
+	}
+	defer session.Close()
+
+	var stdout, stderr lockedBuffer
+	stdoutLines := output.Writer("")
+	stderrLines := output.Writer(sdk.ColorWarning)
+	session.Stdout = io.MultiWriter(&stdout, stdoutLines)
+
+	var stderrWriter io.WriteCloser = nopCloser{io.MultiWriter(&stderr, stderrLines)}
+	if sudo != nil {
+		prompt := newPrompt()
+		command = sudo.wrap(command, prompt)
+
+		if sudo.Password != "" {
+			stdin, err := session.StdinPipe()
+			if err != nil {
+				return result, err
+			}
+			stderrWriter = &promptAnswerer{
+				prompt:   []byte(prompt),
+				password: sudo.Password,
+				stdin:    stdin,
+				next:     stderrWriter,
+			}
+		}
+	}
+	session.Stderr = stderrWriter
+
+	err = session.Start(command)
+	if err == nil {
+		err = wait(ctx, session)
+	}
+
+	_ = stderrWriter.Close()
+	_ = stdoutLines.Close()
+	_ = stderrLines.Close()
+
+	result.Stdout = strings.TrimRight(stdout.String(), "\n")
+	result.Stderr = strings.TrimRight(stderr.String(), "\n")
+
+	var exitErr *ssh.ExitError
+	switch {
+	case err == nil:
+		result.ExitCode = 0
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitStatus()
+	}
+
+	return result, err
+}
+
+// wait waits for the command of session to exit. When ctx is canceled first the
+// command is interrupted and ctx.Err() is returned.
+func wait(ctx context.Context, session *ssh.Session) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	_ = session.Signal(ssh.SIGINT)
+	select {
+	case <-done:
+	case <-time.After(cancelGrace):
+		_ = session.Close()
+	}
+
+	return ctx.Err()
+}
+
+// lockedBuffer is a bytes.Buffer safe to read while the session still writes to it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/melbahja/goph"
+	"github.com/v1Flows/runner-plugins/sdk"
+
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+// Error policies of the on_error param
+const (
+	OnErrorFailFast = "fail_fast"
+	OnErrorContinue = "continue"
+)
+
+// HostSkipped is the status of hosts that were not started because another
+// host failed first
+const HostSkipped = "skipped"
+
+// executor runs the commands of the action on every target host
+type executor struct {
+	params   Params
+	auth     goph.Auth
+	hostKeys hostKeyConfig
+	jumps    []jumpHost
+	script   *script
+	policies []commandPolicy
+	// execution and fingerprint key the pooled connections, fingerprint is
+	// empty unless connections are reused
+	execution   string
+	fingerprint string
+	reporter    *sdk.StepReporter
+}
+
+// runAll runs the commands on hosts, at most params.Parallelism at a time. With
+// fail fast no further hosts are started once a host failed, hosts already
+// running finish their commands. The results keep the order of hosts.
+func (e *executor) runAll(ctx context.Context, hosts []host) []HostOutput {
+	results := make([]HostOutput, len(hosts))
+	for i, h := range hosts {
+		results[i] = HostOutput{Target: h.Name, Status: HostSkipped}
+	}
+
+	parallelism := e.params.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallelism)
+
+	for i, h := range hosts {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil || (failed.Load() && e.params.OnError == OnErrorFailFast) {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, h host) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			results[i] = e.runHost(ctx, h, e.title(h, len(hosts)), len(hosts) > 1)
+			if results[i].Status == sdk.StatusError {
+				failed.Store(true)
+			}
+		}(i, h)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// title groups the output of a host under its own message title. A single
+// host keeps the plain SSH title.
+func (e *executor) title(h host, hosts int) string {
+	if hosts == 1 {
+		return "SSH"
+	}
+	return h.Name
+}
+
+// runHost connects to h and runs all commands on it, stopping at the first
+// failed command
+func (e *executor) runHost(ctx context.Context, h host, title string, multiple bool) HostOutput {
+	result := HostOutput{Target: h.Name}
+
+	// Command output is streamed to the step while the command runs
+	output := sdk.NewLineSink(e.reporter, title, sdk.LineSinkConfig{})
+	defer output.Close()
+
+	fail := func(err error, lines ...string) HostOutput {
+		for _, line := range lines {
+			_ = output.Add(line, sdk.ColorDanger)
+		}
+		_ = output.Add(err.Error(), sdk.ColorDanger)
+
+		result.Status = sdk.StatusError
+		result.Error = err.Error()
+		return result
+	}
+
+	client, release, err := e.connect(h, output)
+	if err != nil {
+		var hostKeyErr *HostKeyError
+		if errors.As(err, &hostKeyErr) {
+			lines := []string{"Host key verification failed for " + hostKeyErr.Host}
+			for _, expected := range hostKeyErr.Expected {
+				lines = append(lines, "Expected: "+expected)
+			}
+			lines = append(lines, "Presented: "+hostKeyErr.Presented)
+
+			return fail(err, lines...)
+		}
+
+		return fail(err, "Failed to connect to remote server")
+	}
+
+	// Defer closing the network connection, or returning it to the pool.
+	defer release()
+
+	if e.params.Operation == OperationUpload || e.params.Operation == OperationDownload {
+		files, err := e.transfer(ctx, client, h, output, multiple)
+		result.Files = files
+		if ctx.Err() != nil {
+			result.Status = sdk.StatusCanceled
+			result.Error = sdk.CancelReason(ctx)
+			return result
+		}
+		if err != nil {
+			return fail(err, "Failed to "+e.params.Operation+" files")
+		}
+
+		result.Status = sdk.StatusSuccess
+		return result
+	}
+
+	if e.script != nil {
+		commandResult, err := e.runScript(ctx, client, e.script, output)
+		result.Commands = append(result.Commands, commandResult)
+		if ctx.Err() != nil {
+			result.Status = sdk.StatusCanceled
+			result.Error = sdk.CancelReason(ctx)
+			return result
+		}
+		if err != nil {
+			return fail(err, "Failed to execute script")
+		}
+
+		result.Status = sdk.StatusSuccess
+		return result
+	}
+
+	for i, command := range e.params.Commands {
+		_ = output.AddLine(models.Line{Content: "-------------------------"})
+		_ = output.AddLine(models.Line{Content: "Executing command: " + command, Color: sdk.ColorPrimary})
+		_ = output.AddLine(models.Line{Content: "-------------------------"})
+
+		// Execute your command.
+		commandResult, err := e.policies[i].run(ctx, output, func() (CommandOutput, error) {
+			return runCommand(ctx, client, command, e.sudo(), output)
+		})
+		commandResult.Command = command
+		result.Commands = append(result.Commands, commandResult)
+
+		if ctx.Err() != nil {
+			result.Status = sdk.StatusCanceled
+			result.Error = sdk.CancelReason(ctx)
+			return result
+		}
+		if err != nil {
+			return fail(err, "Failed to execute command")
+		}
+	}
+
+	result.Status = sdk.StatusSuccess
+	return result
+}
+
+// sudo returns how commands are run through sudo, nil without sudo
+func (e *executor) sudo() *sudo {
+	if !e.params.Sudo {
+		return nil
+	}
+	return &sudo{
+		User:     e.params.SudoUser,
+		Password: e.params.SudoPassword,
+	}
+}
+
+// transfer uploads or downloads the files of the action over SFTP. Downloads
+// from multiple hosts go to a subdirectory per host, so they do not overwrite
+// each other.
+func (e *executor) transfer(ctx context.Context, client *goph.Client, h host, output *sdk.LineSink, multiple bool) ([]FileOutput, error) {
+	sftpClient, err := client.NewSftp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start sftp: %w", err)
+	}
+	defer sftpClient.Close()
+
+	t := &transfer{
+		client:       sftpClient,
+		output:       output,
+		recursive:    e.params.Recursive,
+		preserveMode: e.params.PreserveMode,
+		verify:       e.params.VerifyChecksum,
+	}
+
+	if e.params.Operation == OperationUpload {
+		err = t.upload(ctx, e.params.LocalPath, e.params.RemotePath)
+		return t.files, err
+	}
+
+	local := e.params.LocalPath
+	if multiple {
+		local = filepath.Join(local, hostDir.Replace(h.Name)) + string(filepath.Separator)
+	}
+	err = t.download(ctx, e.params.RemotePath, local)
+	return t.files, err
+}
+
+// hostDir turns a host name into a directory name
+var hostDir = strings.NewReplacer(":", "_", "[", "", "]", "", "/", "_")
+
+// connect returns the connection to h and the func to call once the host is
+// done with it. With reused connections the pooled connection of an earlier
+// step is returned if there is one.
+func (e *executor) connect(h host, output *sdk.LineSink) (*goph.Client, func(), error) {
+	dial := func() (*goph.Client, error) {
+		for _, j := range e.jumps {
+			_ = output.Add("Connecting through jump host "+j.Name+" as "+j.User, "")
+		}
+		_ = output.Add("Connecting to remote server "+h.Name+" as "+e.params.Username, "")
+
+		return connect(h, e.params.Username, e.auth, e.hostKeys, e.jumps)
+	}
+
+	if e.fingerprint == "" {
+		client, err := dial()
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, func() { _ = client.Close() }, nil
+	}
+
+	key := poolKey{
+		Execution: e.execution,
+		Address:   h.address(),
+		User:      e.params.Username,
+		Auth:      e.fingerprint,
+	}
+	client, release, reused, err := pool.acquire(key, e.params.ConnectionIdleTimeout, dial)
+	if err != nil {
+		return nil, nil, err
+	}
+	if reused {
+		_ = output.Add("Reusing connection to remote server "+h.Name+" as "+e.params.Username, "")
+	}
+
+	return client, release, nil
+}
+
+// connect opens the ssh connection to h through the jump hosts, verifying the
+// host key of every hop
+func connect(h host, user string, auth goph.Auth, hostKeys hostKeyConfig, jumps []jumpHost) (*goph.Client, error) {
+	via, err := dialJumps(jumps, auth, hostKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := dialHop(via, h.address(), user, auth, hostKeys)
+	if err != nil {
+		if via != nil {
+			_ = via.Close()
+		}
+		return nil, err
+	}
+
+	return &goph.Client{
+		Client: client,
+		Config: &goph.Config{
+			User:    user,
+			Addr:    h.Addr,
+			Port:    uint(h.Port),
+			Auth:    auth,
+			Timeout: goph.DefaultTimeout,
+		},
+	}, nil
+}
+
+// summary counts the results by status
+func summary(results []HostOutput) Output {
+	out := Output{Hosts: results}
+	for _, result := range results {
+		switch result.Status {
+		case sdk.StatusSuccess:
+			out.Succeeded++
+		case HostSkipped:
+			out.Skipped++
+		default:
+			out.Failed++
+		}
+	}
+	return out
+}
+
+// summaryLines lists the status of every host, colored by status
+func summaryLines(out Output) []models.Line {
+	lines := []models.Line{{Content: "Summary"}}
+	for _, result := range out.Hosts {
+		line := models.Line{Content: result.Target + ": " + result.Status}
+		switch result.Status {
+		case sdk.StatusSuccess:
+			line.Color = sdk.ColorSuccess
+		case HostSkipped:
+			line.Color = sdk.ColorWarning
+		default:
+			line.Color = sdk.ColorDanger
+			if result.Error != "" {
+				line.Content += " (" + result.Error + ")"
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Host key verification modes of the host_key_verification param
+const (
+	HostKeyTOFU           = "tofu"
+	HostKeyKnownHostsFile = "known_hosts_file"
+	HostKeyKnownHosts     = "known_hosts"
+	HostKeyFingerprint    = "fingerprint"
+	HostKeyInsecure       = "insecure"
+)
+
+// tofuMu serializes reads and writes of the trust on first use store, steps of
+// the same plugin process may connect to the same new host at once
+var tofuMu sync.Mutex
+
+// HostKeyError is returned when the server presents a key that is not trusted
+type HostKeyError struct {
+	Host      string
+	Expected  []string
+	Presented string
+}
+
+func (e *HostKeyError) Error() string {
+	if len(e.Expected) == 0 {
+		return fmt.Sprintf("host key of %s is not known, presented %s", e.Host, e.Presented)
+	}
+	return fmt.Sprintf("host key mismatch for %s: expected %s, presented %s", e.Host, strings.Join(e.Expected, " or "), e.Presented)
+}
+
+// hostKeyConfig verifies the key presented by a server
+type hostKeyConfig struct {
+	Mode           string
+	KnownHostsFile string
+	KnownHosts     string
+	Fingerprints   []string
+	// Store is the known_hosts file trust on first use records new keys in
+	Store string
+	// OnTrust is called when trust on first use records a new key
+	OnTrust func(host string, key ssh.PublicKey)
+}
+
+// apply sets the host key callback of config, and for known_hosts based modes
+// limits the host key algorithms to the ones known for address, so the server
+// does not present a key of another type than the recorded one
+func (c hostKeyConfig) apply(config *ssh.ClientConfig, address string) error {
+	switch c.Mode {
+	case HostKeyInsecure:
+		config.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+		return nil
+	case HostKeyFingerprint:
+		if len(c.Fingerprints) == 0 {
+			return errors.New("no host key fingerprint given")
+		}
+		config.HostKeyCallback = c.fingerprintCallback()
+		return nil
+	case HostKeyKnownHostsFile:
+		path, err := expandHome(c.KnownHostsFile)
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			path, err = expandHome("~/.ssh/known_hosts")
+			if err != nil {
+				return err
+			}
+		}
+		callback, err := knownhosts.New(path)
+		if err != nil {
+			return fmt.Errorf("failed to read known_hosts file: %w", err)
+		}
+		config.HostKeyCallback = verified(callback)
+		config.HostKeyAlgorithms = knownAlgorithms(callback, address)
+		return nil
+	case HostKeyKnownHosts:
+		callback, err := knownHostsContent(c.KnownHosts)
+		if err != nil {
+			return err
+		}
+		config.HostKeyCallback = verified(callback)
+		config.HostKeyAlgorithms = knownAlgorithms(callback, address)
+		return nil
+	case HostKeyTOFU:
+		store, err := c.store()
+		if err != nil {
+			return err
+		}
+		tofuMu.Lock()
+		callback, err := knownhosts.New(store)
+		tofuMu.Unlock()
+		if err != nil {
+			return fmt.Errorf("failed to read host key store %s: %w", store, err)
+		}
+		config.HostKeyCallback = c.tofuCallback(store)
+		config.HostKeyAlgorithms = knownAlgorithms(callback, address)
+		return nil
+	default:
+		return fmt.Errorf("unknown host key verification %q", c.Mode)
+	}
+}
+
+func (c hostKeyConfig) fingerprintCallback() ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		presented := ssh.FingerprintSHA256(key)
+		for _, fingerprint := range c.Fingerprints {
+			if normalizeFingerprint(fingerprint) == presented {
+				return nil
+			}
+		}
+
+		return &HostKeyError{
+			Host:      hostname,
+			Expected:  c.Fingerprints,
+			Presented: describeKey(key),
+		}
+	}
+}
+
+// tofuCallback trusts and records the first key of a host and verifies every
+// later connection against it
+func (c hostKeyConfig) tofuCallback(store string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		tofuMu.Lock()
+		defer tofuMu.Unlock()
+
+		callback, err := knownhosts.New(store)
+		if err != nil {
+			return fmt.Errorf("failed to read host key store %s: %w", store, err)
+		}
+
+		err = callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) || len(keyErr.Want) > 0 {
+			return hostKeyError(hostname, key, err)
+		}
+
+		file, err := os.OpenFile(store, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open host key store %s: %w", store, err)
+		}
+		defer file.Close()
+
+		_, err = file.WriteString(knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key) + "\n")
+		if err != nil {
+			return fmt.Errorf("failed to record host key in %s: %w", store, err)
+		}
+
+		if c.OnTrust != nil {
+			c.OnTrust(hostname, key)
+		}
+
+		return nil
+	}
+}
+
+// store returns the path of the trust on first use store and creates it if missing
+func (c hostKeyConfig) store() (string, error) {
+	store, err := expandHome(c.Store)
+	if err != nil {
+		return "", err
+	}
+	if store == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		store = filepath.Join(dir, "runner-plugins", "ssh", "known_hosts")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(store), 0700); err != nil {
+		return "", fmt.Errorf("failed to create host key store: %w", err)
+	}
+
+	file, err := os.OpenFile(store, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create host key store: %w", err)
+	}
+
+	return store, file.Close()
+}
+
+// knownHostsContent parses inline known_hosts content. knownhosts only reads
+// files, the temporary copy is removed once it was parsed.
+func knownHostsContent(content string) (ssh.HostKeyCallback, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, errors.New("no known_hosts content given")
+	}
+
+	file, err := os.CreateTemp("", "known_hosts")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(content + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	callback, err := knownhosts.New(file.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse known_hosts content: %w", err)
+	}
+
+	return callback, nil
+}
+
+// verified turns the errors of a knownhosts callback into HostKeyErrors
+func verified(callback ssh.HostKeyCallback) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		return hostKeyError(hostname, key, callback(hostname, remote, key))
+	}
+}
+
+func hostKeyError(hostname string, key ssh.PublicKey, err error) error {
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return err
+	}
+
+	expected := make([]string, 0, len(keyErr.Want))
+	for _, want := range keyErr.Want {
+		expected = append(expected, describeKey(want.Key))
+	}
+
+	return &HostKeyError{
+		Host:      hostname,
+		Expected:  expected,
+		Presented: describeKey(key),
+	}
+}
+
+// knownAlgorithms returns the host key algorithms of the keys known for
+// address, or nil to let the server choose when none are known
+func knownAlgorithms(callback ssh.HostKeyCallback, address string) []string {
+	var keyErr *knownhosts.KeyError
+	if err := callback(address, &net.TCPAddr{}, unknownKey{}); !errors.As(err, &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	seen := make(map[string]bool)
+	for _, want := range keyErr.Want {
+		for _, algorithm := range keyAlgorithms(want.Key.Type()) {
+			if !seen[algorithm] {
+				seen[algorithm] = true
+				algorithms = append(algorithms, algorithm)
+			}
+		}
+	}
+
+	return algorithms
+}
+
+// keyAlgorithms maps a key type to the signature algorithms that verify it
+func keyAlgorithms(keyType string) []string {
+	switch keyType {
+	case ssh.KeyAlgoRSA:
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	case ssh.CertAlgoRSAv01:
+		return []string{ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01}
+	default:
+		return []string{keyType}
+	}
+}
+
+// unknownKey never matches a known key, it is used to list the keys known for a host
+type unknownKey struct{}
+
+func (unknownKey) Type() string                                 { return "unknown" }
+func (unknownKey) Marshal() []byte                              { return []byte("unknown") }
+func (unknownKey) Verify(data []byte, sig *ssh.Signature) error { return errors.New("unknown key") }
+
+func describeKey(key ssh.PublicKey) string {
+	return ssh.FingerprintSHA256(key) + " (" + key.Type() + ")"
+}
+
+// normalizeFingerprint accepts fingerprints with or without the SHA256: prefix
+// and base64 padding, as printed by ssh-keygen -l or copied from elsewhere
+func normalizeFingerprint(fingerprint string) string {
+	fingerprint = strings.TrimSpace(fingerprint)
+	fingerprint = strings.TrimPrefix(fingerprint, "SHA256:")
+	return "SHA256:" + strings.TrimRight(fingerprint, "=")
+}
+
+func expandHome(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+var testRemote = &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 22}
+
+func TestNormalizeFingerprint(t *testing.T) {
+	tests := []struct {
+		fingerprint string
+		want        string
+	}{
+		{fingerprint: "SHA256:abc", want: "SHA256:abc"},
+		{fingerprint: "abc", want: "SHA256:abc"},
+		{fingerprint: "  SHA256:abc=  ", want: "SHA256:abc"},
+		{fingerprint: "abc==", want: "SHA256:abc"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeFingerprint(tt.fingerprint); got != tt.want {
+			t.Errorf("normalizeFingerprint(%q) = %q, want %q", tt.fingerprint, got, tt.want)
+		}
+	}
+}
+
+func TestFingerprintCallback(t *testing.T) {
+	key := newHostKey(t)
+	fingerprint := ssh.FingerprintSHA256(key)
+
+	tests := []struct {
+		name         string
+		fingerprints []string
+		wantErr      bool
+	}{
+		{name: "exact", fingerprints: []string{fingerprint}},
+		{name: "without prefix", fingerprints: []string{strings.TrimPrefix(fingerprint, "SHA256:")}},
+		{name: "padded", fingerprints: []string{fingerprint + "="}},
+		{name: "one of several", fingerprints: []string{"SHA256:other", fingerprint}},
+		{name: "mismatch", fingerprints: []string{"SHA256:other"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callback := hostKeyConfig{Mode: HostKeyFingerprint, Fingerprints: tt.fingerprints}.fingerprintCallback()
+			err := callback("web1:22", testRemote, key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("callback error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var hostKeyErr *HostKeyError
+			if err != nil && !errors.As(err, &hostKeyErr) {
+				t.Errorf("error = %T, want *HostKeyError", err)
+			}
+		})
+	}
+}
+
+func TestTofuCallback(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+	var trusted []string
+	config := hostKeyConfig{
+		Mode:  HostKeyTOFU,
+		Store: store,
+		OnTrust: func(host string, key ssh.PublicKey) {
+			trusted = append(trusted, host)
+		},
+	}
+
+	store, err := config.store()
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback := config.tofuCallback(store)
+	key, otherKey := newHostKey(t), newHostKey(t)
+
+	steps := []struct {
+		name    string
+		host    string
+		key     ssh.PublicKey
+		wantErr bool
+	}{
+		{name: "first key is trusted", host: "web1:22", key: key},
+		{name: "same key is verified", host: "web1:22", key: key},
+		{name: "changed key is rejected", host: "web1:22", key: otherKey, wantErr: true},
+		{name: "other host is trusted", host: "web2:2222", key: otherKey},
+	}
+
+	for _, step := range steps {
+		err := callback(step.host, testRemote, step.key)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+
+		var hostKeyErr *HostKeyError
+		if err != nil && (!errors.As(err, &hostKeyErr) || len(hostKeyErr.Expected) != 1 || !strings.HasPrefix(hostKeyErr.Expected[0], ssh.FingerprintSHA256(key))) {
+			t.Errorf("%s: error = %v, want a mismatch expecting the recorded key", step.name, err)
+		}
+	}
+
+	if want := []string{"web1:22", "web2:2222"}; strings.Join(trusted, ",") != strings.Join(want, ",") {
+		t.Errorf("trusted = %q, want %q", trusted, want)
+	}
+
+	content, err := os.ReadFile(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "web1 ") || !strings.HasPrefix(lines[1], "[web2]:2222 ") {
+		t.Errorf("store =\n%s", content)
+	}
+}
+
+func TestKnownHostsContent(t *testing.T) {
+	key, otherKey := newHostKey(t), newHostKey(t)
+	content := "# comment\n" + knownhosts.Line([]string{"web1"}, key)
+
+	callback, err := knownHostsContent(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := verified(callback)
+
+	if err := verify("web1:22", testRemote, key); err != nil {
+		t.Errorf("known key: %v", err)
+	}
+
+	var hostKeyErr *HostKeyError
+	if err := verify("web1:22", testRemote, otherKey); !errors.As(err, &hostKeyErr) || len(hostKeyErr.Expected) != 1 {
+		t.Errorf("changed key: error = %v, want a mismatch", err)
+	}
+	if err := verify("web2:22", testRemote, key); !errors.As(err, &hostKeyErr) || len(hostKeyErr.Expected) != 0 {
+		t.Errorf("unknown host: error = %v, want an unknown host", err)
+	}
+
+	if got, want := knownAlgorithms(callback, "web1:22"), []string{ssh.KeyAlgoED25519}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("knownAlgorithms() = %q, want %q", got, want)
+	}
+	if got := knownAlgorithms(callback, "web2:22"); got != nil {
+		t.Errorf("knownAlgorithms() of an unknown host = %q, want nil", got)
+	}
+
+	if _, err := knownHostsContent("  \n"); err == nil {
+		t.Error("knownHostsContent() accepted empty content")
+	}
+}
+
+func TestKeyAlgorithms(t *testing.T) {
+	tests := []struct {
+		keyType string
+		want    []string
+	}{
+		{keyType: ssh.KeyAlgoRSA, want: []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}},
+		{keyType: ssh.CertAlgoRSAv01, want: []string{ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01}},
+		{keyType: ssh.KeyAlgoED25519, want: []string{ssh.KeyAlgoED25519}},
+	}
+
+	for _, tt := range tests {
+		if got := keyAlgorithms(tt.keyType); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("keyAlgorithms(%q) = %q, want %q", tt.keyType, got, tt.want)
+		}
+	}
+}
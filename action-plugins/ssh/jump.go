@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/melbahja/goph"
+	"golang.org/x/crypto/ssh"
+)
+
+// jumpHost is a bastion the connection to the targets goes through
+type jumpHost struct {
+	host
+	User string
+	// Auth is nil when the jump host uses the authentication of the target
+	Auth goph.Auth
+}
+
+// parseJumpHosts parses the jump_hosts param, one jump host per line in the
+// order the connection goes through them. A line is [user@]host[:port],
+// optionally followed by key=<private key file> or agent. The password line
+// at the same position in passwords authenticates the jump host, or decrypts
+// its private key. Without any of them the jump host uses the authentication
+// of the target.
+func parseJumpHosts(lines []string, passwords string, defaultUser string) ([]jumpHost, error) {
+	var fields [][]string
+	for _, line := range lines {
+		if f := strings.Fields(line); len(f) > 0 {
+			fields = append(fields, f)
+		}
+	}
+
+	// empty lines keep the position of jump hosts without password, only the
+	// trailing ones, like the final newline of a textarea, are dropped
+	secrets := strings.Split(strings.ReplaceAll(passwords, "\r\n", "\n"), "\n")
+	for len(secrets) > 0 && secrets[len(secrets)-1] == "" {
+		secrets = secrets[:len(secrets)-1]
+	}
+	if len(secrets) > len(fields) {
+		return nil, fmt.Errorf("%d jump host passwords given for %d jump hosts", len(secrets), len(fields))
+	}
+
+	jumps := make([]jumpHost, 0, len(fields))
+	for i, f := range fields {
+		name, user := f[0], defaultUser
+		if at := strings.LastIndex(name, "@"); at >= 0 {
+			name, user = name[at+1:], name[:at]
+		}
+
+		h, err := parseHost(name, 22)
+		if err != nil {
+			return nil, err
+		}
+		if h.Addr == "" || user == "" {
+			return nil, fmt.Errorf("invalid jump host %q", f[0])
+		}
+
+		password := ""
+		if i < len(secrets) {
+			password = secrets[i]
+		}
+
+		j := jumpHost{host: h, User: user}
+		for _, option := range f[1:] {
+			switch {
+			case strings.HasPrefix(option, "key="):
+				j.Auth, err = goph.Key(strings.TrimPrefix(option, "key="), password)
+				if err != nil {
+					return nil, fmt.Errorf("failed to load private key of jump host %s: %w", h.Name, err)
+				}
+			case option == "agent":
+				j.Auth, err = goph.UseAgent()
+				if err != nil {
+					return nil, fmt.Errorf("failed to connect to SSH agent for jump host %s: %w", h.Name, err)
+				}
+			default:
+				return nil, fmt.Errorf("unknown option %q of jump host %s", option, h.Name)
+			}
+		}
+		if j.Auth == nil && password != "" {
+			j.Auth = goph.Password(password)
+		}
+
+		jumps = append(jumps, j)
+	}
+
+	return jumps, nil
+}
+
+// dialJumps connects through the jump hosts in order, like ProxyJump. Every
+// hop verifies the host key of the next one. The returned client is connected
+// to the last jump host.
+func dialJumps(jumps []jumpHost, auth goph.Auth, hostKeys hostKeyConfig) (*ssh.Client, error) {
+	var client *ssh.Client
+	for _, j := range jumps {
+		hopAuth := j.Auth
+		if hopAuth == nil {
+			hopAuth = auth
+		}
+
+		next, err := dialHop(client, j.address(), j.User, hopAuth, hostKeys)
+		if err != nil {
+			if client != nil {
+				_ = client.Close()
+			}
+			return nil, fmt.Errorf("jump host %s: %w", j.Name, err)
+		}
+		client = next
+	}
+
+	return client, nil
+}
+
+// dialHop opens an ssh connection to address, directly if via is nil or
+// tunneled through via otherwise. Closing the returned client also closes via.
+func dialHop(via *ssh.Client, address string, user string, auth goph.Auth, hostKeys hostKeyConfig) (*ssh.Client, error) {
+	config := &ssh.ClientConfig{
+		User:    user,
+		Auth:    auth,
+		Timeout: goph.DefaultTimeout,
+	}
+	if err := hostKeys.apply(config, address); err != nil {
+		return nil, err
+	}
+
+	if via == nil {
+		return ssh.Dial("tcp", address, config)
+	}
+
+	conn, err := via.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn = &chainedConn{Conn: conn, via: via}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// chainedConn closes the jump host connection it is tunneled through together
+// with the tunnel
+type chainedConn struct {
+	net.Conn
+	via *ssh.Client
+}
+
+func (c *chainedConn) Close() error {
+	err := c.Conn.Close()
+	_ = c.via.Close()
+	return err
+}
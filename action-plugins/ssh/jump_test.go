@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// newPrivateKey returns a signer and its PEM encoded private key, encrypted
+// with passphrase if it is not empty
+func newPrivateKey(t *testing.T, passphrase string) (ssh.Signer, []byte) {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var block *pem.Block
+	if passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(private, "", []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(private, "")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, pem.EncodeToMemory(block)
+}
+
+func TestParseJumpHosts(t *testing.T) {
+	_, key := newPrivateKey(t, "keypass")
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyFile, key, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		lines     []string
+		passwords string
+		want      []host
+		wantUsers []string
+		wantAuth  []bool
+		wantErr   bool
+	}{
+		{
+			name:      "default user and port",
+			lines:     []string{"bastion"},
+			want:      []host{{Name: "bastion", Addr: "bastion", Port: 22}},
+			wantUsers: []string{"alice"},
+			wantAuth:  []bool{false},
+		},
+		{
+			name:      "user, port and ipv6",
+			lines:     []string{"", "bob@bastion:2222", "  ", "carol@[2001:db8::1]:2200"},
+			want:      []host{{Name: "bastion:2222", Addr: "bastion", Port: 2222}, {Name: "[2001:db8::1]:2200", Addr: "2001:db8::1", Port: 2200}},
+			wantUsers: []string{"bob", "carol"},
+			wantAuth:  []bool{false, false},
+		},
+		{
+			name:      "per hop passwords",
+			lines:     []string{"hop1", "hop2", "hop3"},
+			passwords: "pw1\n\npw3",
+			want:      []host{{Name: "hop1", Addr: "hop1", Port: 22}, {Name: "hop2", Addr: "hop2", Port: 22}, {Name: "hop3", Addr: "hop3", Port: 22}},
+			wantUsers: []string{"alice", "alice", "alice"},
+			wantAuth:  []bool{true, false, true},
+		},
+		{
+			name:      "trailing newlines",
+			lines:     []string{"hop1", "hop2"},
+			passwords: "\r\npw2\r\n\n",
+			want:      []host{{Name: "hop1", Addr: "hop1", Port: 22}, {Name: "hop2", Addr: "hop2", Port: 22}},
+			wantUsers: []string{"alice", "alice"},
+			wantAuth:  []bool{false, true},
+		},
+		{
+			name:      "encrypted key file",
+			lines:     []string{"hop1 key=" + keyFile},
+			passwords: "keypass\n",
+			want:      []host{{Name: "hop1", Addr: "hop1", Port: 22}},
+			wantUsers: []string{"alice"},
+			wantAuth:  []bool{true},
+		},
+		{name: "wrong key password", lines: []string{"hop1 key=" + keyFile}, passwords: "wrong", wantErr: true},
+		{name: "too many passwords", lines: []string{"hop1"}, passwords: "pw1\npw2", wantErr: true},
+		{name: "unknown option", lines: []string{"hop1 port=22"}, wantErr: true},
+		{name: "invalid port", lines: []string{"hop1:99999"}, wantErr: true},
+		{name: "empty user", lines: []string{"@hop1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jumps, err := parseJumpHosts(tt.lines, tt.passwords, "alice")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJumpHosts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(jumps) != len(tt.want) {
+				t.Fatalf("parseJumpHosts() = %+v, want %d jump hosts", jumps, len(tt.want))
+			}
+			for i, j := range jumps {
+				if j.host != tt.want[i] || j.User != tt.wantUsers[i] || (j.Auth != nil) != tt.wantAuth[i] {
+					t.Errorf("jump host %d = %+v %s auth %v, want %+v %s auth %v", i, j.host, j.User, j.Auth != nil, tt.want[i], tt.wantUsers[i], tt.wantAuth[i])
+				}
+			}
+		})
+	}
+}
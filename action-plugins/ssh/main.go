@@ -1,120 +1,214 @@
 package main
 
 import (
-	"context"
+	"bytes"
 	"errors"
-	"net/rpc"
-	"strconv"
-	"strings"
-	"sync"
+	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/melbahja/goph"
-	"github.com/v1Flows/runner/pkg/executions"
+	"github.com/v1Flows/runner-plugins/sdk"
 	"github.com/v1Flows/runner/pkg/plugins"
 	"golang.org/x/crypto/ssh"
 
 	"github.com/v1Flows/shared-library/pkg/models"
-
-	"github.com/hashicorp/go-plugin"
 )
 
 // Plugin is an implementation of the Plugin interface
-type Plugin struct{}
+type Plugin struct {
+	sdk.ActionPlugin
+}
 
-var (
-	taskCancels   = make(map[string]context.CancelFunc)
-	taskCancelsMu sync.Mutex
-)
+var tasks = sdk.NewTaskRegistry()
+
+// Params are the action params of the ssh plugin
+type Params struct {
+	Target                 string        `param:"target,required" title:"Target" type:"textarea" category:"Destination" description:"The target server IP addresses or hostnames, one per line or separated by commas. host:port overrides the port and ranges like web[01:10].example.com expand to a group of hosts"`
+	Port                   uint16        `param:"port,required" title:"Port" default:"22" category:"Destination" description:"The target server port"`
+	AuthenticationMethod   string        `param:"authentication_method,required" title:"Authentication Method" type:"select" default:"password" options:"password=Password,private_key=Private Key,private_key_file=Private Key File,ssh_agent=SSH Agent" category:"Credentials" description:"The authentication method to use"`
+	Username               string        `param:"username,required" title:"Username" depends:"authentication_method=password" category:"Credentials" description:"The username to authenticate with"`
+	Password               string        `param:"password" title:"Password" type:"password" depends:"authentication_method=password" category:"Credentials" description:"The password to authenticate with"`
+	PrivateKeyFile         string        `param:"private_key_file" title:"Private Key File" depends:"authentication_method=private_key_file" category:"Credentials" description:"The private key file path to authenticate with. This path must be accessible by the runner"`
+	PrivateKeyFilePassword string        `param:"private_key_file_password" title:"Private Key File Password" type:"password" depends:"authentication_method=private_key_file" category:"Credentials" description:"The password to decrypt the private key file"`
+	PrivateKey             string        `param:"private_key" title:"Private Key" type:"password" depends:"authentication_method=private_key" category:"Credentials" description:"The private key to authenticate with, pasted as a whole in PEM or OpenSSH format. It is stored encrypted like every password"`
+	PrivateKeyPassword     string        `param:"private_key_password" title:"Private Key Password" type:"password" depends:"authentication_method=private_key" category:"Credentials" description:"The password to decrypt the private key"`
+	Certificate            string        `param:"certificate" title:"Certificate" type:"textarea" category:"Credentials" description:"OpenSSH user certificate of the private key or private key file, the content of a file like id_ed25519-cert.pub. The key is presented together with the certificate"`
+	CertificateFile        string        `param:"certificate_file" title:"Certificate File" category:"Credentials" description:"Path of the OpenSSH user certificate on the runner, used instead of the certificate above"`
+	UseSSHAgent            bool          `param:"use_ssh_agent" title:"Use SSH Agent" default:"false" depends:"authentication_method=ssh_agent" category:"Credentials" description:"Use the SSH agent to authenticate"`
+	JumpHosts              []string      `param:"jump_hosts" title:"Jump Hosts" category:"Jump Hosts" description:"Bastion hosts to connect through, in order, one per line as [user@]host[:port] like ssh -J. Add key=<private key file> or agent after the host to authenticate it with a private key file on the runner or the SSH agent. Jump hosts without own authentication use the authentication of the target. The host key of every jump host is verified like the key of the target"`
+	JumpHostPasswords      string        `param:"jump_host_passwords" title:"Jump Host Passwords" type:"password" category:"Jump Hosts" description:"One line per jump host, in the order of the jump hosts: the password of the jump host, or the password of its private key file. Leave a line empty for jump hosts without password"`
+	Sudo                   bool          `param:"sudo,required" title:"Use Sudo" default:"false" category:"Privileges" description:"Use sudo to execute the commands"`
+	SudoPassword           string        `param:"sudo_password" title:"Sudo Password" type:"password" depends:"sudo=true" category:"Privileges" description:"The password to authenticate with sudo. It is passed to sudo on stdin, never on the command line. Without a password sudo must not ask for one"`
+	SudoUser               string        `param:"sudo_user" title:"Sudo User" depends:"sudo=true" category:"Privileges" description:"Run the commands as this user (sudo -u). Defaults to root"`
+	Operation              string        `param:"operation,required" title:"Operation" type:"select" default:"exec" options:"exec=Execute Commands,script=Run Script,upload=Upload,download=Download" category:"Operation" description:"Execute commands on the remote server, run a script or transfer files over SFTP"`
+	Commands               []string      `param:"commands,required" title:"Commands" depends:"operation=exec" category:"Commands" description:"The commands to execute on the remote server. Each command should be on a new line"`
+	Script                 string        `param:"script,required" title:"Script" type:"textarea" depends:"operation=script" category:"Script" description:"The script to run on the remote server. It is uploaded to a temporary file, run as a whole and removed afterwards"`
+	Interpreter            string        `param:"interpreter,required" title:"Interpreter" type:"select" default:"sh" options:"sh=sh,bash=bash,python3=python3,pwsh=PowerShell (pwsh)" depends:"operation=script" category:"Script" description:"The interpreter the script is run with. It must be installed on the remote server"`
+	ScriptArgs             []string      `param:"script_args" title:"Arguments" depends:"operation=script" category:"Script" description:"The arguments passed to the script, one per line"`
+	ScriptEnv              []string      `param:"script_env" title:"Environment" depends:"operation=script" category:"Script" description:"Environment variables of the script, one NAME=value per line"`
+	ScriptDir              string        `param:"script_dir" title:"Script Directory" default:"/tmp" depends:"operation=script" category:"Script" description:"The directory on the remote server the script is uploaded to. It must be writable by the user and allow executing files"`
+	LocalPath              string        `param:"local_path" title:"Local Path" category:"Transfer" description:"File or directory on the runner to upload, or the download destination. Relative paths are resolved in the workspace. Downloads from multiple hosts go to a subdirectory per host"`
+	RemotePath             string        `param:"remote_path" title:"Remote Path" category:"Transfer" description:"File or directory on the remote server to download, or the upload destination. Existing directories and paths ending with / receive the source under its own name"`
+	Recursive              bool          `param:"recursive" title:"Recursive" default:"false" category:"Transfer" description:"Transfer directories with all their content"`
+	PreserveMode           bool          `param:"preserve_mode" title:"Preserve Mode" default:"true" category:"Transfer" description:"Give the copies the file mode of the source"`
+	VerifyChecksum         bool          `param:"verify_checksum" title:"Verify Checksum" default:"true" category:"Transfer" description:"Read every copy back after the transfer and compare its SHA256 checksum with the source"`
+	AllowedExitCodes       string        `param:"allowed_exit_codes" title:"Allowed Exit Codes" default:"0" category:"Error Handling" description:"Exit codes that count as success, separated by commas"`
+	IgnoreErrors           bool          `param:"ignore_errors" title:"Ignore Errors" default:"false" category:"Error Handling" description:"Continue with the next command when a command failed after all retries. The host still succeeds"`
+	Retries                int           `param:"retries" title:"Retries" default:"0" category:"Error Handling" description:"How often a failed command is tried again"`
+	RetryDelay             time.Duration `param:"retry_delay" title:"Retry Delay" default:"5s" category:"Error Handling" description:"The time to wait before a command is tried again, e.g. 5s or 1m"`
+	RetryBackoff           string        `param:"retry_backoff" title:"Retry Backoff" type:"select" default:"constant" options:"constant=Constant,exponential=Exponential" category:"Error Handling" description:"Constant waits the retry delay before every retry, exponential doubles it after every retry up to 5 minutes"`
+	CommandRules           []string      `param:"command_rules" title:"Command Rules" category:"Error Handling" description:"Rules overriding the settings above for single commands, one per line as <n>: option..., n being the number of the command starting at 1 (the script is command 1). Options are exit_codes=0,1, ignore_errors, retries=<n>, delay=<duration>, backoff=constant|exponential and until=<regex>. until retries the command until its output matches the regex and takes the rest of the line, e.g. 2: retries=30 delay=2s until=status: (healthy|ready)"`
+	ReuseConnection        bool          `param:"reuse_connection" title:"Reuse Connection" default:"false" category:"Connection" description:"Keep the connection open for the following ssh steps of the execution on the same host with the same user and authentication, so they skip the handshake"`
+	ConnectionIdleTimeout  time.Duration `param:"connection_idle_timeout" title:"Connection Idle Timeout" default:"1m" depends:"reuse_connection=true" category:"Connection" description:"How long a reused connection is kept open without a step using it, e.g. 30s or 5m. Canceling the execution closes it right away"`
+	Parallelism            int           `param:"parallelism" title:"Parallelism" default:"1" category:"Execution" description:"The number of hosts the commands run on at the same time"`
+	OnError                string        `param:"on_error" title:"On Error" type:"select" default:"fail_fast" options:"fail_fast=Fail Fast,continue=Continue On Error" category:"Execution" description:"Fail fast starts no further hosts once a host failed, hosts already running finish their commands. Continue on error runs the commands on all hosts"`
+	HostKeyVerification    string        `param:"host_key_verification,required" title:"Host Key Verification" type:"select" default:"tofu" options:"tofu=Trust On First Use,known_hosts_file=Known Hosts File,known_hosts=Known Hosts,fingerprint=Pinned Fingerprint,insecure=Disabled (insecure)" category:"Host Key" description:"How the key presented by the server is verified. Trust on first use records the key of a new server on the runner and rejects changed keys"`
+	KnownHostsFile         string        `param:"known_hosts_file" title:"Known Hosts File" depends:"host_key_verification=known_hosts_file" category:"Host Key" description:"Path of a known_hosts file on the runner. Defaults to ~/.ssh/known_hosts of the runner user"`
+	KnownHosts             string        `param:"known_hosts,required" title:"Known Hosts" type:"textarea" depends:"host_key_verification=known_hosts" category:"Host Key" description:"known_hosts lines of the server, e.g. the output of ssh-keyscan"`
+	HostKeyFingerprints    []string      `param:"host_key_fingerprints,required" title:"Host Key Fingerprints" depends:"host_key_verification=fingerprint" category:"Host Key" description:"SHA256 fingerprints of the server key as printed by ssh-keygen -l, e.g. SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s. One per line"`
+	HostKeyStore           string        `param:"host_key_store" title:"Host Key Store" depends:"host_key_verification=tofu" category:"Host Key" description:"File the runner records trusted host keys in. Defaults to runner-plugins/ssh/known_hosts in the config directory of the runner user"`
+}
 
-// ifEmpty returns the defaultValue if the input is an empty string, otherwise it returns the input.
-func ifEmpty(input, defaultValue string) string {
-	if input == "" {
-		return defaultValue
-	}
-	return input
+// Output is published in Response.Data, see sdk.Outputs for the key layout
+type Output struct {
+	Hosts     []HostOutput `json:"hosts"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Skipped   int          `json:"skipped"`
+}
+
+// HostOutput is the result of a single target host. Status is success, error,
+// canceled or skipped.
+type HostOutput struct {
+	Target   string          `json:"target"`
+	Status   string          `json:"status"`
+	Error    string          `json:"error,omitempty"`
+	Commands []CommandOutput `json:"commands"`
+	Files    []FileOutput    `json:"files"`
+}
+
+// CommandOutput is the result of a single command. ExitCode is -1 when the
+// command did not report an exit status, e.g. because it was canceled. Ignored
+// is set when the command failed and ignore_errors let the host continue.
+type CommandOutput struct {
+	Command  string `json:"command"`
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	Attempts int    `json:"attempts"`
+	Ignored  bool   `json:"ignored"`
 }
 
 func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	stepID := request.Step.ID.String()
-
-	// Store cancel func
-	taskCancelsMu.Lock()
-	taskCancels[stepID] = cancel
-	taskCancelsMu.Unlock()
-	defer func() {
-		taskCancelsMu.Lock()
-		delete(taskCancels, stepID)
-		taskCancelsMu.Unlock()
-	}()
-
-	var target string
-	var port uint
-	var username string
-	var password string
-	var privateKeyFile string
-	var privateKeyFilePassword string
-	var useSSHAgent bool
-	var commands []string
-	var sudo bool
-	var sudoPassword string
-
-	// access action params
-	for _, param := range request.Step.Action.Params {
-		if param.Key == "target" {
-			target = param.Value
+	ctx, done, err := tasks.Register(request)
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+	defer done()
+
+	// runs after the hosts released their connections
+	if isFinalStep(request) {
+		defer pool.closeExecution(request.Step.ExecutionID)
+	}
+
+	reporter := sdk.NewStepReporter(request)
+
+	info, err := p.Info(plugins.InfoRequest{Config: request.Config, Workspace: request.Workspace})
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
+	var params Params
+	err = reporter.DecodeParams(info.Action.Params, &params)
+	if err != nil {
+		_ = reporter.InvalidParams(err)
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
+	var run *script
+	if params.Operation == OperationScript {
+		env, err := parseScriptEnv(params.ScriptEnv)
+		if err != nil {
+			errs := sdk.ParamErrors{{Key: "script_env", Title: "Environment", Message: err.Error()}}
+			_ = reporter.InvalidParams(errs)
+			return plugins.Response{
+				Success: false,
+			}, errs
 		}
-		if param.Key == "port" {
-			portInt, _ := strconv.ParseUint(param.Value, 10, 16)
-			port = uint(portInt)
+
+		run = &script{
+			Content:     params.Script,
+			Interpreter: interpreters[params.Interpreter],
+			Args:        params.ScriptArgs,
+			Env:         env,
+			Dir:         params.ScriptDir,
+			Shared:      params.Sudo && params.SudoUser != "" && params.SudoUser != "root",
 		}
-		if param.Key == "username" {
-			username = param.Value
+	}
+
+	var policies []commandPolicy
+	if params.Operation == OperationExec || params.Operation == OperationScript {
+		defaults := commandPolicy{
+			IgnoreErrors: params.IgnoreErrors,
+			Retries:      max(params.Retries, 0),
+			Delay:        params.RetryDelay,
+			Backoff:      params.RetryBackoff,
+		}
+
+		var errs sdk.ParamErrors
+		defaults.ExitCodes, err = parseExitCodes(params.AllowedExitCodes)
+		if err != nil {
+			errs = append(errs, sdk.ParamError{Key: "allowed_exit_codes", Title: "Allowed Exit Codes", Message: err.Error()})
 		}
-		if param.Key == "password" {
-			password = param.Value
+
+		commands := len(params.Commands)
+		if params.Operation == OperationScript {
+			commands = 1
 		}
-		if param.Key == "private_key_file" {
-			privateKeyFile = param.Value
+		policies, err = parseCommandPolicies(defaults, params.CommandRules, commands)
+		if err != nil {
+			errs = append(errs, sdk.ParamError{Key: "command_rules", Title: "Command Rules", Message: err.Error()})
 		}
-		if param.Key == "private_key_file_password" {
-			privateKeyFilePassword = param.Value
+
+		if len(errs) > 0 {
+			_ = reporter.InvalidParams(errs)
+			return plugins.Response{
+				Success: false,
+			}, errs
 		}
-		if param.Key == "use_ssh_agent" {
-			useSSHAgent = strings.ToLower(param.Value) == "true"
+	}
+
+	if params.Operation == OperationUpload || params.Operation == OperationDownload {
+		var errs sdk.ParamErrors
+		if params.LocalPath == "" {
+			errs = append(errs, sdk.ParamError{Key: "local_path", Title: "Local Path", Message: "is required for " + params.Operation})
 		}
-		if param.Key == "commands" {
-			commands = strings.Split(param.Value, "\n")
+		if params.RemotePath == "" {
+			errs = append(errs, sdk.ParamError{Key: "remote_path", Title: "Remote Path", Message: "is required for " + params.Operation})
 		}
-		if param.Key == "sudo" {
-			sudo = strings.ToLower(param.Value) == "true"
+		if len(errs) > 0 {
+			_ = reporter.InvalidParams(errs)
+			return plugins.Response{
+				Success: false,
+			}, errs
 		}
-		if param.Key == "sudo_password" {
-			sudoPassword = param.Value
+
+		if !filepath.IsAbs(params.LocalPath) {
+			params.LocalPath = filepath.Join(request.Workspace, params.LocalPath)
 		}
 	}
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Collecting Data",
-					Lines: []models.Line{
-						{
-							Content:   "Action canceled",
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status:     "canceled",
-			FinishedAt: time.Now(),
-		}, request.Platform)
-		if err != nil {
+		if err := reporter.Cancelled(ctx); err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
@@ -123,23 +217,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		return plugins.Response{Success: false, Canceled: true}, nil
 	}
 
-	err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
-		Messages: []models.Message{
-			{
-				Title: "SSH",
-				Lines: []models.Line{
-					{
-						Content:   "Starting ssh action",
-						Color:     "primary",
-						Timestamp: time.Now(),
-					},
-				},
-			},
-		},
-		Status:    "running",
-		StartedAt: time.Now(),
-	}, request.Platform)
+	err = reporter.Start("SSH", "Starting ssh action")
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -148,29 +226,55 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 
 	var auth goph.Auth
 
+	certificate, err := loadCertificate(params.Certificate, params.CertificateFile)
+	if err != nil {
+		_ = reporter.Fail("SSH", err, "Failed to load certificate file")
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
+	// use private key if provided
+	if params.PrivateKey != "" {
+		auth, err = keyAuth([]byte(params.PrivateKey), params.PrivateKeyPassword, certificate)
+		if err != nil {
+			_ = reporter.Fail("SSH", err, "Failed to load private key")
+			return plugins.Response{
+				Success: false,
+			}, err
+		}
+
+		err = reporter.Info("SSH", "Use private key to authenticate")
+		if err != nil {
+			return plugins.Response{
+				Success: false,
+			}, err
+		}
+	}
+
 	// use private key file if provided
-	if privateKeyFile != "" {
-		auth, err = goph.Key(privateKeyFile, ifEmpty(privateKeyFilePassword, ""))
+	if params.PrivateKeyFile != "" {
+		key, err := os.ReadFile(params.PrivateKeyFile)
+		if err == nil {
+			auth, err = keyAuth(key, params.PrivateKeyFilePassword, certificate)
+		}
 		if err != nil {
+			_ = reporter.Fail("SSH", err, "Failed to load private key file")
 			return plugins.Response{
 				Success: false,
 			}, err
 		}
 
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "SSH",
-					Lines: []models.Line{
-						{
-							Content:   "Use private key file to authenticate",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-		}, request.Platform)
+		err = reporter.Info("SSH", "Use private key file to authenticate")
+		if err != nil {
+			return plugins.Response{
+				Success: false,
+			}, err
+		}
+	}
+
+	if (params.PrivateKey != "" || params.PrivateKeyFile != "") && len(bytes.TrimSpace(certificate)) > 0 {
+		err = reporter.Info("SSH", "Present "+describeCert(certificate))
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -178,28 +282,16 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 	}
 
-	if useSSHAgent {
+	if params.UseSSHAgent {
 		auth, err = goph.UseAgent()
 		if err != nil {
+			_ = reporter.Fail("SSH", err, "Failed to connect to SSH agent")
 			return plugins.Response{
 				Success: false,
 			}, err
 		}
 
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "SSH",
-					Lines: []models.Line{
-						{
-							Content:   "Use SSH agent to authenticate",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-		}, request.Platform)
+		err = reporter.Info("SSH", "Use SSH agent to authenticate")
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -208,23 +300,10 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	// use password if provided
-	if password != "" {
-		auth = goph.Password(password)
-
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "SSH",
-					Lines: []models.Line{
-						{
-							Content:   "Use password to authenticate",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-		}, request.Platform)
+	if params.Password != "" {
+		auth = goph.Password(params.Password)
+
+		err = reporter.Info("SSH", "Use password to authenticate")
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -232,21 +311,9 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 	}
 
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
-		Messages: []models.Message{
-			{
-				Title: "SSH",
-				Lines: []models.Line{
-					{
-						Content:   "Connecting to remote server " + target + " as " + username,
-						Timestamp: time.Now(),
-					},
-				},
-			},
-		},
-	}, request.Platform)
+	hosts, err := parseTargets(params.Target, params.Port)
 	if err != nil {
+		_ = reporter.Fail("SSH", err, "Invalid target")
 		return plugins.Response{
 			Success: false,
 		}, err
@@ -254,24 +321,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Collecting Data",
-					Lines: []models.Line{
-						{
-							Content:   "Action canceled",
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status:     "canceled",
-			FinishedAt: time.Now(),
-		}, request.Platform)
-		if err != nil {
+		if err := reporter.Cancelled(ctx); err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
@@ -280,231 +330,102 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		return plugins.Response{Success: false, Canceled: true}, nil
 	}
 
-	client, err := goph.NewConn(&goph.Config{
-		User:     username,
-		Addr:     target,
-		Port:     port,
-		Auth:     auth,
-		Timeout:  goph.DefaultTimeout,
-		Callback: ssh.InsecureIgnoreHostKey(),
-	})
+	jumps, err := parseJumpHosts(params.JumpHosts, params.JumpHostPasswords, params.Username)
 	if err != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "SSH",
-					Lines: []models.Line{
-						{
-							Content:   "Failed to connect to remote server",
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-						{
-							Content:   err.Error(),
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status:     "error",
-			FinishedAt: time.Now(),
-		}, request.Platform)
+		_ = reporter.Fail("SSH", err, "Invalid jump host")
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
+	if params.HostKeyVerification == HostKeyInsecure {
+		err = reporter.Warn("SSH", "Host key verification is disabled, the identity of the server is not checked")
+		if err != nil {
+			return plugins.Response{
+				Success: false,
+			}, err
+		}
+	}
+
+	if len(hosts) > 1 {
+		err = reporter.Info("SSH", fmt.Sprintf("Running on %d hosts, %d at a time", len(hosts), max(params.Parallelism, 1)))
 		if err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
 		}
+	}
 
+	e := &executor{
+		params: params,
+		auth:   auth,
+		hostKeys: hostKeyConfig{
+			Mode:           params.HostKeyVerification,
+			KnownHostsFile: params.KnownHostsFile,
+			KnownHosts:     params.KnownHosts,
+			Fingerprints:   params.HostKeyFingerprints,
+			Store:          params.HostKeyStore,
+			OnTrust: func(host string, key ssh.PublicKey) {
+				_ = reporter.Warn("SSH", "Trusting new host key of "+host+": "+describeKey(key))
+			},
+		},
+		jumps:    jumps,
+		script:   run,
+		policies: policies,
+		reporter: reporter,
+	}
+	if params.ReuseConnection {
+		e.execution = request.Step.ExecutionID
+		e.fingerprint = authFingerprint(params)
+	}
+
+	out := summary(e.runAll(ctx, hosts))
+
+	data, err := reporter.Outputs(out)
+	if err != nil {
 		return plugins.Response{
 			Success: false,
 		}, err
 	}
 
-	// Defer closing the network connection.
-	defer client.Close()
-
-	for _, command := range commands {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "SSH",
-					Lines: []models.Line{
-						{
-							Content:   "-------------------------",
-							Timestamp: time.Now(),
-						},
-						{
-							Content:   "Executing command: " + command,
-							Color:     "primary",
-							Timestamp: time.Now(),
-						},
-						{
-							Content:   "-------------------------",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status: "running",
-		}, request.Platform)
-		if err != nil {
+	if ctx.Err() != nil {
+		if err := reporter.Cancelled(ctx); err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
 		}
 
-		// Execute your command.
-		var out []byte
-		if sudo {
-			out, err = client.RunContext(ctx, "echo "+sudoPassword+"| sudo -S "+command)
-		} else {
-			out, err = client.RunContext(ctx, command)
-		}
-		if err != nil && !strings.Contains(err.Error(), "context canceled") {
-			err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
-				Messages: []models.Message{
-					{
-						Title: "SSH",
-						Lines: []models.Line{
-							{
-								Content:   "Failed to execute command",
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-							{
-								Content:   err.Error(),
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-							{
-								Content:   "-------------------------",
-								Timestamp: time.Now(),
-							},
-							{
-								Content: "-------------------------",
-							},
-						},
-					},
-				},
-				Status:     "error",
-				FinishedAt: time.Now(),
-			}, request.Platform)
-			if err != nil {
-				return plugins.Response{
-					Success: false,
-				}, err
-			}
-
-			outputLines := strings.Split(string(out), "\n")
-			for _, line := range outputLines {
-				err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
-					Messages: []models.Message{
-						{
-							Title: "SSH",
-							Lines: []models.Line{
-								{
-									Content:   line,
-									Color:     "danger",
-									Timestamp: time.Now(),
-								},
-							},
-						},
-					},
-					Status: "error",
-				}, request.Platform)
-				if err != nil {
-					return plugins.Response{
-						Success: false,
-					}, err
-				}
-			}
+		return plugins.Response{Data: data, Success: false, Canceled: true}, nil
+	}
 
+	if len(hosts) > 1 {
+		err = reporter.Message("SSH", summaryLines(out)...)
+		if err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
 		}
+	}
 
-		outputLines := strings.Split(string(out), "\n")
-		for _, line := range outputLines {
-			err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
-				Messages: []models.Message{
-					{
-						Title: "SSH",
-						Lines: []models.Line{
-							{
-								Content:   line,
-								Timestamp: time.Now(),
-							},
-						},
-					},
-				},
-				Status: "running",
-			}, request.Platform)
-			if err != nil {
-				return plugins.Response{
-					Success: false,
-				}, err
-			}
-		}
-
-		// Check for cancellation before each major step
-		if ctx.Err() != nil {
-			err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
-				Messages: []models.Message{
-					{
-						Title: "Collecting Data",
-						Lines: []models.Line{
-							{
-								Content:   "Action canceled",
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-						},
-					},
-				},
-				Status:     "canceled",
-				FinishedAt: time.Now(),
-			}, request.Platform)
-			if err != nil {
-				return plugins.Response{
-					Success: false,
-				}, err
-			}
-
-			return plugins.Response{Success: false, Canceled: true}, nil
+	if out.Failed > 0 || out.Skipped > 0 {
+		err = errors.New(out.Hosts[0].Error)
+		if len(hosts) > 1 {
+			err = fmt.Errorf("%d of %d hosts failed, %d skipped", out.Failed, len(hosts), out.Skipped)
 		}
 
-		if err != nil {
+		if err := reporter.Finish(sdk.StatusError, "SSH", "SSH action failed"); err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
 		}
+
+		return plugins.Response{
+			Data:    data,
+			Success: false,
+		}, err
 	}
 
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
-		Messages: []models.Message{
-			{
-				Title: "SSH",
-				Lines: []models.Line{
-					{
-						Content:   "SSH action completed",
-						Color:     "success",
-						Timestamp: time.Now(),
-					},
-				},
-			},
-		},
-		Status:     "success",
-		FinishedAt: time.Now(),
-	}, request.Platform)
+	err = reporter.Finish(sdk.StatusSuccess, "SSH", "SSH action completed")
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -512,37 +433,24 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	return plugins.Response{
+		Data:    data,
 		Success: true,
 	}, nil
 }
 
 func (p *Plugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
-	stepID := request.Step.ID.String()
-	taskCancelsMu.Lock()
-	cancel, ok := taskCancels[stepID]
-	taskCancelsMu.Unlock()
-
-	if !ok {
-		return plugins.Response{
-			Success: false,
-		}, errors.New("task not found")
-	}
-
-	cancel()
-	return plugins.Response{Success: true}, nil
-}
-
-func (p *Plugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
+	// cancel the step first, so it stops using its connections before they
+	// are closed under it
+	response, err := tasks.Cancel(request)
+	pool.closeExecution(request.Step.ExecutionID)
+	return response, err
 }
 
 func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	var plugin = models.Plugin{
 		Name:    "SSH",
 		Type:    "action",
-		Version: "1.5.4",
+		Version: "1.6.0",
 		Author:  "JustNZ",
 		Action: models.Action{
 			Name:        "SSH",
@@ -550,146 +458,7 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 			Plugin:      "ssh",
 			Icon:        "hugeicons:server-stack-03",
 			Category:    "Utility",
-			Params: []models.Params{
-				{
-					Key:         "target",
-					Title:       "Target",
-					Type:        "text",
-					Default:     "",
-					Required:    true,
-					Description: "The target server IP address or hostname",
-					Category:    "Destination",
-				},
-				{
-					Key:         "port",
-					Title:       "Port",
-					Type:        "number",
-					Default:     "22",
-					Required:    true,
-					Description: "The target server port",
-					Category:    "Destination",
-				},
-				{
-					Key:         "authentication_method",
-					Title:       "Authentication Method",
-					Type:        "select",
-					Default:     "password",
-					Required:    true,
-					Description: "The authentication method to use",
-					Options: []models.Option{
-						{
-							Key:   "password",
-							Value: "Password",
-						},
-						{
-							Key:   "private_key_file",
-							Value: "Private Key File",
-						},
-						{
-							Key:   "ssh_agent",
-							Value: "SSH Agent",
-						},
-					},
-					Category: "Credentials",
-				},
-				{
-					Key:         "username",
-					Title:       "Username",
-					Type:        "text",
-					Default:     "",
-					Required:    true,
-					Description: "The username to authenticate with",
-					Category:    "Credentials",
-					DependsOn: models.DependsOn{
-						Key:   "authentication_method",
-						Value: "password",
-					},
-				},
-				{
-					Key:         "password",
-					Title:       "Password",
-					Type:        "password",
-					Default:     "",
-					Required:    false,
-					Description: "The password to authenticate with",
-					Category:    "Credentials",
-					DependsOn: models.DependsOn{
-						Key:   "authentication_method",
-						Value: "password",
-					},
-				},
-				{
-					Key:         "private_key_file",
-					Title:       "Private Key File",
-					Type:        "text",
-					Default:     "",
-					Required:    false,
-					Description: "The private key file path to authenticate with. This path must be accessible by the runner",
-					Options:     nil,
-					Category:    "Credentials",
-					DependsOn: models.DependsOn{
-						Key:   "authentication_method",
-						Value: "private_key_file",
-					},
-				},
-				{
-					Key:         "private_key_file_password",
-					Title:       "Private Key File Password",
-					Type:        "password",
-					Default:     "",
-					Required:    false,
-					Description: "The password to decrypt the private key file",
-					Category:    "Credentials",
-					DependsOn: models.DependsOn{
-						Key:   "authentication_method",
-						Value: "private_key_file",
-					},
-				},
-				{
-					Key:         "use_ssh_agent",
-					Title:       "Use SSH Agent",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    false,
-					Description: "Use the SSH agent to authenticate",
-					Category:    "Credentials",
-					DependsOn: models.DependsOn{
-						Key:   "authentication_method",
-						Value: "ssh_agent",
-					},
-				},
-				{
-					Key:         "sudo",
-					Title:       "Use Sudo",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    true,
-					Description: "Use sudo to execute the commands",
-					Category:    "Privileges",
-				},
-				{
-					Key:         "sudo_password",
-					Title:       "Sudo Password",
-					Type:        "password",
-					Default:     "",
-					Required:    false,
-					Description: "The password to authenticate with sudo",
-					Category:    "Privileges",
-					DependsOn: models.DependsOn{
-						Key:   "sudo",
-						Value: "true",
-					},
-				},
-				{
-					Key:         "commands",
-					Title:       "Commands",
-					Type:        "textarea",
-					Default:     "",
-					Required:    true,
-					Description: "The commands to execute on the remote server. Each command should be on a new line",
-					Category:    "Commands",
-				},
-			},
+			Params:      append(sdk.ParamSchema(Params{}), sdk.TimeoutParam()),
 		},
 		Endpoint: models.Endpoint{},
 	}
@@ -697,58 +466,6 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	return plugin, nil
 }
 
-// PluginRPCServer is the RPC server for Plugin
-type PluginRPCServer struct {
-	Impl plugins.Plugin
-}
-
-func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.ExecuteTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.CancelTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
-	result, err := s.Impl.EndpointRequest(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *models.Plugin) error {
-	result, err := s.Impl.Info(request)
-	*resp = result
-	return err
-}
-
-// PluginServer is the implementation of plugin.Plugin interface
-type PluginServer struct {
-	Impl plugins.Plugin
-}
-
-func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
-}
-
-func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &plugins.PluginRPC{Client: c}, nil
-}
-
 func main() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: plugin.HandshakeConfig{
-			ProtocolVersion:  1,
-			MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
-			MagicCookieValue: "hello",
-		},
-		Plugins: map[string]plugin.Plugin{
-			"plugin": &PluginServer{Impl: &Plugin{}},
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
-	})
+	sdk.Serve(&Plugin{})
 }
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/v1Flows/runner-plugins/sdk"
+	"golang.org/x/crypto/ssh"
+)
+
+// Backoffs of the retry_backoff param
+const (
+	BackoffConstant    = "constant"
+	BackoffExponential = "exponential"
+)
+
+// maxRetryDelay caps the exponential backoff between two attempts
+const maxRetryDelay = 5 * time.Minute
+
+// commandPolicy decides whether a command succeeded and how often it is tried
+type commandPolicy struct {
+	ExitCodes    []int
+	IgnoreErrors bool
+	Retries      int
+	Delay        time.Duration
+	Backoff      string
+	// Until is matched against stdout and stderr, a command whose output does
+	// not match is retried like a failed command
+	Until *regexp.Regexp
+}
+
+// parseCommandPolicies returns the policy of each of the commands: the
+// defaults, overridden by the rules of the command_rules param. A rule is
+// "<n>: option...", n being the number of the command starting at 1. Options
+// are exit_codes=0,1, ignore_errors, retries=<n>, delay=<duration>,
+// backoff=constant|exponential and until=<regex>, which takes the rest of the
+// line so the regex may contain spaces.
+func parseCommandPolicies(defaults commandPolicy, rules []string, commands int) ([]commandPolicy, error) {
+	policies := make([]commandPolicy, commands)
+	for i := range policies {
+		policies[i] = defaults
+	}
+
+	for _, rule := range rules {
+		number, options, ok := strings.Cut(rule, ":")
+		n, err := strconv.Atoi(strings.TrimSpace(number))
+		if !ok || err != nil {
+			return nil, fmt.Errorf("rule %q does not start with the number of a command", rule)
+		}
+		if n < 1 || n > commands {
+			return nil, fmt.Errorf("rule %q is for command %d, but there are %d commands", rule, n, commands)
+		}
+
+		if err := policies[n-1].apply(options); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule, err)
+		}
+	}
+
+	return policies, nil
+}
+
+// apply sets the options of a rule
+func (p *commandPolicy) apply(options string) error {
+	options = strings.TrimSpace(options)
+	if before, until, ok := cutUntil(options); ok {
+		re, err := regexp.Compile(until)
+		if err != nil {
+			return fmt.Errorf("invalid until regex: %w", err)
+		}
+		p.Until = re
+		options = before
+	}
+
+	for _, option := range strings.Fields(options) {
+		key, value, _ := strings.Cut(option, "=")
+
+		var err error
+		switch key {
+		case "exit_codes":
+			p.ExitCodes, err = parseExitCodes(value)
+		case "ignore_errors":
+			p.IgnoreErrors = true
+			if value != "" {
+				p.IgnoreErrors, err = strconv.ParseBool(value)
+			}
+		case "retries":
+			p.Retries, err = strconv.Atoi(value)
+			if err == nil && p.Retries < 0 {
+				err = fmt.Errorf("retries must not be negative")
+			}
+		case "delay":
+			p.Delay, err = time.ParseDuration(value)
+		case "backoff":
+			if value != BackoffConstant && value != BackoffExponential {
+				err = fmt.Errorf("backoff must be %s or %s", BackoffConstant, BackoffExponential)
+			}
+			p.Backoff = value
+		default:
+			err = fmt.Errorf("unknown option %q", key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cutUntil splits options at an until= option starting a word
+func cutUntil(options string) (string, string, bool) {
+	for i := 0; i < len(options); i++ {
+		if strings.HasPrefix(options[i:], "until=") && (i == 0 || options[i-1] == ' ' || options[i-1] == '\t') {
+			return options[:i], options[i+len("until="):], true
+		}
+	}
+	return options, "", false
+}
+
+// parseExitCodes parses exit codes separated by commas
+func parseExitCodes(value string) ([]int, error) {
+	var codes []int
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		code, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an exit code", field)
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("no exit code given")
+	}
+	return codes, nil
+}
+
+// check returns why the result of an attempt is not a success, nil if it is
+func (p commandPolicy) check(result CommandOutput, err error) error {
+	var exitErr *ssh.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		// the command did not report an exit status
+		return err
+	}
+
+	allowed := false
+	for _, code := range p.ExitCodes {
+		if code == result.ExitCode {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("exited with code %d, allowed are %s", result.ExitCode, formatExitCodes(p.ExitCodes))
+	}
+
+	if p.Until != nil && !p.Until.MatchString(result.Stdout) && !p.Until.MatchString(result.Stderr) {
+		return fmt.Errorf("output does not match %q", p.Until.String())
+	}
+
+	return nil
+}
+
+// delay returns how long to wait before the attempt after attempt
+func (p commandPolicy) delay(attempt int) time.Duration {
+	if p.Backoff != BackoffExponential {
+		return p.Delay
+	}
+
+	delay := p.Delay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// run runs attempt until its result passes check, at most 1+Retries times. The
+// last failure is returned unless errors are ignored, in which case the result
+// is marked as ignored.
+func (p commandPolicy) run(ctx context.Context, output *sdk.LineSink, attempt func() (CommandOutput, error)) (CommandOutput, error) {
+	for n := 1; ; n++ {
+		result, err := attempt()
+		result.Attempts = n
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		err = p.check(result, err)
+		if err == nil {
+			return result, nil
+		}
+
+		if n > p.Retries {
+			if p.IgnoreErrors {
+				_ = output.Add("Ignoring error: "+err.Error(), sdk.ColorWarning)
+				result.Ignored = true
+				return result, nil
+			}
+			return result, err
+		}
+
+		delay := p.delay(n)
+		_ = output.Add(fmt.Sprintf("Attempt %d of %d failed: %s. Retrying in %s", n, p.Retries+1, err, delay), sdk.ColorWarning)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return result, ctx.Err()
+		}
+	}
+}
+
+func formatExitCodes(codes []int) string {
+	s := make([]string, 0, len(codes))
+	for _, code := range codes {
+		s = append(s, strconv.Itoa(code))
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestParseCommandPolicies(t *testing.T) {
+	defaults := commandPolicy{ExitCodes: []int{0}, Backoff: BackoffConstant}
+
+	tests := []struct {
+		name    string
+		rules   []string
+		want    []commandPolicy
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			want: []commandPolicy{defaults, defaults},
+		},
+		{
+			name:  "options",
+			rules: []string{"2: exit_codes=0,1 ignore_errors retries=3 delay=2s backoff=exponential"},
+			want: []commandPolicy{defaults, {
+				ExitCodes:    []int{0, 1},
+				IgnoreErrors: true,
+				Retries:      3,
+				Delay:        2 * time.Second,
+				Backoff:      BackoffExponential,
+			}},
+		},
+		{
+			name:  "later rules override earlier ones",
+			rules: []string{"1: retries=1 ignore_errors", " 1 : retries=2 ignore_errors=false"},
+			want:  []commandPolicy{{ExitCodes: []int{0}, Retries: 2, Backoff: BackoffConstant}, defaults},
+		},
+		{
+			name:  "until takes the rest of the line",
+			rules: []string{"1: retries=5 until=ready: [0-9]+ nodes"},
+			want:  []commandPolicy{{ExitCodes: []int{0}, Retries: 5, Backoff: BackoffConstant, Until: regexp.MustCompile("ready: [0-9]+ nodes")}, defaults},
+		},
+		{
+			name:  "until inside a word is not an option",
+			rules: []string{"1: until=a until=b"},
+			want:  []commandPolicy{{ExitCodes: []int{0}, Backoff: BackoffConstant, Until: regexp.MustCompile("a until=b")}, defaults},
+		},
+		{name: "no number", rules: []string{"retries=1"}, wantErr: "does not start with the number of a command"},
+		{name: "command 0", rules: []string{"0: retries=1"}, wantErr: "is for command 0, but there are 2 commands"},
+		{name: "command out of range", rules: []string{"3: retries=1"}, wantErr: "is for command 3, but there are 2 commands"},
+		{name: "unknown option", rules: []string{"1: timeout=5s"}, wantErr: `unknown option "timeout"`},
+		{name: "negative retries", rules: []string{"1: retries=-1"}, wantErr: "retries must not be negative"},
+		{name: "invalid delay", rules: []string{"1: delay=5"}, wantErr: "missing unit"},
+		{name: "invalid backoff", rules: []string{"1: backoff=linear"}, wantErr: "backoff must be constant or exponential"},
+		{name: "invalid exit code", rules: []string{"1: exit_codes=0,x"}, wantErr: `"x" is not an exit code`},
+		{name: "no exit code", rules: []string{"1: exit_codes="}, wantErr: "no exit code given"},
+		{name: "invalid until", rules: []string{"1: until=("}, wantErr: "invalid until regex"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCommandPolicies(defaults, tt.rules, 2)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseCommandPolicies() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCommandPolicies() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCommandPolicies() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandPolicyCheck(t *testing.T) {
+	exitErr := &ssh.ExitError{}
+	connErr := errors.New("connection lost")
+
+	tests := []struct {
+		name    string
+		policy  commandPolicy
+		result  CommandOutput
+		err     error
+		wantErr string
+	}{
+		{name: "success", policy: commandPolicy{ExitCodes: []int{0}}, result: CommandOutput{ExitCode: 0}},
+		{name: "exit code not allowed", policy: commandPolicy{ExitCodes: []int{0}}, result: CommandOutput{ExitCode: 2}, err: exitErr, wantErr: "exited with code 2, allowed are 0"},
+		{name: "allowed exit code", policy: commandPolicy{ExitCodes: []int{0, 2}}, result: CommandOutput{ExitCode: 2}, err: exitErr},
+		{name: "no exit status", policy: commandPolicy{ExitCodes: []int{-1}}, result: CommandOutput{ExitCode: -1}, err: connErr, wantErr: "connection lost"},
+		{name: "until matches stdout", policy: commandPolicy{ExitCodes: []int{0}, Until: regexp.MustCompile("^ready$")}, result: CommandOutput{Stdout: "ready"}},
+		{name: "until matches stderr", policy: commandPolicy{ExitCodes: []int{0}, Until: regexp.MustCompile("ready")}, result: CommandOutput{Stderr: "already ready"}},
+		{name: "until does not match", policy: commandPolicy{ExitCodes: []int{0}, Until: regexp.MustCompile("^ready$")}, result: CommandOutput{Stdout: "starting"}, wantErr: `output does not match "^ready$"`},
+		{name: "exit code before until", policy: commandPolicy{ExitCodes: []int{0}, Until: regexp.MustCompile("ready")}, result: CommandOutput{ExitCode: 1, Stdout: "ready"}, err: exitErr, wantErr: "exited with code 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.check(tt.result, tt.err)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("check() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("check() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCommandPolicyDelay(t *testing.T) {
+	tests := []struct {
+		backoff string
+		delay   time.Duration
+		attempt int
+		want    time.Duration
+	}{
+		{backoff: BackoffConstant, delay: time.Second, attempt: 1, want: time.Second},
+		{backoff: BackoffConstant, delay: time.Second, attempt: 5, want: time.Second},
+		{backoff: BackoffExponential, delay: time.Second, attempt: 1, want: time.Second},
+		{backoff: BackoffExponential, delay: time.Second, attempt: 4, want: 8 * time.Second},
+		{backoff: BackoffExponential, delay: time.Minute, attempt: 10, want: maxRetryDelay},
+		{backoff: BackoffExponential, delay: 0, attempt: 3, want: 0},
+	}
+
+	for _, tt := range tests {
+		p := commandPolicy{Backoff: tt.backoff, Delay: tt.delay}
+		if got := p.delay(tt.attempt); got != tt.want {
+			t.Errorf("%s delay(%d) with %s = %v, want %v", tt.backoff, tt.attempt, tt.delay, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/melbahja/goph"
+	"github.com/v1Flows/runner/pkg/plugins"
+)
+
+// keepaliveTimeout bounds the check of a pooled connection before it is
+// reused, a server that silently dropped it would block the step otherwise
+var keepaliveTimeout = 5 * time.Second
+
+// poolKey identifies a connection that steps of the same execution can share.
+// Auth is a fingerprint of everything that decides how the connection was
+// opened, the credentials never end up in the pool.
+type poolKey struct {
+	Execution string
+	Address   string
+	User      string
+	Auth      string
+}
+
+// pooledConn is a connection kept open for the following steps of an execution
+type pooledConn struct {
+	client *goph.Client
+	users  int
+	idle   *time.Timer
+	// closing is set when the execution ended or the connection died while
+	// it was in use, the last user closes it
+	closing bool
+}
+
+// connPool keeps ssh connections open between the steps of an execution, so
+// consecutive steps on the same host do not repeat the handshake. Connections
+// are closed once they were idle for the idle timeout of the step that used
+// them last, when the final step of the execution completes or when the
+// execution is canceled.
+type connPool struct {
+	mu    sync.Mutex
+	conns map[poolKey]*pooledConn
+}
+
+var pool = &connPool{conns: make(map[poolKey]*pooledConn)}
+
+// acquire returns the pooled connection of key, or opens a new one with dial.
+// reused tells whether the connection was already open. release must be called
+// once the step is done with the connection, it keeps the connection open for
+// idle.
+func (p *connPool) acquire(key poolKey, idle time.Duration, dial func() (*goph.Client, error)) (client *goph.Client, release func(), reused bool, err error) {
+	p.mu.Lock()
+	conn, ok := p.conns[key]
+	if ok && !conn.closing {
+		conn.users++
+		conn.idle.Stop()
+		p.mu.Unlock()
+
+		// the server may have dropped the connection while it was idle
+		if keepalive(conn.client) == nil {
+			return conn.client, p.releaser(key, conn, idle), true, nil
+		}
+
+		// other steps may still hold the dead connection, it is closed by
+		// the last of them and new steps dial a fresh one
+		p.mu.Lock()
+		conn.users--
+		conn.closing = true
+		if p.conns[key] == conn {
+			delete(p.conns, key)
+		}
+		if conn.users == 0 {
+			p.removeLocked(key, conn)
+		}
+	}
+	p.mu.Unlock()
+
+	client, err = dial()
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	conn = &pooledConn{client: client, users: 1}
+	conn.idle = time.AfterFunc(idle, func() { p.expire(key, conn) })
+	conn.idle.Stop()
+
+	p.mu.Lock()
+	if _, ok := p.conns[key]; ok {
+		// a parallel step pooled the same connection first, this one is
+		// closed after the step
+		p.mu.Unlock()
+		return client, func() { _ = client.Close() }, false, nil
+	}
+	p.conns[key] = conn
+	p.mu.Unlock()
+
+	return client, p.releaser(key, conn, idle), false, nil
+}
+
+// keepalive checks that client is still connected, within keepaliveTimeout
+func keepalive(client *goph.Client) error {
+	result := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(keepaliveTimeout):
+		return errors.New("keepalive timed out")
+	}
+}
+
+func (p *connPool) releaser(key poolKey, conn *pooledConn, idle time.Duration) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			conn.users--
+			if conn.users > 0 {
+				return
+			}
+			if conn.closing {
+				p.removeLocked(key, conn)
+				return
+			}
+			conn.idle.Reset(idle)
+		})
+	}
+}
+
+// expire closes conn once its idle timeout passed without another user
+func (p *connPool) expire(key poolKey, conn *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if conn.users == 0 {
+		p.removeLocked(key, conn)
+	}
+}
+
+// closeExecution closes the connections of execution. Connections still in use
+// are closed when their step releases them.
+func (p *connPool) closeExecution(execution string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, conn := range p.conns {
+		if key.Execution != execution {
+			continue
+		}
+		if conn.users > 0 {
+			conn.closing = true
+			continue
+		}
+		p.removeLocked(key, conn)
+	}
+}
+
+// isFinalStep tells whether the step of request runs the last active action of
+// its flow, no later step of the execution can reuse its connections then.
+func isFinalStep(request plugins.ExecuteTaskRequest) bool {
+	for i := len(request.Flow.Actions) - 1; i >= 0; i-- {
+		action := request.Flow.Actions[i]
+		if action.Active {
+			return action.ID == request.Step.Action.ID
+		}
+	}
+	return false
+}
+
+func (p *connPool) removeLocked(key poolKey, conn *pooledConn) {
+	conn.idle.Stop()
+	if p.conns[key] == conn {
+		delete(p.conns, key)
+	}
+	_ = conn.client.Close()
+}
+
+// authFingerprint hashes the parts of the params that decide how a connection
+// is opened: credentials, jump hosts and host key verification. Steps only
+// share a connection if all of them are equal.
+func authFingerprint(params Params) string {
+	parts := []string{
+		params.AuthenticationMethod,
+		params.Password,
+		params.PrivateKey,
+		params.PrivateKeyPassword,
+		params.PrivateKeyFile,
+		params.PrivateKeyFilePassword,
+		params.Certificate,
+		params.CertificateFile,
+		strings.Join(params.JumpHosts, "\n"),
+		params.JumpHostPasswords,
+		params.HostKeyVerification,
+		params.KnownHostsFile,
+		params.KnownHosts,
+		strings.Join(params.HostKeyFingerprints, "\n"),
+		params.HostKeyStore,
+	}
+
+	h := sha256.New()
+	for _, part := range parts {
+		// the length prefix keeps "ab"+"c" apart from "a"+"bc"
+		_ = binary.Write(h, binary.BigEndian, uint32(len(part)))
+		h.Write([]byte(part))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/melbahja/goph"
+	"github.com/v1Flows/runner/pkg/plugins"
+	"github.com/v1Flows/shared-library/pkg/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an in-process ssh server accepting any client and answering
+// keepalives
+type testServer struct {
+	listener net.Listener
+	dials    atomic.Int32
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	hostKey, _ := newPrivateKey(t, "")
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{listener: listener}
+	t.Cleanup(func() {
+		_ = listener.Close()
+		s.drop()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for ch := range chans {
+					_ = ch.Reject(ssh.Prohibited, "no channels")
+				}
+			}()
+		}
+	}()
+
+	return s
+}
+
+// dial opens a new client connection to the server
+func (s *testServer) dial() (*goph.Client, error) {
+	s.dials.Add(1)
+	client, err := ssh.Dial("tcp", s.listener.Addr().String(), &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &goph.Client{Client: client}, nil
+}
+
+// drop closes all connections on the server side
+func (s *testServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+func newTestPool() *connPool {
+	return &connPool{conns: make(map[poolKey]*pooledConn)}
+}
+
+// closed tells whether the client connection was closed
+func closed(client *goph.Client) bool {
+	_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+	return err != nil
+}
+
+func TestPoolReuse(t *testing.T) {
+	server := newTestServer(t)
+	p := newTestPool()
+	key := poolKey{Execution: "exec", Address: "web1:22", User: "root", Auth: "a"}
+
+	first, release, reused, err := p.acquire(key, time.Minute, server.dial)
+	if err != nil || reused {
+		t.Fatalf("acquire() reused = %v, error = %v, want a new connection", reused, err)
+	}
+	release()
+	release() // releasing twice is harmless
+
+	second, release, reused, err := p.acquire(key, time.Minute, server.dial)
+	if err != nil || !reused || second != first {
+		t.Fatalf("acquire() reused = %v, error = %v, want the pooled connection", reused, err)
+	}
+
+	// parallel steps share the connection
+	third, releaseThird, reused, err := p.acquire(key, time.Minute, server.dial)
+	if err != nil || !reused || third != first {
+		t.Fatalf("parallel acquire() reused = %v, error = %v, want the pooled connection", reused, err)
+	}
+	release()
+	releaseThird()
+
+	// other users, executions or credentials get their own connection
+	for _, other := range []poolKey{
+		{Execution: "exec", Address: "web1:22", User: "deploy", Auth: "a"},
+		{Execution: "exec", Address: "web1:22", User: "root", Auth: "b"},
+		{Execution: "other", Address: "web1:22", User: "root", Auth: "a"},
+	} {
+		client, release, reused, err := p.acquire(other, time.Minute, server.dial)
+		if err != nil || reused || client == first {
+			t.Errorf("acquire(%+v) reused = %v, error = %v, want a new connection", other, reused, err)
+		}
+		release()
+	}
+
+	if got := server.dials.Load(); got != 4 {
+		t.Errorf("dialed %d times, want 4", got)
+	}
+}
+
+func TestPoolIdleTimeout(t *testing.T) {
+	server := newTestServer(t)
+	p := newTestPool()
+	key := poolKey{Execution: "exec", Address: "web1:22"}
+
+	client, release, _, err := p.acquire(key, 20*time.Millisecond, server.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the idle timeout only runs once the connection is released
+	time.Sleep(50 * time.Millisecond)
+	if closed(client) {
+		t.Fatal("connection in use was closed after the idle timeout")
+	}
+	release()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !closed(client) {
+		if time.Now().After(deadline) {
+			t.Fatal("idle connection was not closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.conns) != 0 {
+		t.Errorf("pool still holds %d connections", len(p.conns))
+	}
+}
+
+func TestPoolEvictsDeadConnection(t *testing.T) {
+	server := newTestServer(t)
+	p := newTestPool()
+	key := poolKey{Execution: "exec", Address: "web1:22"}
+
+	dead, release, _, err := p.acquire(key, time.Minute, server.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a parallel step still holds the connection when it dies
+	_, releaseHolder, _, err := p.acquire(key, time.Minute, server.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	server.drop()
+
+	client, release, reused, err := p.acquire(key, time.Minute, server.dial)
+	if err != nil || reused || client == dead {
+		t.Fatalf("acquire() reused = %v, error = %v, want a new connection", reused, err)
+	}
+	defer release()
+	if closed(client) {
+		t.Error("new connection is closed")
+	}
+
+	// the holder of the dead connection releases it without touching the
+	// new one
+	releaseHolder()
+	p.mu.Lock()
+	if p.conns[key] == nil || p.conns[key].client != client {
+		t.Error("new connection is not pooled")
+	}
+	p.mu.Unlock()
+	if closed(client) {
+		t.Error("new connection was closed with the dead one")
+	}
+	if got := server.dials.Load(); got != 2 {
+		t.Errorf("dialed %d times, want 2", got)
+	}
+}
+
+func TestPoolCloseExecution(t *testing.T) {
+	server := newTestServer(t)
+	p := newTestPool()
+	idleKey := poolKey{Execution: "exec", Address: "web1:22"}
+	busyKey := poolKey{Execution: "exec", Address: "web2:22"}
+	otherKey := poolKey{Execution: "other", Address: "web1:22"}
+
+	idle, release, _, err := p.acquire(idleKey, time.Minute, server.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	other, release, _, err := p.acquire(otherKey, time.Minute, server.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	busy, releaseBusy, _, err := p.acquire(busyKey, time.Minute, server.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the final step of the execution completed
+	p.closeExecution("exec")
+
+	if !closed(idle) {
+		t.Error("idle connection of the execution was not closed")
+	}
+	if closed(other) {
+		t.Error("connection of another execution was closed")
+	}
+	if closed(busy) {
+		t.Fatal("connection in use was closed under its step")
+	}
+
+	// a closing connection is not handed out again
+	client, release, reused, err := p.acquire(busyKey, time.Minute, server.dial)
+	if err != nil || reused || client == busy {
+		t.Fatalf("acquire() reused = %v, error = %v, want a new connection", reused, err)
+	}
+	release()
+
+	releaseBusy()
+	if !closed(busy) {
+		t.Error("connection in use was not closed when its step released it")
+	}
+}
+
+func TestIsFinalStep(t *testing.T) {
+	first, last, inactive := uuid.New(), uuid.New(), uuid.New()
+	actions := []models.Action{
+		{ID: first, Active: true},
+		{ID: last, Active: true},
+		{ID: inactive, Active: false},
+	}
+
+	tests := []struct {
+		name    string
+		actions []models.Action
+		step    uuid.UUID
+		want    bool
+	}{
+		{name: "first step", actions: actions, step: first, want: false},
+		{name: "last active step", actions: actions, step: last, want: true},
+		{name: "inactive action is skipped", actions: actions, step: inactive, want: false},
+		{name: "no actions", step: first, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := plugins.ExecuteTaskRequest{
+				Flow: models.Flows{Actions: tt.actions},
+				Step: models.ExecutionSteps{Action: models.Action{ID: tt.step}},
+			}
+			if got := isFinalStep(request); got != tt.want {
+				t.Errorf("isFinalStep() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
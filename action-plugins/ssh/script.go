@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/melbahja/goph"
+	"github.com/v1Flows/runner-plugins/sdk"
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+// OperationScript uploads the script param and runs it with an interpreter
+const OperationScript = "script"
+
+// interpreter runs an uploaded script. Some interpreters only accept scripts
+// with their own file extension.
+type interpreter struct {
+	Command   []string
+	Extension string
+}
+
+// interpreters are the options of the interpreter param
+var interpreters = map[string]interpreter{
+	"sh":      {Command: []string{"sh"}, Extension: ".sh"},
+	"bash":    {Command: []string{"bash"}, Extension: ".sh"},
+	"python3": {Command: []string{"python3"}, Extension: ".py"},
+	"pwsh":    {Command: []string{"pwsh", "-NoLogo", "-NoProfile", "-NonInteractive", "-File"}, Extension: ".ps1"},
+}
+
+// envName matches the names a POSIX shell accepts for environment variables
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseScriptEnv turns the NAME=value lines of the script_env param into
+// shell quoted assignments
+func parseScriptEnv(lines []string) ([]string, error) {
+	var assignments []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !envName.MatchString(name) {
+			return nil, fmt.Errorf("%q is not a NAME=value line", line)
+		}
+
+		assignments = append(assignments, name+"="+shellQuote(value))
+	}
+
+	return assignments, nil
+}
+
+// script is the script of the action prepared for a run
+type script struct {
+	Content     string
+	Interpreter interpreter
+	Args        []string
+	Env         []string
+	Dir         string
+	// Shared makes the uploaded script readable by other users, required
+	// when sudo runs it as a user other than root
+	Shared bool
+}
+
+// command returns the command line running the script uploaded to remote
+func (s *script) command(remote string) string {
+	args := append([]string{}, s.Interpreter.Command...)
+	args = append(args, shellQuote(remote))
+	for _, arg := range s.Args {
+		args = append(args, shellQuote(arg))
+	}
+
+	if len(s.Env) > 0 {
+		args = append(append([]string{"env"}, s.Env...), args...)
+	}
+
+	return strings.Join(args, " ")
+}
+
+// display returns the command line shown in the step and the outputs. It
+// leaves out the environment, the values are not meant for the log.
+func (s *script) display(remote string) string {
+	args := append([]string{}, s.Interpreter.Command...)
+	args = append(args, remote)
+	args = append(args, s.Args...)
+	return strings.Join(args, " ")
+}
+
+// runScript uploads the script to a temporary file on the host, runs it and
+// removes the file again, also when the run failed or was canceled
+func (e *executor) runScript(ctx context.Context, client *goph.Client, s *script, output *sdk.LineSink) (CommandOutput, error) {
+	result := CommandOutput{ExitCode: -1}
+
+	sftpClient, err := client.NewSftp()
+	if err != nil {
+		return result, fmt.Errorf("failed to start sftp: %w", err)
+	}
+	defer sftpClient.Close()
+
+	name := make([]byte, 8)
+	_, _ = rand.Read(name)
+	remote := path.Join(s.Dir, "v1flows-script-"+hex.EncodeToString(name)+s.Interpreter.Extension)
+	result.Command = s.display(remote)
+
+	file, err := sftpClient.OpenFile(remote, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return result, fmt.Errorf("failed to create %s: %w", remote, err)
+	}
+	defer func() {
+		if err := sftpClient.Remove(remote); err != nil {
+			_ = output.Add("Failed to remove "+remote+": "+err.Error(), sdk.ColorWarning)
+		}
+	}()
+
+	mode := os.FileMode(0700)
+	if s.Shared {
+		mode = 0755
+	}
+	if err := file.Chmod(mode); err != nil {
+		_ = file.Close()
+		return result, fmt.Errorf("failed to set the mode of %s: %w", remote, err)
+	}
+	if _, err := file.Write([]byte(s.Content)); err != nil {
+		_ = file.Close()
+		return result, fmt.Errorf("failed to upload the script: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return result, fmt.Errorf("failed to upload the script: %w", err)
+	}
+
+	_ = output.AddLine(models.Line{Content: "-------------------------"})
+	_ = output.AddLine(models.Line{Content: "Executing script: " + result.Command, Color: sdk.ColorPrimary})
+	_ = output.AddLine(models.Line{Content: "-------------------------"})
+
+	commandResult, err := e.policies[0].run(ctx, output, func() (CommandOutput, error) {
+		commandResult, err := runCommand(ctx, client, s.command(remote), e.sudo(), output)
+		if commandResult.ExitCode >= 0 {
+			_ = output.Add(fmt.Sprintf("Script exited with code %d", commandResult.ExitCode), "")
+		}
+		return commandResult, err
+	})
+	commandResult.Command = result.Command
+
+	return commandResult, err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseScriptEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", lines: []string{"", "  "}, want: nil},
+		{name: "assignments", lines: []string{"A=1", " _B2 =two words", "C="}, want: []string{"A='1'", "_B2='two words'", "C=''"}},
+		{name: "value with equals and quotes", lines: []string{"Q=a=b 'c'"}, want: []string{`Q='a=b '\''c'\'''`}},
+		{name: "value keeps spaces", lines: []string{"S= x "}, want: []string{"S=' x '"}},
+		{name: "missing equals", lines: []string{"A"}, wantErr: true},
+		{name: "empty name", lines: []string{"=1"}, wantErr: true},
+		{name: "name starting with a digit", lines: []string{"1A=1"}, wantErr: true},
+		{name: "name with a dash", lines: []string{"MY-VAR=1"}, wantErr: true},
+		{name: "command substitution", lines: []string{"$(id)=1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseScriptEnv(tt.lines)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseScriptEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseScriptEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptCommand(t *testing.T) {
+	s := &script{
+		Interpreter: interpreters["pwsh"],
+		Args:        []string{"-Name", "it's"},
+		Env:         []string{"TOKEN='secret'"},
+	}
+
+	if got, want := s.command("/tmp/x.ps1"), `env TOKEN='secret' pwsh -NoLogo -NoProfile -NonInteractive -File '/tmp/x.ps1' '-Name' 'it'\''s'`; got != want {
+		t.Errorf("command() = %q, want %q", got, want)
+	}
+	if got, want := s.display("/tmp/x.ps1"), "pwsh -NoLogo -NoProfile -NonInteractive -File /tmp/x.ps1 -Name it's"; got != want {
+		t.Errorf("display() = %q, want %q", got, want)
+	}
+}
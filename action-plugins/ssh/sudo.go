@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"strings"
+	"sync"
+)
+
+// sudo runs commands through sudo. The password is never part of the command
+// line, it is written to the stdin of the session when sudo prompts for it.
+type sudo struct {
+	User     string
+	Password string
+}
+
+// wrap returns the command line running command through sudo with prompt as
+// the password prompt. Without a password sudo runs non-interactively and fails
+// instead of waiting for input.
+func (s *sudo) wrap(command string, prompt string) string {
+	args := []string{"sudo"}
+	if s.Password != "" {
+		args = append(args, "-S", "-p", shellQuote(prompt))
+	} else {
+		args = append(args, "-n")
+	}
+	if s.User != "" {
+		args = append(args, "-u", shellQuote(s.User))
+	}
+	args = append(args, "--", "sh", "-c", shellQuote(command))
+
+	return strings.Join(args, " ")
+}
+
+// newPrompt returns a random sudo prompt that does not show up in regular output
+func newPrompt() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "[sudo-prompt-" + hex.EncodeToString(b) + "]"
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// promptAnswerer watches the stderr of a sudo command for the prompt, removes
+// it from the output and answers it with the password. stdin is closed right
+// after the password, so a command reading stdin gets EOF instead of hanging and
+// sudo fails after a wrong password instead of waiting for another one.
+type promptAnswerer struct {
+	prompt   []byte
+	password string
+	stdin    io.WriteCloser
+	next     io.Writer
+
+	mu       sync.Mutex
+	pending  []byte
+	answered bool
+}
+
+func (a *promptAnswerer) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pending = append(a.pending, p...)
+	for {
+		i := bytes.Index(a.pending, a.prompt)
+		if i < 0 {
+			break
+		}
+		if err := a.flush(a.pending[:i]); err != nil {
+			return len(p), err
+		}
+		a.pending = a.pending[i+len(a.prompt):]
+		a.answer()
+	}
+
+	// hold back a possible start of the prompt split across writes
+	keep := partialPrefix(a.pending, a.prompt)
+	if err := a.flush(a.pending[:len(a.pending)-keep]); err != nil {
+		return len(p), err
+	}
+	a.pending = append([]byte(nil), a.pending[len(a.pending)-keep:]...)
+
+	return len(p), nil
+}
+
+// Close writes output held back by Write
+func (a *promptAnswerer) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	err := a.flush(a.pending)
+	a.pending = nil
+	return err
+}
+
+func (a *promptAnswerer) flush(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	_, err := a.next.Write(p)
+	return err
+}
+
+func (a *promptAnswerer) answer() {
+	if a.answered {
+		return
+	}
+	a.answered = true
+	_, _ = io.WriteString(a.stdin, a.password+"\n")
+	_ = a.stdin.Close()
+}
+
+// partialPrefix returns the length of the longest suffix of data that is a
+// prefix of prompt
+func partialPrefix(data []byte, prompt []byte) int {
+	n := len(prompt) - 1
+	if n > len(data) {
+		n = len(data)
+	}
+	for ; n > 0; n-- {
+		if bytes.HasPrefix(prompt, data[len(data)-n:]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSudoWrap(t *testing.T) {
+	tests := []struct {
+		name string
+		sudo sudo
+		want string
+	}{
+		{
+			name: "without password",
+			sudo: sudo{},
+			want: `sudo -n -- sh -c 'id -u'`,
+		},
+		{
+			name: "with password",
+			sudo: sudo{Password: "secret"},
+			want: `sudo -S -p '[prompt]' -- sh -c 'id -u'`,
+		},
+		{
+			name: "with user",
+			sudo: sudo{User: "deploy", Password: "secret"},
+			want: `sudo -S -p '[prompt]' -u 'deploy' -- sh -c 'id -u'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sudo.wrap("id -u", "[prompt]"); got != tt.want {
+				t.Errorf("wrap() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got, want := (&sudo{}).wrap("echo 'a b'", ""), `sudo -n -- sh -c 'echo '\''a b'\'''`; got != want {
+		t.Errorf("wrap() = %q, want %q", got, want)
+	}
+}
+
+func TestNewPrompt(t *testing.T) {
+	prompt := newPrompt()
+	if !strings.HasPrefix(prompt, "[sudo-prompt-") || prompt == newPrompt() {
+		t.Errorf("newPrompt() = %q, want a random prompt", prompt)
+	}
+}
+
+type testStdin struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (s *testStdin) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, io.ErrClosedPipe
+	}
+	return s.Buffer.Write(p)
+}
+
+func (s *testStdin) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestPromptAnswerer(t *testing.T) {
+	const prompt = "[sudo-prompt-1234]"
+
+	tests := []struct {
+		name       string
+		writes     []string
+		wantOutput string
+		wantStdin  string
+		wantClosed bool
+	}{
+		{
+			name:       "no prompt",
+			writes:     []string{"warning\n", "[sudo"},
+			wantOutput: "warning\n[sudo",
+		},
+		{
+			name:       "prompt",
+			writes:     []string{prompt, "error\n"},
+			wantOutput: "error\n",
+			wantStdin:  "secret\n",
+			wantClosed: true,
+		},
+		{
+			name:       "prompt split across writes",
+			writes:     []string{"before [sudo-", "prompt-", "1234]after\n"},
+			wantOutput: "before after\n",
+			wantStdin:  "secret\n",
+			wantClosed: true,
+		},
+		{
+			name:       "second prompt after a wrong password",
+			writes:     []string{prompt, "Sorry, try again.\n" + prompt, "sudo: no password was provided\n"},
+			wantOutput: "Sorry, try again.\nsudo: no password was provided\n",
+			wantStdin:  "secret\n",
+			wantClosed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			stdin := &testStdin{}
+			answerer := &promptAnswerer{prompt: []byte(prompt), password: "secret", stdin: stdin, next: &output}
+
+			for _, write := range tt.writes {
+				if n, err := answerer.Write([]byte(write)); n != len(write) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", write, n, err)
+				}
+			}
+			if err := answerer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := output.String(); got != tt.wantOutput {
+				t.Errorf("output = %q, want %q", got, tt.wantOutput)
+			}
+			if got := stdin.String(); got != tt.wantStdin {
+				t.Errorf("stdin = %q, want %q", got, tt.wantStdin)
+			}
+			if stdin.closed != tt.wantClosed {
+				t.Errorf("stdin closed = %v, want %v", stdin.closed, tt.wantClosed)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxHosts limits the hosts a target list can expand to, a typo in a range
+// should not open thousands of connections
+const maxHosts = 1000
+
+// host is a single server of the target param
+type host struct {
+	// Name is the host as shown in the step, with the port if it was given
+	Name string
+	Addr string
+	Port uint16
+}
+
+func (h host) address() string {
+	return net.JoinHostPort(h.Addr, strconv.Itoa(int(h.Port)))
+}
+
+// hostRange matches numeric ranges like [01:10] and letter ranges like [a:f]
+var hostRange = regexp.MustCompile(`\[(\d+):(\d+)\]|\[([a-z]):([a-z])\]`)
+
+// parseTargets splits the target param into hosts. Hosts are separated by new
+// lines, commas or spaces, may carry their own port as host:port or [ipv6]:port
+// and may contain ranges like web[01:10].example.com, which expand to a group of
+// hosts keeping the zero padding of the range start.
+func parseTargets(targets string, defaultPort uint16) ([]host, error) {
+	fields := strings.FieldsFunc(targets, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	})
+
+	var hosts []host
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		names, err := expandRanges(field)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range names {
+			h, err := parseHost(name, defaultPort)
+			if err != nil {
+				return nil, err
+			}
+			if seen[h.address()] {
+				continue
+			}
+			seen[h.address()] = true
+
+			hosts = append(hosts, h)
+			if len(hosts) > maxHosts {
+				return nil, fmt.Errorf("targets expand to more than %d hosts", maxHosts)
+			}
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no target host given")
+	}
+
+	return hosts, nil
+}
+
+func parseHost(name string, defaultPort uint16) (host, error) {
+	h := host{Name: name, Addr: name, Port: defaultPort}
+
+	addr, port, err := net.SplitHostPort(name)
+	if err != nil {
+		// no port, or a bare IPv6 address
+		h.Addr = strings.Trim(name, "[]")
+		return h, nil
+	}
+
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return host{}, fmt.Errorf("invalid port in target %q", name)
+	}
+	h.Addr = addr
+	h.Port = uint16(n)
+
+	return h, nil
+}
+
+// expandRanges expands every range of pattern
+func expandRanges(pattern string) ([]string, error) {
+	match := hostRange.FindStringSubmatchIndex(pattern)
+	if match == nil {
+		return []string{pattern}, nil
+	}
+
+	prefix, suffix := pattern[:match[0]], pattern[match[1]:]
+
+	var values []string
+	if match[2] >= 0 {
+		first, last := pattern[match[2]:match[3]], pattern[match[4]:match[5]]
+		start, _ := strconv.Atoi(first)
+		end, _ := strconv.Atoi(last)
+		if start > end || end-start >= maxHosts {
+			return nil, fmt.Errorf("invalid range in target %q", pattern)
+		}
+		width := 0
+		if strings.HasPrefix(first, "0") && len(first) > 1 {
+			width = len(first)
+		}
+		for i := start; i <= end; i++ {
+			values = append(values, fmt.Sprintf("%0*d", width, i))
+		}
+	} else {
+		start, end := pattern[match[6]], pattern[match[8]]
+		if start > end {
+			return nil, fmt.Errorf("invalid range in target %q", pattern)
+		}
+		for c := start; c <= end; c++ {
+			values = append(values, string(c))
+		}
+	}
+
+	var names []string
+	for _, value := range values {
+		expanded, err := expandRanges(prefix + value + suffix)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, expanded...)
+		if len(names) > maxHosts {
+			return nil, fmt.Errorf("targets expand to more than %d hosts", maxHosts)
+		}
+	}
+
+	return names, nil
+}
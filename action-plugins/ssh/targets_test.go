@@ -0,0 +1,117 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets string
+		want    []host
+		wantErr bool
+	}{
+		{
+			name:    "separators",
+			targets: "web1, web2\nweb3\r\n\tweb4",
+			want: []host{
+				{Name: "web1", Addr: "web1", Port: 22},
+				{Name: "web2", Addr: "web2", Port: 22},
+				{Name: "web3", Addr: "web3", Port: 22},
+				{Name: "web4", Addr: "web4", Port: 22},
+			},
+		},
+		{
+			name:    "ports",
+			targets: "web1:2222 10.0.0.1:22",
+			want: []host{
+				{Name: "web1:2222", Addr: "web1", Port: 2222},
+				{Name: "10.0.0.1:22", Addr: "10.0.0.1", Port: 22},
+			},
+		},
+		{
+			name:    "ipv6",
+			targets: "::1 [2001:db8::1] [2001:db8::2]:2222 fe80::1%eth0",
+			want: []host{
+				{Name: "::1", Addr: "::1", Port: 22},
+				{Name: "[2001:db8::1]", Addr: "2001:db8::1", Port: 22},
+				{Name: "[2001:db8::2]:2222", Addr: "2001:db8::2", Port: 2222},
+				{Name: "fe80::1%eth0", Addr: "fe80::1%eth0", Port: 22},
+			},
+		},
+		{
+			name:    "ipv6 range",
+			targets: "[2001:db8::[1:2]]:2222",
+			want: []host{
+				{Name: "[2001:db8::1]:2222", Addr: "2001:db8::1", Port: 2222},
+				{Name: "[2001:db8::2]:2222", Addr: "2001:db8::2", Port: 2222},
+			},
+		},
+		{
+			name:    "range with port",
+			targets: "web[1:2].example.com:2222",
+			want: []host{
+				{Name: "web1.example.com:2222", Addr: "web1.example.com", Port: 2222},
+				{Name: "web2.example.com:2222", Addr: "web2.example.com", Port: 2222},
+			},
+		},
+		{
+			name:    "duplicates",
+			targets: "web1 web1:22 web[1:2]",
+			want: []host{
+				{Name: "web1", Addr: "web1", Port: 22},
+				{Name: "web2", Addr: "web2", Port: 22},
+			},
+		},
+		{name: "invalid port", targets: "web1:99999", wantErr: true},
+		{name: "empty", targets: " ,\n", wantErr: true},
+		{name: "too many hosts", targets: "web[0:999] db[0:9]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTargets(tt.targets, 22)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTargets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTargets() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandRanges(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+		wantErr bool
+	}{
+		{pattern: "web1", want: []string{"web1"}},
+		{pattern: "web[1:3]", want: []string{"web1", "web2", "web3"}},
+		{pattern: "web[08:10]", want: []string{"web08", "web09", "web10"}},
+		{pattern: "web[0:1]", want: []string{"web0", "web1"}},
+		{pattern: "rack[a:c]", want: []string{"racka", "rackb", "rackc"}},
+		{pattern: "r[a:b]-n[1:2]", want: []string{"ra-n1", "ra-n2", "rb-n1", "rb-n2"}},
+		{pattern: "10.0.0.[1:2]", want: []string{"10.0.0.1", "10.0.0.2"}},
+		{pattern: "2001:db8::[9:10]", want: []string{"2001:db8::9", "2001:db8::10"}},
+		{pattern: "[2001:db8::1]", want: []string{"[2001:db8::1]"}},
+		{pattern: "web[3:1]", wantErr: true},
+		{pattern: "rack[c:a]", wantErr: true},
+		{pattern: "web[0:1000]", wantErr: true},
+		{pattern: "a[0:99]b[0:99]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := expandRanges(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandRanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandRanges() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
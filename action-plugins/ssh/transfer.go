@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"github.com/v1Flows/runner-plugins/sdk"
+)
+
+// Operations of the operation param
+const (
+	OperationExec     = "exec"
+	OperationUpload   = "upload"
+	OperationDownload = "download"
+)
+
+// FileOutput is a single transferred file. SHA256 is the checksum of the
+// source, Verified is true when the destination was read back and matched it.
+type FileOutput struct {
+	Local    string `json:"local"`
+	Remote   string `json:"remote"`
+	Size     int64  `json:"size"`
+	Mode     string `json:"mode"`
+	SHA256   string `json:"sha256"`
+	Verified bool   `json:"verified"`
+}
+
+// transfer copies files between the runner and a host over SFTP
+type transfer struct {
+	client *sftp.Client
+	output *sdk.LineSink
+
+	recursive    bool
+	preserveMode bool
+	verify       bool
+
+	files []FileOutput
+}
+
+// upload copies local to remote. Like scp, a remote path that is an existing
+// directory or ends with a slash receives local under its own name.
+func (t *transfer) upload(ctx context.Context, local string, remote string) error {
+	info, err := os.Stat(local)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() && !t.recursive {
+		return fmt.Errorf("%s is a directory, enable recursive to upload it", local)
+	}
+
+	if remoteInfo, err := t.client.Stat(remote); (err == nil && remoteInfo.IsDir()) || strings.HasSuffix(remote, "/") {
+		remote = path.Join(remote, filepath.Base(local))
+	}
+
+	if !info.IsDir() {
+		return t.uploadFile(ctx, local, remote, info)
+	}
+
+	return filepath.WalkDir(local, func(localPath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		rel, err := filepath.Rel(local, localPath)
+		if err != nil {
+			return err
+		}
+		remotePath := path.Join(remote, filepath.ToSlash(rel))
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case entry.IsDir():
+			if err := t.client.MkdirAll(remotePath); err != nil {
+				return fmt.Errorf("failed to create %s: %w", remotePath, err)
+			}
+			if t.preserveMode {
+				return t.client.Chmod(remotePath, info.Mode().Perm())
+			}
+			return nil
+		case info.Mode().IsRegular():
+			return t.uploadFile(ctx, localPath, remotePath, info)
+		default:
+			_ = t.output.Add("Skipping "+localPath+", not a regular file", sdk.ColorWarning)
+			return nil
+		}
+	})
+}
+
+func (t *transfer) uploadFile(ctx context.Context, local string, remote string, info os.FileInfo) error {
+	source, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := t.client.OpenFile(remote, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", remote, err)
+	}
+	defer destination.Close()
+
+	checksum := sha256.New()
+	size, err := io.Copy(destination, io.TeeReader(contextReader{ctx, source}, checksum))
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", local, err)
+	}
+
+	if t.preserveMode {
+		if err := destination.Chmod(info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to set the mode of %s: %w", remote, err)
+		}
+	}
+
+	if err := destination.Close(); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", local, err)
+	}
+
+	file := FileOutput{
+		Local:  local,
+		Remote: remote,
+		Size:   size,
+		Mode:   fmt.Sprintf("%04o", info.Mode().Perm()),
+		SHA256: hexSum(checksum),
+	}
+
+	if t.verify {
+		remoteFile, err := t.client.Open(remote)
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %w", remote, err)
+		}
+		defer remoteFile.Close()
+
+		if err := verifyChecksum(ctx, remoteFile, file.SHA256, remote); err != nil {
+			return err
+		}
+		file.Verified = true
+	}
+
+	t.done(file, "Uploaded "+local+" to "+remote)
+	return nil
+}
+
+// download copies remote to local. Like scp, a local path that is an existing
+// directory or ends with a separator receives remote under its own name.
+func (t *transfer) download(ctx context.Context, remote string, local string) error {
+	info, err := t.client.Stat(remote)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", remote, err)
+	}
+	if info.IsDir() && !t.recursive {
+		return fmt.Errorf("%s is a directory, enable recursive to download it", remote)
+	}
+
+	if localInfo, err := os.Stat(local); (err == nil && localInfo.IsDir()) || strings.HasSuffix(local, string(filepath.Separator)) {
+		local = filepath.Join(local, path.Base(remote))
+	}
+
+	if !info.IsDir() {
+		return t.downloadFile(ctx, remote, local, info)
+	}
+
+	walker := t.client.Walk(remote)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), remote), "/")
+		localPath := filepath.Join(local, filepath.FromSlash(rel))
+		info := walker.Stat()
+
+		switch {
+		case info.IsDir():
+			mode := os.FileMode(0755)
+			if t.preserveMode {
+				mode = info.Mode().Perm()
+			}
+			if err := os.MkdirAll(localPath, mode); err != nil {
+				return err
+			}
+			if t.preserveMode {
+				if err := os.Chmod(localPath, mode); err != nil {
+					return err
+				}
+			}
+		case info.Mode().IsRegular():
+			if err := t.downloadFile(ctx, walker.Path(), localPath, info); err != nil {
+				return err
+			}
+		default:
+			_ = t.output.Add("Skipping "+walker.Path()+", not a regular file", sdk.ColorWarning)
+		}
+	}
+
+	return nil
+}
+
+func (t *transfer) downloadFile(ctx context.Context, remote string, local string, info os.FileInfo) error {
+	source, err := t.client.Open(remote)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", remote, err)
+	}
+	defer source.Close()
+
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return err
+	}
+
+	destination, err := os.OpenFile(local, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer destination.Close()
+
+	checksum := sha256.New()
+	size, err := io.Copy(destination, io.TeeReader(contextReader{ctx, source}, checksum))
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", remote, err)
+	}
+
+	if t.preserveMode {
+		if err := destination.Chmod(info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	if err := destination.Close(); err != nil {
+		return err
+	}
+
+	file := FileOutput{
+		Local:  local,
+		Remote: remote,
+		Size:   size,
+		Mode:   fmt.Sprintf("%04o", info.Mode().Perm()),
+		SHA256: hexSum(checksum),
+	}
+
+	if t.verify {
+		localFile, err := os.Open(local)
+		if err != nil {
+			return err
+		}
+		defer localFile.Close()
+
+		if err := verifyChecksum(ctx, localFile, file.SHA256, local); err != nil {
+			return err
+		}
+		file.Verified = true
+	}
+
+	t.done(file, "Downloaded "+remote+" to "+local)
+	return nil
+}
+
+func (t *transfer) done(file FileOutput, message string) {
+	t.files = append(t.files, file)
+
+	line := fmt.Sprintf("%s (%d bytes, mode %s, sha256 %s)", message, file.Size, file.Mode, file.SHA256)
+	if file.Verified {
+		line += ", checksum verified"
+	}
+	_ = t.output.Add(line, "")
+}
+
+// verifyChecksum reads the written copy back and compares its checksum to the
+// checksum of the source
+func verifyChecksum(ctx context.Context, r io.Reader, expected string, name string) error {
+	checksum := sha256.New()
+	if _, err := io.Copy(checksum, contextReader{ctx, r}); err != nil {
+		return fmt.Errorf("failed to verify %s: %w", name, err)
+	}
+
+	if actual := hexSum(checksum); actual != expected {
+		return fmt.Errorf("checksum mismatch for %s: expected sha256 %s, got %s", name, expected, actual)
+	}
+
+	return nil
+}
+
+func hexSum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// contextReader stops a copy once ctx is canceled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -2,19 +2,16 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"net/rpc"
-	"strconv"
+	"path/filepath"
 	"strings"
-	"sync"
-	"time"
 
-	"github.com/v1Flows/runner/pkg/executions"
+	"github.com/v1Flows/runner-plugins/sdk"
 	"github.com/v1Flows/runner/pkg/plugins"
 
 	"github.com/v1Flows/shared-library/pkg/models"
 
-	"github.com/hashicorp/go-plugin"
 	"github.com/hashicorp/terraform-exec/tfexec"
 
 	"github.com/hashicorp/go-version"
@@ -23,106 +20,129 @@ import (
 )
 
 // Plugin is an implementation of the Plugin interface
-type Plugin struct{}
+type Plugin struct {
+	sdk.ActionPlugin
+}
 
-var (
-	taskCancels   = make(map[string]context.CancelFunc)
-	taskCancelsMu sync.Mutex
-)
+var tasks = sdk.NewTaskRegistry()
+
+// Params are the action params of the terraform plugin
+type Params struct {
+	TFVersion  string `param:"tf_version,required" title:"Terraform Version" default:"1.0.6" category:"General" description:"Terraform Version to use"`
+	Workdir    string `param:"workdir,required" title:"Working Directory" category:"General" description:"Working directory where terraform files are located"`
+	Init       bool   `param:"init" title:"Initialize" default:"false" category:"Init" description:"Perform an terraform init"`
+	Plan       bool   `param:"plan" title:"Plan" default:"false" category:"Plan" description:"Perform an terraform plan"`
+	PlanOutput string `param:"plan_output" title:"Plan Output" depends:"plan=true" category:"Plan" description:"Output the terraform plan to a file"`
+	PlanShow   bool   `param:"plan_show" title:"Plan Show" default:"false" depends:"plan_output=*" category:"Plan" description:"Show the terraform plan output. Requires a plan_output file"`
+	Apply      bool   `param:"apply" title:"Apply" default:"false" depends:"plan_output=*" category:"Apply" description:"Perform an terraform apply. Requires a plan_output file"`
+}
 
-func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	stepID := request.Step.ID.String()
-
-	// Store cancel func
-	taskCancelsMu.Lock()
-	taskCancels[stepID] = cancel
-	taskCancelsMu.Unlock()
-	defer func() {
-		taskCancelsMu.Lock()
-		delete(taskCancels, stepID)
-		taskCancelsMu.Unlock()
-	}()
-
-	tf_version := ""
-	workdir := request.Workspace
-	init := false
-	plan := false
-	plan_output := ""
-	plan_show := false
-	apply := false
-
-	for _, param := range request.Step.Action.Params {
-		if param.Key == "tf_version" {
-			tf_version = param.Value
-		}
-		if param.Key == "workdir" {
-			workdir = workdir + "/" + param.Value
-		}
-		if param.Key == "init" {
-			init, _ = strconv.ParseBool(param.Value)
-		}
-		if param.Key == "plan" {
-			plan, _ = strconv.ParseBool(param.Value)
-		}
-		if param.Key == "plan_output" {
-
-			// if the file ends with .tf fail
-			if strings.HasSuffix(param.Value, ".tf") {
-				err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
-					Messages: []models.Message{
-						{
-							Title: "Terraform",
-							Lines: []models.Line{
-								{
-									Content:   "Terraform Plan Output file cannot end with .tf",
-									Color:     "danger",
-									Timestamp: time.Now(),
-								},
-							},
-						},
-					},
-					Status:     "error",
-					FinishedAt: time.Now(),
-				}, request.Platform)
-				if err != nil {
-					return plugins.Response{
-						Success: false,
-					}, err
-				}
-			}
+// Output is published in Response.Data, see sdk.Outputs for the key layout.
+// Outputs holds the result of `terraform output -json`, OutputsJSON the same
+// document unflattened. Values of sensitive outputs are left out of both.
+type Output struct {
+	Changes     bool                   `json:"changes"`
+	Applied     bool                   `json:"applied"`
+	Outputs     map[string]OutputValue `json:"outputs"`
+	OutputsJSON string                 `json:"outputs_json"`
+}
 
-			plan_output = param.Value
+// OutputValue is a single terraform output
+type OutputValue struct {
+	Sensitive bool            `json:"sensitive"`
+	Type      json.RawMessage `json:"type,omitempty"`
+	Value     json.RawMessage `json:"value,omitempty"`
+}
+
+// readOutputs runs terraform output -json and drops the values of sensitive outputs
+func readOutputs(ctx context.Context, tf *tfexec.Terraform, reporter *sdk.StepReporter) (map[string]OutputValue, string, error) {
+	metas, err := tf.Output(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	outputs := make(map[string]OutputValue, len(metas))
+	for name, meta := range metas {
+		output := OutputValue{
+			Sensitive: meta.Sensitive,
+			Type:      meta.Type,
+			Value:     meta.Value,
 		}
-		if param.Key == "plan_show" {
-			plan_show, _ = strconv.ParseBool(param.Value)
+		if meta.Sensitive {
+			var value string
+			if json.Unmarshal(meta.Value, &value) == nil {
+				reporter.Redact(value)
+			}
+			output.Value = nil
 		}
-		if param.Key == "apply" {
-			apply, _ = strconv.ParseBool(param.Value)
+		outputs[name] = output
+	}
+
+	document, err := json.Marshal(outputs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return outputs, string(document), nil
+}
+
+func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
+	ctx, done, err := tasks.Register(request)
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+	defer done()
+
+	reporter := sdk.NewStepReporter(request)
+
+	info, err := p.Info(plugins.InfoRequest{Config: request.Config, Workspace: request.Workspace})
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
+	var params Params
+	err = reporter.DecodeParams(info.Action.Params, &params)
+	if err != nil {
+		_ = reporter.InvalidParams(err)
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
+	tfVersion, err := version.NewVersion(params.TFVersion)
+	if err != nil {
+		err = sdk.ParamErrors{{Key: "tf_version", Title: "Terraform Version", Message: err.Error()}}
+		_ = reporter.InvalidParams(err)
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+
+	workdir := params.Workdir
+	if !filepath.IsAbs(workdir) {
+		workdir = filepath.Join(request.Workspace, workdir)
+	}
+
+	// if the file ends with .tf fail
+	if strings.HasSuffix(params.PlanOutput, ".tf") {
+		if err := reporter.Finish(sdk.StatusError, "Terraform", "Terraform Plan Output file cannot end with .tf"); err != nil {
+			return plugins.Response{
+				Success: false,
+			}, err
 		}
+
+		return plugins.Response{
+			Success: false,
+		}, errors.New("terraform plan output file cannot end with .tf")
 	}
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Cancel",
-					Lines: []models.Line{
-						{
-							Content:   "Action canceled",
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status:     "canceled",
-			FinishedAt: time.Now(),
-		}, request.Platform)
-		if err != nil {
+		if err := reporter.Cancelled(ctx); err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
@@ -131,22 +151,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		return plugins.Response{Success: false, Canceled: true}, nil
 	}
 
-	err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
-		Messages: []models.Message{
-			{
-				Title: "Terraform",
-				Lines: []models.Line{
-					{
-						Content:   "Terraform Action started",
-						Timestamp: time.Now(),
-					},
-				},
-			},
-		},
-		Status:    "running",
-		StartedAt: time.Now(),
-	}, request.Platform)
+	err = reporter.Start("Terraform", "Terraform Action started")
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -155,34 +160,12 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 
 	installer := &releases.ExactVersion{
 		Product: product.Terraform,
-		Version: version.Must(version.NewVersion(tf_version)),
+		Version: tfVersion,
 	}
 
 	execPath, err := installer.Install(ctx)
 	if err != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Terraform",
-					Lines: []models.Line{
-						{
-							Content:   "Terraform Install failed",
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-						{
-							Content:   err.Error(),
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status:     "error",
-			FinishedAt: time.Now(),
-		}, request.Platform)
-		if err != nil {
+		if err := reporter.Fail("Terraform", err, "Terraform Install failed"); err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
@@ -193,22 +176,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}, err
 	}
 
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
-		Messages: []models.Message{
-			{
-				Title: "Terraform",
-				Lines: []models.Line{
-					{
-						Content:   "Terraform Install completed",
-						Color:     "success",
-						Timestamp: time.Now(),
-					},
-				},
-			},
-		},
-		Status: "running",
-	}, request.Platform)
+	err = reporter.Success("Terraform", "Terraform Install completed")
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -217,24 +185,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Cancel",
-					Lines: []models.Line{
-						{
-							Content:   "Action canceled",
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status:     "canceled",
-			FinishedAt: time.Now(),
-		}, request.Platform)
-		if err != nil {
+		if err := reporter.Cancelled(ctx); err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
@@ -245,29 +196,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 
 	tf, err := tfexec.NewTerraform(workdir, execPath)
 	if err != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Terraform",
-					Lines: []models.Line{
-						{
-							Content:   "Terraform NewTerraform failed",
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-						{
-							Content:   err.Error(),
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status:     "error",
-			FinishedAt: time.Now(),
-		}, request.Platform)
-		if err != nil {
+		if err := reporter.Fail("Terraform", err, "Terraform NewTerraform failed"); err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
@@ -278,54 +207,17 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}, err
 	}
 
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
-		Messages: []models.Message{
-			{
-				Title: "Terraform",
-				Lines: []models.Line{
-					{
-						Content:   "Terraform NewTerraform completed",
-						Color:     "success",
-						Timestamp: time.Now(),
-					},
-				},
-			},
-		},
-		Status: "running",
-	}, request.Platform)
+	err = reporter.Success("Terraform", "Terraform NewTerraform completed")
 	if err != nil {
 		return plugins.Response{
 			Success: false,
 		}, err
 	}
 
-	if init {
+	if params.Init {
 		err = tf.Init(ctx, tfexec.Upgrade(false))
 		if err != nil {
-			err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
-				Messages: []models.Message{
-					{
-						Title: "Terraform",
-						Lines: []models.Line{
-							{
-								Content:   "Terraform Init failed",
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-							{
-								Content:   err.Error(),
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-						},
-					},
-				},
-				Status:     "error",
-				FinishedAt: time.Now(),
-			}, request.Platform)
-			if err != nil {
+			if err := reporter.Fail("Terraform", err, "Terraform Init failed"); err != nil {
 				return plugins.Response{
 					Success: false,
 				}, err
@@ -336,22 +228,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			}, err
 		}
 
-		err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Terraform",
-					Lines: []models.Line{
-						{
-							Content:   "Terraform Init completed",
-							Color:     "success",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status: "running",
-		}, request.Platform)
+		err = reporter.Success("Terraform", "Terraform Init completed")
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -359,32 +236,12 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 	}
 
-	if plan {
-		diff, err := tf.Plan(ctx, tfexec.Out(plan_output))
+	var out Output
+
+	if params.Plan {
+		diff, err := tf.Plan(ctx, tfexec.Out(params.PlanOutput))
 		if err != nil {
-			err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
-				Messages: []models.Message{
-					{
-						Title: "Terraform",
-						Lines: []models.Line{
-							{
-								Content:   "Terraform Plan failed",
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-							{
-								Content:   err.Error(),
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-						},
-					},
-				},
-				Status:     "error",
-				FinishedAt: time.Now(),
-			}, request.Platform)
-			if err != nil {
+			if err := reporter.Fail("Terraform", err, "Terraform Plan failed"); err != nil {
 				return plugins.Response{
 					Success: false,
 				}, err
@@ -395,60 +252,20 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			}, err
 		}
 
+		out.Changes = diff
+
 		if diff {
-			err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
-				Messages: []models.Message{
-					{
-						Title: "Terraform",
-						Lines: []models.Line{
-							{
-								Content:   "Terraform Plan has changes",
-								Color:     "warning",
-								Timestamp: time.Now(),
-							},
-						},
-					},
-				},
-				Status: "running",
-			}, request.Platform)
+			err := reporter.Warn("Terraform", "Terraform Plan has changes")
 			if err != nil {
 				return plugins.Response{
 					Success: false,
 				}, err
 			}
 
-			if plan_show {
-				plan, err := tf.ShowPlanFileRaw(ctx, plan_output)
+			if params.PlanShow {
+				plan, err := tf.ShowPlanFileRaw(ctx, params.PlanOutput)
 				if err != nil {
-					err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-						ID: request.Step.ID,
-						Messages: []models.Message{
-							{
-								Title: "Terraform",
-								Lines: []models.Line{
-									{
-										Content:   "Terraform Plan show failed",
-										Color:     "danger",
-										Timestamp: time.Now(),
-									},
-									{
-										Content:   "Plan output file: " + plan_output,
-										Color:     "danger",
-										Timestamp: time.Now(),
-									},
-									{
-										Content:   err.Error(),
-										Color:     "danger",
-										Timestamp: time.Now(),
-									},
-								},
-							},
-						},
-						Status:     "error",
-						FinishedAt: time.Now(),
-					}, request.Platform)
-					if err != nil {
+					if err := reporter.Fail("Terraform", err, "Terraform Plan show failed", "Plan output file: "+params.PlanOutput); err != nil {
 						return plugins.Response{
 							Success: false,
 						}, err
@@ -466,57 +283,30 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 					color := "" // Default color
 					trimmedLine := strings.TrimSpace(line)
 					if strings.HasPrefix(trimmedLine, "+") {
-						color = "success" // Color for additions
+						color = sdk.ColorSuccess // Color for additions
 					} else if strings.HasPrefix(trimmedLine, "-") {
 						// Check if the line is a list item (e.g., starts with "- " or "-\t")
 						if strings.HasPrefix(trimmedLine, "- ") || strings.HasPrefix(trimmedLine, "-\t") {
 							color = "" // Neutral color for list items
 						} else {
-							color = "danger" // Color for deletions
+							color = sdk.ColorDanger // Color for deletions
 						}
 					}
 					messageLines = append(messageLines, models.Line{
-						Content:   line,
-						Color:     color, // Assign the color
-						Timestamp: time.Now(),
+						Content: line,
+						Color:   color, // Assign the color
 					})
 				}
 
-				err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-					ID: request.Step.ID,
-					Messages: []models.Message{
-						{
-							Title: "Terraform",
-							Lines: messageLines,
-						},
-					},
-					Status: "running",
-				}, request.Platform)
+				err = reporter.Message("Terraform", messageLines...)
 				if err != nil {
 					return plugins.Response{
 						Success: false,
 					}, err
 				}
-
 			}
-
 		} else {
-			err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
-				Messages: []models.Message{
-					{
-						Title: "Terraform",
-						Lines: []models.Line{
-							{
-								Content:   "Terraform Plan has no changes",
-								Color:     "success",
-								Timestamp: time.Now(),
-							},
-						},
-					},
-				},
-				Status: "running",
-			}, request.Platform)
+			err := reporter.Success("Terraform", "Terraform Plan has no changes")
 			if err != nil {
 				return plugins.Response{
 					Success: false,
@@ -527,24 +317,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Cancel",
-					Lines: []models.Line{
-						{
-							Content:   "Action canceled",
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status:     "canceled",
-			FinishedAt: time.Now(),
-		}, request.Platform)
-		if err != nil {
+		if err := reporter.Cancelled(ctx); err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
@@ -553,27 +326,10 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		return plugins.Response{Success: false, Canceled: true}, nil
 	}
 
-	if apply {
+	if params.Apply {
 		// Fail if no plan_output is specified
-		if plan_output == "" {
-			err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
-				Messages: []models.Message{
-					{
-						Title: "Terraform",
-						Lines: []models.Line{
-							{
-								Content:   "Terraform Apply requires a plan_output file",
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-						},
-					},
-				},
-				Status:     "error",
-				FinishedAt: time.Now(),
-			}, request.Platform)
-			if err != nil {
+		if params.PlanOutput == "" {
+			if err := reporter.Finish(sdk.StatusError, "Terraform", "Terraform Apply requires a plan_output file"); err != nil {
 				return plugins.Response{
 					Success: false,
 				}, err
@@ -581,34 +337,12 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 
 			return plugins.Response{
 				Success: false,
-			}, err
+			}, errors.New("terraform apply requires a plan_output file")
 		}
 
-		err = tf.Apply(ctx, tfexec.DirOrPlan(plan_output))
+		err = tf.Apply(ctx, tfexec.DirOrPlan(params.PlanOutput))
 		if err != nil {
-			err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-				ID: request.Step.ID,
-				Messages: []models.Message{
-					{
-						Title: "Terraform",
-						Lines: []models.Line{
-							{
-								Content:   "Terraform Apply failed",
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-							{
-								Content:   err.Error(),
-								Color:     "danger",
-								Timestamp: time.Now(),
-							},
-						},
-					},
-				},
-				Status:     "error",
-				FinishedAt: time.Now(),
-			}, request.Platform)
-			if err != nil {
+			if err := reporter.Fail("Terraform", err, "Terraform Apply failed"); err != nil {
 				return plugins.Response{
 					Success: false,
 				}, err
@@ -619,22 +353,9 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 			}, err
 		}
 
-		err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Terraform",
-					Lines: []models.Line{
-						{
-							Content:   "Terraform Apply completed",
-							Color:     "success",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status: "running",
-		}, request.Platform)
+		out.Applied = true
+
+		err = reporter.Success("Terraform", "Terraform Apply completed")
 		if err != nil {
 			return plugins.Response{
 				Success: false,
@@ -642,61 +363,46 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 		}
 	}
 
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
-		Messages: []models.Message{
-			{
-				Title: "Terraform",
-				Lines: []models.Line{
-					{
-						Content:   "Terraform Action completed",
-						Color:     "success",
-						Timestamp: time.Now(),
-					},
-				},
-			},
-		},
-		Status:     "success",
-		FinishedAt: time.Now(),
-	}, request.Platform)
+	// outputs only exist once there is a state, missing ones are not an error
+	out.Outputs, out.OutputsJSON, err = readOutputs(ctx, tf, reporter)
+	if err != nil {
+		err = reporter.Warn("Terraform", "Terraform Output failed: "+err.Error())
+		if err != nil {
+			return plugins.Response{
+				Success: false,
+			}, err
+		}
+	}
+
+	data, err := reporter.Outputs(out)
 	if err != nil {
 		return plugins.Response{
 			Success: false,
 		}, err
 	}
 
-	return plugins.Response{
-		Success: true,
-	}, nil
-}
-
-func (p *Plugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
-	stepID := request.Step.ID.String()
-	taskCancelsMu.Lock()
-	cancel, ok := taskCancels[stepID]
-	taskCancelsMu.Unlock()
-
-	if !ok {
+	err = reporter.Finish(sdk.StatusSuccess, "Terraform", "Terraform Action completed")
+	if err != nil {
 		return plugins.Response{
 			Success: false,
-		}, errors.New("task not found")
+		}, err
 	}
 
-	cancel()
-	return plugins.Response{Success: true}, nil
+	return plugins.Response{
+		Data:    data,
+		Success: true,
+	}, nil
 }
 
-func (p *Plugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
+func (p *Plugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
+	return tasks.Cancel(request)
 }
 
 func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	var plugin = models.Plugin{
 		Name:    "Terraform",
 		Type:    "action",
-		Version: "1.0.3",
+		Version: "1.1.0",
 		Author:  "JustNZ",
 		Action: models.Action{
 			Name:        "Terraform",
@@ -704,83 +410,10 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 			Plugin:      "terraform",
 			Icon:        "logos:terraform-icon",
 			Category:    "Utility",
-			Params: []models.Params{
-				{
-					Key:         "tf_version",
-					Title:       "Terraform Version",
-					Type:        "text",
-					Category:    "General",
-					Default:     "1.0.6",
-					Required:    true,
-					Description: "Terraform Version to use",
-				},
-				{
-					Key:         "workdir",
-					Title:       "Working Directory",
-					Type:        "text",
-					Category:    "General",
-					Default:     request.Workspace + "/",
-					Required:    true,
-					Description: "Working directory where terraform files are located",
-				},
-				{
-					Key:         "init",
-					Title:       "Initialize",
-					Type:        "boolean",
-					Category:    "Init",
-					Default:     "false",
-					Required:    false,
-					Description: "Perform an terraform init",
-				},
-				{
-					Key:         "plan",
-					Title:       "Plan",
-					Type:        "boolean",
-					Category:    "Plan",
-					Default:     "false",
-					Required:    false,
-					Description: "Perform an terraform plan",
-				},
-				{
-					Key:         "plan_output",
-					Title:       "Plan Output",
-					Type:        "text",
-					Category:    "Plan",
-					Default:     request.Workspace + "/plan.tfplan",
-					Required:    false,
-					Description: "Output the terraform plan to a file",
-					DependsOn: models.DependsOn{
-						Key:   "plan",
-						Value: "true",
-					},
-				},
-				{
-					Key:         "plan_show",
-					Title:       "Plan Show",
-					Type:        "boolean",
-					Category:    "Plan",
-					Default:     "false",
-					Required:    false,
-					Description: "Show the terraform plan output. Requires a plan_output file",
-					DependsOn: models.DependsOn{
-						Key:   "plan_output",
-						Value: "*",
-					},
-				},
-				{
-					Key:         "apply",
-					Title:       "Apply",
-					Type:        "boolean",
-					Default:     "false",
-					Category:    "Apply",
-					Required:    false,
-					Description: "Perform an terraform apply. Requires a plan_output file",
-					DependsOn: models.DependsOn{
-						Key:   "plan_output",
-						Value: "*",
-					},
-				},
-			},
+			Params: append(sdk.ParamSchema(Params{
+				Workdir:    request.Workspace + "/",
+				PlanOutput: request.Workspace + "/plan.tfplan",
+			}), sdk.TimeoutParam()),
 		},
 		Endpoint: models.Endpoint{},
 	}
@@ -788,58 +421,6 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	return plugin, nil
 }
 
-// PluginRPCServer is the RPC server for Plugin
-type PluginRPCServer struct {
-	Impl plugins.Plugin
-}
-
-func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.ExecuteTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.CancelTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
-	result, err := s.Impl.EndpointRequest(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *models.Plugin) error {
-	result, err := s.Impl.Info(request)
-	*resp = result
-	return err
-}
-
-// PluginServer is the implementation of plugin.Plugin interface
-type PluginServer struct {
-	Impl plugins.Plugin
-}
-
-func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
-}
-
-func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &plugins.PluginRPC{Client: c}, nil
-}
-
 func main() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: plugin.HandshakeConfig{
-			ProtocolVersion:  1,
-			MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
-			MagicCookieValue: "hello",
-		},
-		Plugins: map[string]plugin.Plugin{
-			"plugin": &PluginServer{Impl: &Plugin{}},
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
-	})
+	sdk.Serve(&Plugin{})
 }
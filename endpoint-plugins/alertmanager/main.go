@@ -3,11 +3,10 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"net/rpc"
 
 	"github.com/google/uuid"
+	"github.com/v1Flows/runner-plugins/sdk"
 	"github.com/v1Flows/runner/pkg/alerts"
 	"github.com/v1Flows/runner/pkg/flows"
 	"github.com/v1Flows/runner/pkg/plugins"
@@ -17,7 +16,6 @@ import (
 
 	"time"
 
-	"github.com/hashicorp/go-plugin"
 	"github.com/tidwall/gjson"
 )
 
@@ -35,18 +33,8 @@ func parseTime(timeStr string) time.Time {
 }
 
 // AlertmanagerEndpointPlugin is an implementation of the Plugin interface
-type AlertmanagerEndpointPlugin struct{}
-
-func (p *AlertmanagerEndpointPlugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
-}
-
-func (p *AlertmanagerEndpointPlugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
+type AlertmanagerEndpointPlugin struct {
+	sdk.EndpointPlugin
 }
 
 func (p *AlertmanagerEndpointPlugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
@@ -152,7 +140,7 @@ func (p *AlertmanagerEndpointPlugin) Info(request plugins.InfoRequest) (shared_m
 	return shared_models.Plugin{
 		Name:    "Alertmanager",
 		Type:    "endpoint",
-		Version: "1.2.7",
+		Version: "1.3.0",
 		Author:  "JustNZ",
 		Endpoint: shared_models.Endpoint{
 			ID:    "alertmanager",
@@ -164,58 +152,6 @@ func (p *AlertmanagerEndpointPlugin) Info(request plugins.InfoRequest) (shared_m
 	}, nil
 }
 
-// PluginRPCServer is the RPC server for Plugin
-type PluginRPCServer struct {
-	Impl plugins.Plugin
-}
-
-func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.ExecuteTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.CancelTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
-	result, err := s.Impl.EndpointRequest(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *shared_models.Plugin) error {
-	result, err := s.Impl.Info(request)
-	*resp = result
-	return err
-}
-
-// PluginServer is the implementation of plugin.Plugin interface
-type PluginServer struct {
-	Impl plugins.Plugin
-}
-
-func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
-}
-
-func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &plugins.PluginRPC{Client: c}, nil
-}
-
 func main() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: plugin.HandshakeConfig{
-			ProtocolVersion:  1,
-			MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
-			MagicCookieValue: "hello",
-		},
-		Plugins: map[string]plugin.Plugin{
-			"plugin": &PluginServer{Impl: &AlertmanagerEndpointPlugin{}},
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
-	})
+	sdk.Serve(&AlertmanagerEndpointPlugin{})
 }
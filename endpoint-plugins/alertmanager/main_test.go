@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/v1Flows/runner-plugins/sdk/plugintest"
+)
+
+const firingPayload = `{
+	"receiver": "flow-1",
+	"status": "firing",
+	"groupLabels": {"alertname": "GroupName"},
+	"commonLabels": {"alertname": "HighLoad", "severity": "critical"},
+	"alerts": [
+		{
+			"status": "firing",
+			"labels": {"alertname": "HighLoad", "instance": "web1:9100"},
+			"startsAt": "2025-03-01T10:00:00Z",
+			"endsAt": "0001-01-01T00:00:00Z"
+		},
+		{
+			"status": "resolved",
+			"labels": {"alertname": "HighLoad", "instance": "web2:9100"},
+			"startsAt": "2025-03-01T09:00:00Z",
+			"endsAt": "2025-03-01T09:30:00Z"
+		}
+	]
+}`
+
+func TestEndpointRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		alert   string
+		status  string
+		subs    []string
+	}{
+		{
+			name:    "common alertname",
+			payload: firingPayload,
+			alert:   "HighLoad",
+			status:  "firing",
+			subs:    []string{"firing", "resolved"},
+		},
+		{
+			name:    "group alertname",
+			payload: `{"receiver": "flow-1", "status": "resolved", "groupLabels": {"alertname": "DiskFull"}}`,
+			alert:   "DiskFull",
+			status:  "resolved",
+		},
+		{
+			name:    "no alertname",
+			payload: `{"receiver": "flow-1", "status": "firing"}`,
+			alert:   "Unknown",
+			status:  "firing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := plugintest.New(&AlertmanagerEndpointPlugin{})
+			defer h.Close()
+			h.Platform = "alertflow"
+
+			if err := h.Backend.SetFlow("flow-1", map[string]string{"id": "flow-1"}); err != nil {
+				t.Fatal(err)
+			}
+
+			response, err := h.Endpoint([]byte(tt.payload))
+			if err != nil || !response.Success {
+				t.Fatalf("Endpoint() = %+v, %v", response, err)
+			}
+
+			alerts := h.Backend.Alerts()
+			if len(alerts) != 1 {
+				t.Fatalf("got %d alerts, want 1", len(alerts))
+			}
+			alert := alerts[0]
+
+			if alert.Name != tt.alert || alert.Status != tt.status || alert.FlowID != "flow-1" || alert.Plugin != "Alertmanager" {
+				t.Errorf("alert = %q %q of flow %q from %q, want %q %q of flow-1 from Alertmanager", alert.Name, alert.Status, alert.FlowID, alert.Plugin, tt.alert, tt.status)
+			}
+			if !json.Valid(alert.Payload) {
+				t.Errorf("payload %s is not the request body", alert.Payload)
+			}
+
+			if len(alert.SubAlerts) != len(tt.subs) {
+				t.Fatalf("got %d sub alerts, want %d", len(alert.SubAlerts), len(tt.subs))
+			}
+			for i, sub := range alert.SubAlerts {
+				if sub.Status != tt.subs[i] || sub.Name != "HighLoad" {
+					t.Errorf("sub alert %d = %q %q, want HighLoad %q", i, sub.Name, sub.Status, tt.subs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEndpointRequestTimes(t *testing.T) {
+	h := plugintest.New(&AlertmanagerEndpointPlugin{})
+	defer h.Close()
+	h.Platform = "alertflow"
+
+	if err := h.Backend.SetFlow("flow-1", map[string]string{"id": "flow-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Endpoint([]byte(firingPayload)); err != nil {
+		t.Fatal(err)
+	}
+
+	subs := h.Backend.Alerts()[0].SubAlerts
+	resolved := subs[1]
+	if want := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC); !resolved.StartedAt.Equal(want) {
+		t.Errorf("StartedAt = %v, want %v", resolved.StartedAt, want)
+	}
+	if want := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC); !resolved.ResolvedAt.Equal(want) {
+		t.Errorf("ResolvedAt = %v, want %v", resolved.ResolvedAt, want)
+	}
+}
+
+func TestEndpointRequestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform string
+		body     []byte
+	}{
+		{name: "exflow is not supported", platform: "exflow", body: []byte(firingPayload)},
+		{name: "no body", platform: "alertflow"},
+		{name: "unknown flow", platform: "alertflow", body: []byte(`{"receiver": "unknown"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := plugintest.New(&AlertmanagerEndpointPlugin{})
+			defer h.Close()
+			h.Platform = tt.platform
+
+			if response, err := h.Endpoint(tt.body); err == nil || response.Success {
+				t.Errorf("Endpoint() = %+v, %v, want an error", response, err)
+			}
+			if alerts := h.Backend.Alerts(); len(alerts) != 0 {
+				t.Errorf("sent %d alerts, want none", len(alerts))
+			}
+		})
+	}
+}
@@ -21,6 +21,7 @@ on:
     branches: [ "develop" ]
     paths:
       - "$type/$plugin/**"
+      - "sdk/**"
 
 jobs:
   build-plugin:
@@ -50,6 +51,7 @@ on:
     branches: [ "main" ]
     paths:
       - "$type/$plugin/**"
+      - "sdk/**"
 
 jobs:
   build-and-release:
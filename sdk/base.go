@@ -0,0 +1,38 @@
+package sdk
+
+import (
+	"errors"
+
+	"github.com/v1Flows/runner/pkg/plugins"
+)
+
+// ErrNotImplemented is returned by the base plugins for methods a plugin does not provide
+var ErrNotImplemented = errors.New("not implemented")
+
+// ActionPlugin can be embedded by action plugins. It answers EndpointRequest
+// with ErrNotImplemented so the plugin only has to provide ExecuteTask,
+// CancelTask and Info.
+type ActionPlugin struct{}
+
+func (ActionPlugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
+	return plugins.Response{
+		Success: false,
+	}, ErrNotImplemented
+}
+
+// EndpointPlugin can be embedded by endpoint plugins. It answers ExecuteTask
+// and CancelTask with ErrNotImplemented so the plugin only has to provide
+// EndpointRequest and Info.
+type EndpointPlugin struct{}
+
+func (EndpointPlugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
+	return plugins.Response{
+		Success: false,
+	}, ErrNotImplemented
+}
+
+func (EndpointPlugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
+	return plugins.Response{
+		Success: false,
+	}, ErrNotImplemented
+}
@@ -0,0 +1,374 @@
+// plugin-cli runs a built plugin binary outside of the platform. It talks to the
+// plugin over the same go-plugin handshake as the runner and serves the platform
+// API the plugin calls from a local fake backend.
+//
+//	plugin-cli info ./ssh
+//	plugin-cli exec -param target=10.0.0.1 -param commands="uptime" ./ssh
+//	plugin-cli exec -params ssh.yaml ./ssh
+//	plugin-cli endpoint -body payload.json ./alertmanager
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+	"github.com/v1Flows/runner-plugins/sdk"
+	"github.com/v1Flows/runner-plugins/sdk/plugintest"
+	"github.com/v1Flows/runner/pkg/plugins"
+	"github.com/v1Flows/shared-library/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+const usage = `Usage: plugin-cli <command> [flags] <plugin binary>
+
+Commands:
+  info      print the plugin info and its param schema
+  exec      run the action of the plugin, Ctrl-C cancels it
+  endpoint  send a request body to the endpoint of the plugin
+
+Run plugin-cli <command> -h for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "info":
+		err = info(os.Args[2:])
+	case "exec":
+		err = execute(os.Args[2:])
+	case "endpoint":
+		err = endpoint(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("Error: %s", err))
+		os.Exit(1)
+	}
+}
+
+// paramFlags collects repeated -param key=value flags
+type paramFlags map[string]string
+
+func (p paramFlags) String() string {
+	return ""
+}
+
+func (p paramFlags) Set(value string) error {
+	key, val, found := strings.Cut(value, "=")
+	if !found || key == "" {
+		return fmt.Errorf("param %q must have the form key=value", value)
+	}
+	p[key] = val
+	return nil
+}
+
+// launch parses the shared flags of all commands and starts the plugin
+func launch(flags *flag.FlagSet, args []string) (*plugintest.Harness, error) {
+	platform := flags.String("platform", "exflow", "platform sent to the plugin, exflow or alertflow")
+	workspace := flags.String("workspace", "", "workspace directory sent to the plugin (default: current directory)")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return nil, errors.New("expected exactly one plugin binary")
+	}
+
+	path, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := plugintest.Launch(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", path, err)
+	}
+
+	h.Platform = *platform
+	h.Workspace = *workspace
+	if h.Workspace == "" {
+		h.Workspace, _ = os.Getwd()
+	}
+
+	return h, nil
+}
+
+func info(args []string) error {
+	flags := flag.NewFlagSet("info", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the plugin info as JSON")
+
+	h, err := launch(flags, args)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	plugin, err := h.Info()
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plugin)
+	}
+
+	fmt.Printf("%s %s (%s) by %s\n", color.New(color.Bold).Sprint(plugin.Name), plugin.Version, plugin.Type, plugin.Author)
+	if plugin.Action.Description != "" {
+		fmt.Println(plugin.Action.Description)
+	}
+	if len(plugin.Action.Params) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tTYPE\tREQUIRED\tDEFAULT\tDEPENDS ON\tDESCRIPTION")
+	for _, param := range plugin.Action.Params {
+		depends := ""
+		if param.DependsOn.Key != "" {
+			depends = param.DependsOn.Key + "=" + param.DependsOn.Value
+		}
+
+		paramType := param.Type
+		if len(param.Options) > 0 {
+			keys := make([]string, 0, len(param.Options))
+			for _, option := range param.Options {
+				keys = append(keys, option.Key)
+			}
+			paramType += " (" + strings.Join(keys, "|") + ")"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\t%s\n", param.Key, paramType, param.Required, param.Default, depends, param.Description)
+	}
+
+	return w.Flush()
+}
+
+func execute(args []string) error {
+	flags := flag.NewFlagSet("exec", flag.ExitOnError)
+	params := paramFlags{}
+	flags.Var(params, "param", "action param as key=value, can be repeated and overrides -params")
+	paramsFile := flags.String("params", "", "YAML or JSON file with the action params as key: value")
+
+	h, err := launch(flags, args)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	values := map[string]string{}
+	if *paramsFile != "" {
+		values, err = readParams(*paramsFile)
+		if err != nil {
+			return err
+		}
+	}
+	for key, value := range params {
+		values[key] = value
+	}
+
+	h.Backend.OnStepUpdate = printUpdate
+
+	run := h.Start(h.Request(values))
+
+	interrupt := make(chan os.Signal, 2)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	canceled := false
+	for {
+		select {
+		case <-interrupt:
+			if canceled {
+				return errors.New("interrupted")
+			}
+			canceled = true
+
+			fmt.Fprintln(os.Stderr, color.YellowString("Canceling, press Ctrl-C again to quit"))
+			if _, err := run.Cancel(currentUser()); err != nil {
+				fmt.Fprintln(os.Stderr, color.RedString("Cancel failed: %s", err))
+			}
+		case <-run.Done():
+			response, err := run.Wait()
+			return printResult(run.Step(), response, err)
+		}
+	}
+}
+
+func endpoint(args []string) error {
+	flags := flag.NewFlagSet("endpoint", flag.ExitOnError)
+	bodyFile := flags.String("body", "", "file with the request body, - reads stdin")
+
+	h, err := launch(flags, args)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	var body []byte
+	switch *bodyFile {
+	case "":
+		return errors.New("-body is required")
+	case "-":
+		body, err = io.ReadAll(os.Stdin)
+	default:
+		body, err = os.ReadFile(*bodyFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	response, err := h.Endpoint(body)
+	if err != nil {
+		return err
+	}
+
+	for _, alert := range h.Backend.Alerts() {
+		fmt.Println(color.New(color.Bold).Sprint("Alert sent to platform:"))
+		if err := printJSON(alert); err != nil {
+			return err
+		}
+	}
+
+	if !response.Success {
+		return errors.New("endpoint request was not successful")
+	}
+
+	fmt.Println(color.GreenString("Success"))
+	return nil
+}
+
+// readParams reads a flat YAML or JSON object. Lists are joined with newlines,
+// the way textarea params like commands are stored.
+func readParams(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// YAML is a superset of JSON, so one decoder handles both
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case nil:
+			values[key] = ""
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, "\n")
+		case map[string]interface{}:
+			return nil, fmt.Errorf("param %q must be a scalar or a list", key)
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+
+	return values, nil
+}
+
+func printUpdate(update plugintest.StepUpdate) {
+	for _, message := range update.Step.Messages {
+		title := color.New(color.Bold).Sprintf("[%s]", message.Title)
+		for _, line := range message.Lines {
+			fmt.Printf("%s %s %s\n", line.Timestamp.Format("15:04:05"), title, colorize(line.Color, line.Content))
+		}
+	}
+
+	if update.Step.Status != "" {
+		fmt.Printf("%s %s\n", color.New(color.Faint).Sprint("status:"), colorize(statusColor(update.Step.Status), update.Step.Status))
+	}
+}
+
+func printResult(step models.ExecutionSteps, response plugins.Response, err error) error {
+	if len(response.Data) > 0 {
+		fmt.Println(color.New(color.Bold).Sprint("Data:"))
+		if err := printJSON(response.Data); err != nil {
+			return err
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+	if response.Canceled {
+		return errors.New("action was canceled")
+	}
+	if !response.Success {
+		return fmt.Errorf("action finished with status %q", step.Status)
+	}
+
+	fmt.Println(color.GreenString("Success"))
+	return nil
+}
+
+func colorize(lineColor string, content string) string {
+	switch lineColor {
+	case sdk.ColorPrimary:
+		return color.BlueString("%s", content)
+	case sdk.ColorSuccess:
+		return color.GreenString("%s", content)
+	case sdk.ColorWarning:
+		return color.YellowString("%s", content)
+	case sdk.ColorDanger:
+		return color.RedString("%s", content)
+	default:
+		return content
+	}
+}
+
+func statusColor(status string) string {
+	switch status {
+	case sdk.StatusSuccess:
+		return sdk.ColorSuccess
+	case sdk.StatusError, sdk.StatusCanceled:
+		return sdk.ColorDanger
+	case sdk.StatusPaused:
+		return sdk.ColorWarning
+	default:
+		return sdk.ColorPrimary
+	}
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "plugin-cli"
+}
@@ -0,0 +1,92 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+// OutputSeparator joins the path segments of flattened output keys
+const OutputSeparator = "."
+
+// Outputs flattens the struct v into the Response.Data map of an action.
+//
+// Response.Data travels from the plugin to the runner over gob, which only
+// decodes the basic types it knows without registration. Outputs therefore
+// marshals v with its json tags and flattens nested objects and arrays into
+// dotted keys holding strings, int64s, float64s and bools:
+//
+//	type Output struct {
+//		Commands []CommandOutput `json:"commands"`
+//	}
+//
+// becomes "commands.0.exit_code", "commands.0.stdout" and so on. Null values and
+// empty objects or arrays are left out.
+func Outputs(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	object, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("outputs must be a struct or a map")
+	}
+
+	outputs := make(map[string]interface{})
+	for key, value := range object {
+		flattenOutput(outputs, key, value)
+	}
+
+	return outputs, nil
+}
+
+func flattenOutput(outputs map[string]interface{}, key string, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		for child, childValue := range v {
+			flattenOutput(outputs, key+OutputSeparator+child, childValue)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flattenOutput(outputs, key+OutputSeparator+strconv.Itoa(i), item)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			outputs[key] = n
+		} else if f, err := v.Float64(); err == nil {
+			outputs[key] = f
+		} else {
+			outputs[key] = v.String()
+		}
+	default:
+		outputs[key] = v
+	}
+}
+
+// Outputs flattens v like the package level Outputs and redacts secrets from
+// all string values, so command output cannot leak them into later steps
+func (r *StepReporter) Outputs(v interface{}) (map[string]interface{}, error) {
+	outputs, err := Outputs(v)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range outputs {
+		if s, ok := value.(string); ok {
+			outputs[key] = r.Redactor.String(s)
+		}
+	}
+
+	return outputs, nil
+}
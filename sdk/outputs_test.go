@@ -0,0 +1,96 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+)
+
+func TestOutputs(t *testing.T) {
+	type command struct {
+		Command  string  `json:"command"`
+		ExitCode int     `json:"exit_code"`
+		Duration float64 `json:"duration"`
+		Ignored  bool    `json:"ignored"`
+	}
+	type output struct {
+		Target   string            `json:"target"`
+		Commands []command         `json:"commands"`
+		Labels   map[string]string `json:"labels"`
+		Empty    []string          `json:"empty"`
+		Missing  *command          `json:"missing"`
+		Omitted  string            `json:"omitted,omitempty"`
+		Big      uint64            `json:"big"`
+	}
+
+	got, err := Outputs(output{
+		Target: "web1",
+		Commands: []command{
+			{Command: "uptime", ExitCode: 0, Duration: 0.5},
+			{Command: "false", ExitCode: 1, Duration: 1, Ignored: true},
+		},
+		Labels: map[string]string{"env": "prod"},
+		Empty:  []string{},
+		Big:    1 << 63,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"target":               "web1",
+		"commands.0.command":   "uptime",
+		"commands.0.exit_code": int64(0),
+		"commands.0.duration":  0.5,
+		"commands.0.ignored":   false,
+		"commands.1.command":   "false",
+		"commands.1.exit_code": int64(1),
+		// json does not keep the type of whole floats
+		"commands.1.duration": int64(1),
+		"commands.1.ignored":  true,
+		"labels.env":          "prod",
+		"big":                 float64(1 << 63),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Outputs() =\n%#v\nwant\n%#v", got, want)
+	}
+
+	// the runner receives Response.Data over gob without registering types
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(got); err != nil {
+		t.Fatalf("outputs are not gob-safe: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("gob round trip = %#v", decoded)
+	}
+}
+
+func TestOutputsRequireObject(t *testing.T) {
+	for _, v := range []interface{}{"text", []string{"a"}, 1, func() {}} {
+		if _, err := Outputs(v); err == nil {
+			t.Errorf("Outputs(%T) succeeded", v)
+		}
+	}
+}
+
+func TestStepReporterOutputsAreRedacted(t *testing.T) {
+	reporter := NewStepReporter(testRequest(""))
+	reporter.Redact("hunter2")
+
+	got, err := reporter.Outputs(map[string]interface{}{
+		"stdout":    "password is hunter2",
+		"exit_code": 0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"stdout": "password is ****", "exit_code": int64(0)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Outputs() = %#v, want %#v", got, want)
+	}
+}
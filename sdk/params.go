@@ -0,0 +1,255 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+// ParamTag is the struct tag naming the action param a field is decoded from
+const ParamTag = "param"
+
+// ParamError describes a single invalid action param
+type ParamError struct {
+	Key     string
+	Title   string
+	Message string
+}
+
+func (e ParamError) Error() string {
+	name := e.Title
+	if name == "" {
+		name = e.Key
+	}
+	return name + ": " + e.Message
+}
+
+// ParamErrors collects every invalid action param found by DecodeParams
+type ParamErrors []ParamError
+
+func (e ParamErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return "invalid params: " + strings.Join(messages, "; ")
+}
+
+// DecodeParams binds the action params of a step into the struct pointed to by v.
+// Fields are matched by their `param:"key"` tag, a field with a `formerly` tag
+// takes the value of its old key if the step sends the new one empty or with
+// its default.
+// schema is the param list the
+// plugin declares in Info(): its defaults fill empty values, required params
+// must be set and number, boolean and select params are validated against
+// their type and options. Params whose DependsOn condition is not met are not
+// required. All problems are returned together as ParamErrors.
+func DecodeParams(params []models.Params, schema []models.Params, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Struct {
+		return errors.New("DecodeParams requires a pointer to a struct")
+	}
+
+	values := make(map[string]string, len(params))
+	for _, param := range params {
+		values[param.Key] = param.Value
+	}
+
+	declared := make(map[string]models.Params, len(schema))
+	for _, param := range schema {
+		declared[param.Key] = param
+	}
+
+	structValue := target.Elem()
+	structType := structValue.Type()
+
+	// flows saved before a param was renamed still send the old key, it is
+	// used unless the new key holds a value other than its default
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		key, formerly := paramKey(field), field.Tag.Get("formerly")
+		if key == "" || formerly == "" || strings.TrimSpace(values[formerly]) == "" {
+			continue
+		}
+		if value := strings.TrimSpace(values[key]); value == "" || value == declared[key].Default {
+			values[key] = values[formerly]
+		}
+	}
+
+	for _, param := range schema {
+		if strings.TrimSpace(values[param.Key]) == "" {
+			values[param.Key] = param.Default
+		}
+	}
+
+	var errs ParamErrors
+
+	for _, param := range schema {
+		value := strings.TrimSpace(values[param.Key])
+
+		if !dependencyMet(param, values) {
+			continue
+		}
+
+		if value == "" {
+			if param.Required {
+				errs = append(errs, ParamError{Key: param.Key, Title: param.Title, Message: "is required"})
+			}
+			continue
+		}
+
+		if err := validateParam(param, value); err != nil {
+			errs = append(errs, ParamError{Key: param.Key, Title: param.Title, Message: err.Error()})
+		}
+	}
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		key := paramKey(field)
+		if key == "" || !field.IsExported() {
+			continue
+		}
+
+		value, ok := values[key]
+		if !ok || strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		if err := setField(structValue.Field(i), value); err != nil {
+			// type errors of declared params are already reported by validateParam
+			if param, ok := declared[key]; !ok || validateParam(param, strings.TrimSpace(value)) == nil {
+				errs = append(errs, ParamError{Key: key, Title: declared[key].Title, Message: err.Error()})
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// DecodeParams binds the action params of the step into v like the package
+// level DecodeParams. The values of all params schema declares as password are
+// redacted from the output of the step, whatever type the step sends them with.
+func (r *StepReporter) DecodeParams(schema []models.Params, v interface{}) error {
+	r.Redact(SecretParams(r.request.Step.Action.Params, schema)...)
+
+	return DecodeParams(r.request.Step.Action.Params, schema, v)
+}
+
+func paramKey(field reflect.StructField) string {
+	tag := field.Tag.Get(ParamTag)
+	if tag == "-" {
+		return ""
+	}
+	return strings.Split(tag, ",")[0]
+}
+
+func dependencyMet(param models.Params, values map[string]string) bool {
+	if param.DependsOn.Key == "" {
+		return true
+	}
+	if param.DependsOn.Value == "*" {
+		return strings.TrimSpace(values[param.DependsOn.Key]) != ""
+	}
+	return values[param.DependsOn.Key] == param.DependsOn.Value
+}
+
+func validateParam(param models.Params, value string) error {
+	switch param.Type {
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+	case "select":
+		keys := make([]string, 0, len(param.Options))
+		for _, option := range param.Options {
+			if option.Key == value {
+				return nil
+			}
+			keys = append(keys, option.Key)
+		}
+		if len(keys) > 0 {
+			return fmt.Errorf("%q is not one of %s", value, strings.Join(keys, ", "))
+		}
+	}
+
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setField(field reflect.Value, value string) error {
+	if field.Kind() == reflect.String {
+		field.SetString(value)
+		return nil
+	}
+
+	value = strings.TrimSpace(value)
+
+	if field.Type() == durationType {
+		if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+			field.SetInt(seconds * int64(time.Second))
+			return nil
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", value)
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a valid integer", value)
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a valid positive integer", value)
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported field type %s", field.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(strings.ReplaceAll(value, "\r\n", "\n"), "\n") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
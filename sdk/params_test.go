@@ -0,0 +1,129 @@
+package sdk
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+func TestDecodeParamsReadsFormerKey(t *testing.T) {
+	type params struct {
+		ConnectTimeout int `param:"connect_timeout" formerly:"Timeout" default:"3"`
+	}
+	schema := ParamSchema(params{})
+
+	tests := []struct {
+		name   string
+		params []models.Params
+		want   int
+	}{
+		{name: "new key", params: []models.Params{{Key: "connect_timeout", Value: "5"}}, want: 5},
+		{name: "former key", params: []models.Params{{Key: "Timeout", Value: "7"}}, want: 7},
+		{name: "new key wins", params: []models.Params{{Key: "Timeout", Value: "7"}, {Key: "connect_timeout", Value: "5"}}, want: 5},
+		{name: "former key wins over the default", params: []models.Params{{Key: "Timeout", Value: "7"}, {Key: "connect_timeout", Value: "3"}}, want: 7},
+		{name: "default", want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v params
+			if err := DecodeParams(tt.params, schema, &v); err != nil {
+				t.Fatal(err)
+			}
+			if v.ConnectTimeout != tt.want {
+				t.Errorf("ConnectTimeout = %d, want %d", v.ConnectTimeout, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeParams(t *testing.T) {
+	type params struct {
+		Target  string        `param:"target,required"`
+		Port    int           `param:"port" default:"22"`
+		Wait    time.Duration `param:"wait" default:"5s"`
+		Verbose bool          `param:"verbose"`
+		Method  string        `param:"method" type:"select" default:"password" options:"password=Password,key=Key"`
+		KeyFile string        `param:"key_file,required" depends:"method=key"`
+		Hosts   []string      `param:"hosts"`
+		Ratio   float64       `param:"ratio"`
+		Count   uint8         `param:"count"`
+	}
+	schema := ParamSchema(params{})
+
+	tests := []struct {
+		name     string
+		params   map[string]string
+		want     params
+		wantKeys []string
+	}{
+		{
+			name:   "defaults",
+			params: map[string]string{"target": "host"},
+			want:   params{Target: "host", Port: 22, Wait: 5 * time.Second, Method: "password"},
+		},
+		{
+			name:   "values",
+			params: map[string]string{"target": "host", "port": "2222", "wait": "30", "verbose": "true", "method": "key", "key_file": "/id", "hosts": "a\r\n\n b \n", "ratio": "0.5", "count": "3"},
+			want:   params{Target: "host", Port: 2222, Wait: 30 * time.Second, Verbose: true, Method: "key", KeyFile: "/id", Hosts: []string{"a", "b"}, Ratio: 0.5, Count: 3},
+		},
+		{
+			name:     "required",
+			params:   map[string]string{"target": "  "},
+			wantKeys: []string{"target"},
+		},
+		{
+			name:     "required with met dependency",
+			params:   map[string]string{"target": "host", "method": "key"},
+			wantKeys: []string{"key_file"},
+		},
+		{
+			name:     "type errors",
+			params:   map[string]string{"target": "host", "port": "ssh", "verbose": "maybe", "wait": "soon", "ratio": "half", "count": "300"},
+			wantKeys: []string{"port", "verbose", "ratio", "wait", "count"},
+		},
+		{
+			name:     "select option",
+			params:   map[string]string{"target": "host", "method": "agent"},
+			wantKeys: []string{"method"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var steps []models.Params
+			for key, value := range tt.params {
+				steps = append(steps, models.Params{Key: key, Value: value})
+			}
+
+			var got params
+			err := DecodeParams(steps, schema, &got)
+
+			var keys []string
+			var paramErrs ParamErrors
+			if errors.As(err, &paramErrs) {
+				for _, paramErr := range paramErrs {
+					keys = append(keys, paramErr.Key)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("invalid params = %q, want %q (%v)", keys, tt.wantKeys, err)
+			}
+			if tt.wantKeys == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeParams() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeParamsRequiresStructPointer(t *testing.T) {
+	var v struct{}
+	if err := DecodeParams(nil, nil, v); err == nil {
+		t.Error("DecodeParams() accepted a struct value")
+	}
+}
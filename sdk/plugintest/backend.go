@@ -0,0 +1,340 @@
+// Package plugintest runs plugins against a fake exFlow/alertFlow backend, so
+// actions and endpoints can be exercised without a running platform.
+package plugintest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	af_models "github.com/v1Flows/alertFlow/services/backend/pkg/models"
+	"github.com/v1Flows/runner/config"
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+// StepUpdate is a single step update received by the Backend
+type StepUpdate struct {
+	ExecutionID string
+	Step        models.ExecutionSteps
+}
+
+// Backend is an httptest server implementing the platform API used by the
+// runner packages executions, flows and alerts. It records everything plugins
+// send to it.
+type Backend struct {
+	// Server is the underlying test server
+	Server *httptest.Server
+	// OnStepUpdate is called for every step update after it was recorded
+	OnStepUpdate func(update StepUpdate)
+
+	mu         sync.Mutex
+	updates    []StepUpdate
+	steps      map[string]models.ExecutionSteps
+	executions map[string]models.Executions
+	flows      map[string]json.RawMessage
+	alerts     []af_models.Alerts
+	alertsByID map[string]af_models.Alerts
+}
+
+// NewBackend starts a Backend. Close it when done.
+func NewBackend() *Backend {
+	b := &Backend{
+		steps:      make(map[string]models.ExecutionSteps),
+		executions: make(map[string]models.Executions),
+		flows:      make(map[string]json.RawMessage),
+		alertsByID: make(map[string]af_models.Alerts),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /api/v1/executions/{id}/steps/{stepID}", b.updateStep)
+	mux.HandleFunc("GET /api/v1/executions/{id}/steps/{stepID}", b.getStep)
+	mux.HandleFunc("GET /api/v1/executions/{id}/steps", b.getSteps)
+	mux.HandleFunc("POST /api/v1/executions/{id}/steps", b.createStep)
+	mux.HandleFunc("PUT /api/v1/executions/{id}/heartbeat", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("PUT /api/v1/executions/{id}", b.updateExecution)
+	mux.HandleFunc("GET /api/v1/executions/{id}", b.getExecution)
+	mux.HandleFunc("GET /api/v1/flows/{id}", b.getFlow)
+	mux.HandleFunc("POST /api/v1/alerts/", b.createAlert)
+	mux.HandleFunc("GET /api/v1/alerts/{id}", b.getAlert)
+	mux.HandleFunc("PUT /api/v1/alerts/{id}", b.updateAlert)
+
+	b.Server = httptest.NewServer(mux)
+
+	return b
+}
+
+// Close shuts the server down
+func (b *Backend) Close() {
+	b.Server.Close()
+}
+
+// Config returns a runner config pointing both platforms at the Backend
+func (b *Backend) Config() *config.Config {
+	cfg := &config.Config{}
+	cfg.Alertflow.Enabled = true
+	cfg.Alertflow.URL = b.Server.URL
+	cfg.Alertflow.APIKey = "plugintest"
+	cfg.ExFlow.Enabled = true
+	cfg.ExFlow.URL = b.Server.URL
+	cfg.ExFlow.APIKey = "plugintest"
+
+	return cfg
+}
+
+// SetStep stores step, e.g. to simulate a user interacting with it
+func (b *Backend) SetStep(step models.ExecutionSteps) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.steps[step.ID.String()] = step
+}
+
+// SetFlow stores the flow returned by flows.GetFlowData for id
+func (b *Backend) SetFlow(id string, flow interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{"flow": flow})
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.flows[id] = data
+	return nil
+}
+
+// SetAlert stores the alert returned by alerts.GetData
+func (b *Backend) SetAlert(alert af_models.Alerts) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.alertsByID[alert.ID.String()] = alert
+}
+
+// Updates returns every step update received so far, in order
+func (b *Backend) Updates() []StepUpdate {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]StepUpdate(nil), b.updates...)
+}
+
+// Step returns the current state of a step. Messages of all updates are
+// appended, other fields are overwritten by updates that set them.
+func (b *Backend) Step(stepID string) (models.ExecutionSteps, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	step, ok := b.steps[stepID]
+	return step, ok
+}
+
+// Lines returns the content of every line sent to a step under title, in order.
+// An empty title matches all messages.
+func (b *Backend) Lines(stepID string, title string) []string {
+	step, _ := b.Step(stepID)
+
+	var lines []string
+	for _, message := range step.Messages {
+		if title != "" && message.Title != title {
+			continue
+		}
+		for _, line := range message.Lines {
+			lines = append(lines, line.Content)
+		}
+	}
+
+	return lines
+}
+
+// Execution returns the last state of an execution sent by the plugin
+func (b *Backend) Execution(executionID string) (models.Executions, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	execution, ok := b.executions[executionID]
+	return execution, ok
+}
+
+// Alerts returns every alert sent with alerts.SendAlert
+func (b *Backend) Alerts() []af_models.Alerts {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]af_models.Alerts(nil), b.alerts...)
+}
+
+func (b *Backend) updateStep(w http.ResponseWriter, r *http.Request) {
+	var update models.ExecutionSteps
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stepID := r.PathValue("stepID")
+
+	stepUpdate := StepUpdate{
+		ExecutionID: r.PathValue("id"),
+		Step:        update,
+	}
+
+	b.mu.Lock()
+	b.updates = append(b.updates, stepUpdate)
+	b.steps[stepID] = mergeStep(b.steps[stepID], update, r.PathValue("id"))
+	b.mu.Unlock()
+
+	if b.OnStepUpdate != nil {
+		b.OnStepUpdate(stepUpdate)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (b *Backend) getStep(w http.ResponseWriter, r *http.Request) {
+	step, ok := b.Step(r.PathValue("stepID"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"step": step})
+}
+
+func (b *Backend) getSteps(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	steps := []models.ExecutionSteps{}
+	for _, step := range b.steps {
+		if step.ExecutionID == r.PathValue("id") {
+			steps = append(steps, step)
+		}
+	}
+	b.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"steps": steps})
+}
+
+func (b *Backend) createStep(w http.ResponseWriter, r *http.Request) {
+	var step models.ExecutionSteps
+	if err := json.NewDecoder(r.Body).Decode(&step); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	step.ExecutionID = r.PathValue("id")
+
+	b.SetStep(step)
+	writeJSON(w, http.StatusCreated, step)
+}
+
+func (b *Backend) updateExecution(w http.ResponseWriter, r *http.Request) {
+	var execution models.Executions
+	if err := json.NewDecoder(r.Body).Decode(&execution); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	b.mu.Lock()
+	b.executions[r.PathValue("id")] = execution
+	b.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (b *Backend) getExecution(w http.ResponseWriter, r *http.Request) {
+	execution, ok := b.Execution(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, execution)
+}
+
+func (b *Backend) getFlow(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	flow, ok := b.flows[r.PathValue("id")]
+	b.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(flow)
+}
+
+func (b *Backend) createAlert(w http.ResponseWriter, r *http.Request) {
+	var alert af_models.Alerts
+	if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	b.mu.Lock()
+	b.alerts = append(b.alerts, alert)
+	b.mu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (b *Backend) getAlert(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	alert, ok := b.alertsByID[r.PathValue("id")]
+	b.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"alert": alert})
+}
+
+func (b *Backend) updateAlert(w http.ResponseWriter, r *http.Request) {
+	var alert af_models.Alerts
+	if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	b.SetAlert(alert)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// mergeStep applies update the way the platform stores step updates
+func mergeStep(step models.ExecutionSteps, update models.ExecutionSteps, executionID string) models.ExecutionSteps {
+	step.ID = update.ID
+	step.ExecutionID = executionID
+	step.Messages = append(step.Messages, update.Messages...)
+
+	if update.Status != "" {
+		step.Status = update.Status
+	}
+	if update.Action.Name != "" {
+		step.Action = update.Action
+	}
+	if update.Interactive {
+		step.Interactive = true
+	}
+	if update.CanceledBy != "" {
+		step.CanceledBy = update.CanceledBy
+	}
+	if !update.CanceledAt.IsZero() {
+		step.CanceledAt = update.CanceledAt
+	}
+	if !update.StartedAt.IsZero() {
+		step.StartedAt = update.StartedAt
+	}
+	if !update.FinishedAt.IsZero() {
+		step.FinishedAt = update.FinishedAt
+	}
+
+	return step
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,85 @@
+package plugintest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	af_models "github.com/v1Flows/alertFlow/services/backend/pkg/models"
+	"github.com/v1Flows/runner/pkg/alerts"
+	"github.com/v1Flows/runner/pkg/flows"
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+func TestBackendGetStep(t *testing.T) {
+	b := NewBackend()
+	defer b.Close()
+
+	step := models.ExecutionSteps{ID: uuid.New(), ExecutionID: uuid.NewString(), Status: "pending"}
+	b.SetStep(step)
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{name: "known step", path: "/api/v1/executions/" + step.ExecutionID + "/steps/" + step.ID.String(), status: http.StatusOK},
+		{name: "unknown step", path: "/api/v1/executions/" + step.ExecutionID + "/steps/" + uuid.NewString(), status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(b.Server.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Step models.ExecutionSteps `json:"step"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Step.ID != step.ID || body.Step.Status != step.Status {
+				t.Errorf("step = %+v, want %+v", body.Step, step)
+			}
+		})
+	}
+}
+
+func TestBackendFlowsAndAlerts(t *testing.T) {
+	b := NewBackend()
+	defer b.Close()
+
+	if err := b.SetFlow("flow-1", map[string]string{"name": "Flow 1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := flows.GetFlowData(b.Config(), "flow-1", "alertflow")
+	if err != nil {
+		t.Fatalf("GetFlowData() error = %v", err)
+	}
+	if got, want := string(data), `{"flow":{"name":"Flow 1"}}`; got != want {
+		t.Errorf("flow data = %s, want %s", got, want)
+	}
+
+	if _, err := flows.GetFlowData(b.Config(), "unknown", "alertflow"); err == nil {
+		t.Error("GetFlowData() of an unknown flow succeeded")
+	}
+
+	alerts.SendAlert(b.Config(), af_models.Alerts{Name: "HighLoad", FlowID: "flow-1"})
+
+	sent := b.Alerts()
+	if len(sent) != 1 || sent[0].Name != "HighLoad" || sent[0].FlowID != "flow-1" {
+		t.Errorf("alerts = %+v, want the HighLoad alert of flow-1", sent)
+	}
+}
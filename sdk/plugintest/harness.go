@@ -0,0 +1,270 @@
+package plugintest
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+	"github.com/v1Flows/runner-plugins/sdk"
+	"github.com/v1Flows/runner/pkg/plugins"
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+// Harness drives a plugin against its own Backend
+type Harness struct {
+	// Plugin is the plugin under test, either in-process or an RPC client
+	Plugin plugins.Plugin
+	// Backend receives everything the plugin sends to the platform
+	Backend *Backend
+	// Platform is sent with every request, "exflow" unless changed
+	Platform string
+	// Workspace is sent with every request
+	Workspace string
+
+	client *plugin.Client
+}
+
+// New returns a Harness calling impl directly
+func New(impl plugins.Plugin) *Harness {
+	return &Harness{
+		Plugin:   impl,
+		Backend:  NewBackend(),
+		Platform: "exflow",
+	}
+}
+
+// Launch starts the plugin binary at path and talks to it through the same
+// go-plugin handshake the runner uses
+func Launch(path string, args ...string) (*Harness, error) {
+	client := plugin.NewClient(&plugin.ClientConfig{
+		HandshakeConfig:  sdk.HandshakeConfig,
+		Plugins:          sdk.PluginMap(nil),
+		Cmd:              exec.Command(path, args...),
+		AllowedProtocols: []plugin.Protocol{plugin.ProtocolNetRPC},
+		Logger: hclog.New(&hclog.LoggerOptions{
+			Name:  "plugin",
+			Level: hclog.Warn,
+		}),
+	})
+
+	rpcClient, err := client.Client()
+	if err != nil {
+		client.Kill()
+		return nil, err
+	}
+
+	raw, err := rpcClient.Dispense(sdk.PluginName)
+	if err != nil {
+		client.Kill()
+		return nil, err
+	}
+
+	impl, ok := raw.(plugins.Plugin)
+	if !ok {
+		client.Kill()
+		return nil, errors.New("plugin does not implement plugins.Plugin")
+	}
+
+	h := New(impl)
+	h.client = client
+
+	return h, nil
+}
+
+// Close stops the Backend and, for launched plugins, the plugin process
+func (h *Harness) Close() {
+	h.Backend.Close()
+	if h.client != nil {
+		h.client.Kill()
+	}
+}
+
+// Info returns the plugin info for the harness workspace
+func (h *Harness) Info() (models.Plugin, error) {
+	return h.Plugin.Info(plugins.InfoRequest{
+		Config:    h.Backend.Config(),
+		Workspace: h.Workspace,
+	})
+}
+
+// Request returns an ExecuteTaskRequest for a new execution and step with the
+// given action params, wired to the Backend. Like the platform it sends every
+// param the plugin declares in Info, with its type and the default for params
+// not in params. Params the plugin does not declare are sent as they are.
+func (h *Harness) Request(params map[string]string) plugins.ExecuteTaskRequest {
+	action := models.Action{}
+
+	declared := make(map[string]bool)
+	if info, err := h.Info(); err == nil {
+		for _, param := range info.Action.Params {
+			declared[param.Key] = true
+
+			value, ok := params[param.Key]
+			if !ok {
+				value = param.Default
+			}
+			action.Params = append(action.Params, models.Params{
+				Key:   param.Key,
+				Type:  param.Type,
+				Value: value,
+			})
+		}
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if !declared[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		action.Params = append(action.Params, models.Params{
+			Key:   key,
+			Value: params[key],
+		})
+	}
+
+	execution := models.Executions{
+		ID:        uuid.New(),
+		Status:    sdk.StatusRunning,
+		CreatedAt: time.Now(),
+	}
+
+	return plugins.ExecuteTaskRequest{
+		Config:    h.Backend.Config(),
+		Execution: execution,
+		Step: models.ExecutionSteps{
+			ID:          uuid.New(),
+			ExecutionID: execution.ID.String(),
+			Action:      action,
+			Status:      "pending",
+			CreatedAt:   time.Now(),
+		},
+		Platform:  h.Platform,
+		Workspace: h.Workspace,
+	}
+}
+
+// Execute runs request and waits for the result
+func (h *Harness) Execute(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
+	return h.Start(request).Wait()
+}
+
+// Run is an ExecuteTask call running in the background
+type Run struct {
+	// Request is the request the task was started with
+	Request plugins.ExecuteTaskRequest
+
+	harness  *Harness
+	done     chan struct{}
+	response plugins.Response
+	err      error
+}
+
+// Start calls ExecuteTask in the background, e.g. to cancel it while it runs
+func (h *Harness) Start(request plugins.ExecuteTaskRequest) *Run {
+	h.Backend.SetStep(request.Step)
+
+	run := &Run{
+		Request: request,
+		harness: h,
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(run.done)
+		run.response, run.err = h.Plugin.ExecuteTask(request)
+	}()
+
+	return run
+}
+
+// Wait blocks until the task returns
+func (r *Run) Wait() (plugins.Response, error) {
+	<-r.done
+	return r.response, r.err
+}
+
+// Done is closed once the task returned
+func (r *Run) Done() <-chan struct{} {
+	return r.done
+}
+
+// Cancel sends CancelTask for the step of the run as if canceledBy clicked cancel
+func (r *Run) Cancel(canceledBy string) (plugins.Response, error) {
+	step := r.Request.Step
+	step.CanceledBy = canceledBy
+	step.CanceledAt = time.Now()
+
+	return r.harness.Plugin.CancelTask(plugins.CancelTaskRequest{Step: step})
+}
+
+// Step returns the state of the step of the run as recorded by the Backend
+func (r *Run) Step() models.ExecutionSteps {
+	step, _ := r.harness.Backend.Step(r.Request.Step.ID.String())
+	return step
+}
+
+// Lines returns the lines sent to the step of the run under title
+func (r *Run) Lines(title string) []string {
+	return r.harness.Backend.Lines(r.Request.Step.ID.String(), title)
+}
+
+// Endpoint sends body to the EndpointRequest of the plugin
+func (h *Harness) Endpoint(body []byte) (plugins.Response, error) {
+	return h.Plugin.EndpointRequest(plugins.EndpointRequest{
+		Config:   h.Backend.Config(),
+		Body:     body,
+		Platform: h.Platform,
+	})
+}
+
+// CheckParams verifies the params declared by the plugin against the struct v
+// decoded in ExecuteTask, see sdk.CheckParams
+func (h *Harness) CheckParams(v interface{}) error {
+	info, err := h.Info()
+	if err != nil {
+		return err
+	}
+
+	return sdk.CheckParams(info.Action.Params, v)
+}
+
+// CheckInfo fails t if the params impl declares in Info do not match the
+// struct v decoded in ExecuteTask, see sdk.CheckParams, and that the version
+// matches the .version file the release workflow tags. Call it from a test of
+// every plugin:
+//
+//	func TestParams(t *testing.T) {
+//		plugintest.CheckInfo(t, &Plugin{}, Params{})
+//	}
+func CheckInfo(t testing.TB, impl plugins.Plugin, v interface{}) {
+	t.Helper()
+
+	info, err := impl.Info(plugins.InfoRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sdk.CheckParams(info.Action.Params, v); err != nil {
+		t.Error(err)
+	}
+
+	// tests run in the directory of the plugin package
+	version, err := os.ReadFile(".version")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	if err == nil && strings.TrimSpace(string(version)) != info.Version {
+		t.Errorf("Info returns version %q, but .version is %q", info.Version, strings.TrimSpace(string(version)))
+	}
+}
@@ -0,0 +1,237 @@
+package plugintest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/v1Flows/runner-plugins/sdk"
+	"github.com/v1Flows/runner/pkg/plugins"
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+// testPlugin reports a few lines and the secret param, or waits to be canceled
+// if the wait param is set
+type testPlugin struct {
+	sdk.ActionPlugin
+	tasks *sdk.TaskRegistry
+}
+
+func (p *testPlugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
+	ctx, done, err := p.tasks.Register(request)
+	if err != nil {
+		return plugins.Response{Success: false}, err
+	}
+	defer done()
+
+	reporter := sdk.NewStepReporter(request)
+	if err := reporter.Start("Test", "started"); err != nil {
+		return plugins.Response{Success: false}, err
+	}
+
+	for _, param := range request.Step.Action.Params {
+		if param.Key == "secret" && param.Value != "" {
+			if err := reporter.Info("Secret", "secret is "+param.Value); err != nil {
+				return plugins.Response{Success: false}, err
+			}
+		}
+		if param.Key == "wait" && param.Value == "true" {
+			<-ctx.Done()
+			if err := reporter.Cancelled(ctx); err != nil {
+				return plugins.Response{Success: false}, err
+			}
+			return plugins.Response{Success: false, Canceled: true}, nil
+		}
+	}
+
+	if err := reporter.Info("Test", "first", "second"); err != nil {
+		return plugins.Response{Success: false}, err
+	}
+	if err := reporter.Finish(sdk.StatusSuccess, "Test", "done"); err != nil {
+		return plugins.Response{Success: false}, err
+	}
+
+	return plugins.Response{Success: true}, nil
+}
+
+func (p *testPlugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
+	return p.tasks.Cancel(request)
+}
+
+func (p *testPlugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
+	return models.Plugin{
+		Name: "Test",
+		Type: "action",
+		Action: models.Action{
+			Params: []models.Params{
+				{Key: "wait", Type: "boolean", Default: "false"},
+				{Key: "secret", Type: sdk.PasswordParamType},
+				sdk.TimeoutParam(),
+			},
+		},
+	}, nil
+}
+
+func newTestHarness(t *testing.T) *Harness {
+	t.Helper()
+
+	h := New(&testPlugin{tasks: sdk.NewTaskRegistry()})
+	t.Cleanup(h.Close)
+
+	return h
+}
+
+func TestStepUpdates(t *testing.T) {
+	h := newTestHarness(t)
+
+	request := h.Request(nil)
+	response, err := h.Execute(request)
+	if err != nil || !response.Success {
+		t.Fatalf("Execute() = %+v, %v", response, err)
+	}
+
+	step, ok := h.Backend.Step(request.Step.ID.String())
+	if !ok {
+		t.Fatal("the backend has no state of the step")
+	}
+	if step.Status != sdk.StatusSuccess {
+		t.Errorf("step status = %q, want %q", step.Status, sdk.StatusSuccess)
+	}
+	if step.ExecutionID != request.Execution.ID.String() {
+		t.Errorf("step execution = %q, want %q", step.ExecutionID, request.Execution.ID)
+	}
+	if step.StartedAt.IsZero() || step.FinishedAt.IsZero() {
+		t.Errorf("step started at %v and finished at %v, want both set", step.StartedAt, step.FinishedAt)
+	}
+
+	want := []string{"started", "first", "second", "done"}
+	if got := h.Backend.Lines(request.Step.ID.String(), "Test"); !reflect.DeepEqual(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+
+	updates := h.Backend.Updates()
+	if len(updates) != 3 {
+		t.Fatalf("got %d step updates, want 3", len(updates))
+	}
+	for _, update := range updates {
+		if update.ExecutionID != request.Execution.ID.String() || update.Step.ID != request.Step.ID {
+			t.Errorf("update for step %s of execution %s, want step %s of execution %s", update.Step.ID, update.ExecutionID, request.Step.ID, request.Execution.ID)
+		}
+	}
+}
+
+func TestRequestUsesDeclaredParams(t *testing.T) {
+	h := newTestHarness(t)
+
+	request := h.Request(map[string]string{"secret": "hunter2", "extra": "value"})
+
+	want := []models.Params{
+		{Key: "wait", Type: "boolean", Value: "false"},
+		{Key: "secret", Type: sdk.PasswordParamType, Value: "hunter2"},
+		{Key: sdk.TimeoutParamKey, Type: "text", Value: ""},
+		{Key: "extra", Value: "value"},
+	}
+	if got := request.Step.Action.Params; !reflect.DeepEqual(got, want) {
+		t.Errorf("params = %+v, want %+v", got, want)
+	}
+}
+
+func TestPasswordParamsAreRedacted(t *testing.T) {
+	h := newTestHarness(t)
+
+	run := h.Start(h.Request(map[string]string{"secret": "hunter2"}))
+	if _, err := run.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := run.Lines("Secret"), []string{"secret is " + sdk.Redacted}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
+
+func TestStepUpdateRecordsCancel(t *testing.T) {
+	h := newTestHarness(t)
+
+	request := h.Request(nil)
+	reporter := sdk.NewStepReporter(request)
+	canceledAt := time.Now().Truncate(time.Second)
+
+	err := reporter.Update(models.ExecutionSteps{
+		Status:     sdk.StatusCanceled,
+		CanceledBy: "alice",
+		CanceledAt: canceledAt,
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	step, _ := h.Backend.Step(request.Step.ID.String())
+	if step.Status != sdk.StatusCanceled || step.CanceledBy != "alice" || !step.CanceledAt.Equal(canceledAt) {
+		t.Errorf("step = status %q canceled by %q at %v, want canceled by alice at %v", step.Status, step.CanceledBy, step.CanceledAt, canceledAt)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	h := newTestHarness(t)
+
+	run := h.Start(h.Request(map[string]string{"wait": "true"}))
+	waitForStatus(t, run, sdk.StatusRunning)
+
+	if _, err := run.Cancel("alice"); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+
+	response, err := run.Wait()
+	if err != nil || !response.Canceled {
+		t.Fatalf("Wait() = %+v, %v, want a canceled response", response, err)
+	}
+	if status := run.Step().Status; status != sdk.StatusCanceled {
+		t.Errorf("step status = %q, want %q", status, sdk.StatusCanceled)
+	}
+	if got, want := run.Lines("Cancel"), []string{"Action canceled by alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cancel lines = %q, want %q", got, want)
+	}
+
+	// a late cancel of the finished step is answered without an error
+	if response, err := run.Cancel("bob"); err != nil || !response.Success {
+		t.Errorf("Cancel() of a finished step = %+v, %v", response, err)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	h := newTestHarness(t)
+
+	run := h.Start(h.Request(map[string]string{"wait": "true", sdk.TimeoutParamKey: "1"}))
+
+	select {
+	case <-run.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("the step did not time out")
+	}
+
+	if got, want := run.Lines("Cancel"), []string{"Action exceeded its timeout of 1s"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cancel lines = %q, want %q", got, want)
+	}
+}
+
+func TestCancelUnknownStep(t *testing.T) {
+	h := newTestHarness(t)
+
+	run := &Run{Request: h.Request(nil), harness: h}
+	if _, err := run.Cancel("alice"); !errors.Is(err, sdk.ErrTaskNotFound) {
+		t.Errorf("Cancel() error = %v, want ErrTaskNotFound", err)
+	}
+}
+
+func waitForStatus(t *testing.T, run *Run, status string) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for run.Step().Status != status {
+		if time.Now().After(deadline) {
+			t.Fatalf("step status = %q, want %q", run.Step().Status, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
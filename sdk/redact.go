@@ -0,0 +1,182 @@
+package sdk
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+// Redacted replaces secrets in step output
+const Redacted = "****"
+
+// PasswordParamType is the param type whose values are treated as secrets
+const PasswordParamType = "password"
+
+// MinSecretLength is the length below which secrets are not redacted, short
+// values like "a" or a "***" placeholder would scrub unrelated output
+const MinSecretLength = 4
+
+// Redactor scrubs secrets from step output. Besides the plain value it also
+// removes the base64, URL and JSON-encoded forms of every secret, and every
+// line of multi-line secrets like private keys.
+type Redactor struct {
+	mu       sync.Mutex
+	secrets  map[string]struct{}
+	replacer *strings.Replacer
+}
+
+// NewRedactor returns a Redactor for secrets
+func NewRedactor(secrets ...string) *Redactor {
+	r := &Redactor{
+		secrets: make(map[string]struct{}),
+	}
+	r.Add(secrets...)
+
+	return r
+}
+
+// Add registers more secrets. Values shorter than MinSecretLength are ignored.
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	added := false
+	for _, secret := range secrets {
+		for _, variant := range secretVariants(secret) {
+			if _, ok := r.secrets[variant]; !ok {
+				r.secrets[variant] = struct{}{}
+				added = true
+			}
+		}
+	}
+
+	if added {
+		r.replacer = nil
+	}
+}
+
+// String returns s with all secrets replaced by Redacted
+func (r *Redactor) String(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+
+	r.mu.Lock()
+	if r.replacer == nil {
+		r.replacer = r.buildReplacer()
+	}
+	replacer := r.replacer
+	r.mu.Unlock()
+
+	return replacer.Replace(s)
+}
+
+// Messages scrubs the content of every line of messages in place
+func (r *Redactor) Messages(messages []models.Message) {
+	if r == nil {
+		return
+	}
+
+	for i := range messages {
+		for j := range messages[i].Lines {
+			messages[i].Lines[j].Content = r.String(messages[i].Lines[j].Content)
+		}
+	}
+}
+
+// buildReplacer orders the secrets longest first, so a secret containing another
+// one is replaced as a whole
+func (r *Redactor) buildReplacer() *strings.Replacer {
+	secrets := make([]string, 0, len(r.secrets))
+	for secret := range r.secrets {
+		secrets = append(secrets, secret)
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		if len(secrets[i]) != len(secrets[j]) {
+			return len(secrets[i]) > len(secrets[j])
+		}
+		return secrets[i] < secrets[j]
+	})
+
+	pairs := make([]string, 0, len(secrets)*2)
+	for _, secret := range secrets {
+		pairs = append(pairs, secret, Redacted)
+	}
+
+	return strings.NewReplacer(pairs...)
+}
+
+func secretVariants(secret string) []string {
+	if len(strings.TrimSpace(secret)) < MinSecretLength {
+		return nil
+	}
+
+	variants := []string{
+		secret,
+		base64.StdEncoding.EncodeToString([]byte(secret)),
+		base64.RawStdEncoding.EncodeToString([]byte(secret)),
+		base64.URLEncoding.EncodeToString([]byte(secret)),
+		base64.RawURLEncoding.EncodeToString([]byte(secret)),
+		url.QueryEscape(secret),
+		url.PathEscape(secret),
+	}
+
+	// secrets inside JSON output have their quotes, backslashes and newlines escaped
+	if quoted, err := json.Marshal(secret); err == nil {
+		variants = append(variants, string(quoted[1:len(quoted)-1]))
+	}
+
+	// output is split into lines, so each line of a multi-line secret must go too
+	if strings.Contains(secret, "\n") {
+		for _, line := range strings.Split(secret, "\n") {
+			if line = strings.TrimSpace(line); len(line) >= MinSecretLength {
+				variants = append(variants, line)
+			}
+		}
+	}
+
+	return variants
+}
+
+// SecretParams returns the values of all params of type password. A param is a
+// password if the step sends it with that type or schema, the params declared
+// by the plugin, declares it as one. Empty values of declared passwords fall
+// back to their default.
+func SecretParams(params []models.Params, schema []models.Params) []string {
+	declared := make(map[string]models.Params, len(schema))
+	for _, param := range schema {
+		if param.Type == PasswordParamType {
+			declared[param.Key] = param
+		}
+	}
+
+	var secrets []string
+	for _, param := range params {
+		value := param.Value
+		if password, ok := declared[param.Key]; ok {
+			if value == "" {
+				value = password.Default
+			}
+			delete(declared, param.Key)
+		} else if param.Type != PasswordParamType {
+			continue
+		}
+
+		if value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+
+	for _, password := range declared {
+		if password.Default != "" {
+			secrets = append(secrets, password.Default)
+		}
+	}
+
+	return secrets
+}
@@ -0,0 +1,83 @@
+package sdk
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+func TestRedactor(t *testing.T) {
+	const secret = "p@ss w/rd+\"1\""
+	multiline := "-----BEGIN KEY-----\nc2VjcmV0IGtleQ\n-----END KEY-----"
+	quoted, _ := json.Marshal(secret)
+
+	tests := []struct {
+		name    string
+		secrets []string
+		input   string
+		want    string
+	}{
+		{name: "plain", secrets: []string{secret}, input: "login " + secret + " ok", want: "login **** ok"},
+		{name: "base64", secrets: []string{secret}, input: base64.StdEncoding.EncodeToString([]byte(secret)), want: "****"},
+		{name: "raw base64", secrets: []string{secret}, input: base64.RawStdEncoding.EncodeToString([]byte(secret)), want: "****"},
+		{name: "base64 url", secrets: []string{secret}, input: base64.URLEncoding.EncodeToString([]byte(secret)), want: "****"},
+		{name: "raw base64 url", secrets: []string{secret}, input: base64.RawURLEncoding.EncodeToString([]byte(secret)), want: "****"},
+		{name: "query escaped", secrets: []string{secret}, input: "?p=" + url.QueryEscape(secret), want: "?p=****"},
+		{name: "path escaped", secrets: []string{secret}, input: "/" + url.PathEscape(secret), want: "/****"},
+		{name: "json", secrets: []string{secret}, input: `{"password":` + string(quoted) + `}`, want: `{"password":"****"}`},
+		{name: "multi-line", secrets: []string{multiline}, input: "c2VjcmV0IGtleQ", want: "****"},
+		{name: "longest first", secrets: []string{"secret", "secret-token"}, input: "secret-token", want: "****"},
+		{name: "short secrets are ignored", secrets: []string{"a", "***", " ab "}, input: "a *** ab", want: "a *** ab"},
+		{name: "minimum length", secrets: []string{"abcd"}, input: "abcd", want: "****"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewRedactor(tt.secrets...).String(tt.input); got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorMessages(t *testing.T) {
+	messages := []models.Message{{Title: "Output", Lines: []models.Line{{Content: "token is hunter2"}}}}
+
+	NewRedactor("hunter2").Messages(messages)
+	if got := messages[0].Lines[0].Content; got != "token is ****" {
+		t.Errorf("line = %q", got)
+	}
+
+	var nilRedactor *Redactor
+	if got := nilRedactor.String("hunter2"); got != "hunter2" {
+		t.Errorf("nil Redactor changed the output to %q", got)
+	}
+}
+
+func TestSecretParams(t *testing.T) {
+	params := []models.Params{
+		{Key: "user", Value: "alice"},
+		{Key: "password", Value: "hunter2"},
+		{Key: "token", Type: PasswordParamType, Value: "step-token"},
+		{Key: "key", Value: ""},
+	}
+	schema := []models.Params{
+		{Key: "password", Type: PasswordParamType},
+		{Key: "key", Type: PasswordParamType, Default: "default-key"},
+		{Key: "missing", Type: PasswordParamType, Default: "missing-key"},
+	}
+
+	got := SecretParams(params, schema)
+	want := map[string]bool{"hunter2": true, "step-token": true, "default-key": true, "missing-key": true}
+	if len(got) != len(want) {
+		t.Fatalf("SecretParams() = %q", got)
+	}
+	for _, secret := range got {
+		if !want[secret] {
+			t.Errorf("SecretParams() returned %q", secret)
+		}
+	}
+}
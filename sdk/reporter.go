@@ -0,0 +1,333 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/v1Flows/runner/pkg/platform"
+	"github.com/v1Flows/runner/pkg/plugins"
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+// Step statuses understood by the platforms
+const (
+	StatusRunning  = "running"
+	StatusSuccess  = "success"
+	StatusError    = "error"
+	StatusCanceled = "canceled"
+	StatusPaused   = "paused"
+)
+
+// Line colors understood by the platforms
+const (
+	ColorPrimary = "primary"
+	ColorSuccess = "success"
+	ColorWarning = "warning"
+	ColorDanger  = "danger"
+)
+
+// Defaults used by NewStepReporter for retrying failed step updates
+const (
+	DefaultUpdateRetries = 3
+	DefaultRetryBackoff  = 250 * time.Millisecond
+)
+
+// updateClient sends the step updates. The timeout keeps a hanging platform
+// from blocking the step, the request is retried like any other network error.
+var updateClient = &http.Client{Timeout: 30 * time.Second}
+
+// updateError is a step update the platform did not accept. Transient errors
+// are network errors, 5xx and 429 responses, all others are not retried.
+type updateError struct {
+	err       error
+	transient bool
+}
+
+func (e *updateError) Error() string {
+	return e.err.Error()
+}
+
+func (e *updateError) Unwrap() error {
+	return e.err
+}
+
+// StepReporter sends messages and status changes for the step of an ExecuteTaskRequest
+type StepReporter struct {
+	// Retries is the number of times a failed step update is retried
+	Retries int
+	// RetryBackoff is the wait before the first retry, it grows with every attempt
+	RetryBackoff time.Duration
+	// Redactor scrubs secrets from every line before it is sent, nil disables it
+	Redactor *Redactor
+
+	request plugins.ExecuteTaskRequest
+
+	sinksMu sync.Mutex
+	sinks   []*LineSink
+}
+
+// NewStepReporter returns a StepReporter bound to the step of request. The values
+// of all params the step sends as password are redacted from its output, params
+// the plugin declares as password are added by DecodeParams.
+func NewStepReporter(request plugins.ExecuteTaskRequest) *StepReporter {
+	return &StepReporter{
+		Retries:      DefaultUpdateRetries,
+		RetryBackoff: DefaultRetryBackoff,
+		Redactor:     NewRedactor(SecretParams(request.Step.Action.Params, nil)...),
+		request:      request,
+	}
+}
+
+// Redact adds secrets that are not password params, e.g. tokens created at runtime
+func (r *StepReporter) Redact(secrets ...string) {
+	if r.Redactor != nil {
+		r.Redactor.Add(secrets...)
+	}
+}
+
+// Update sends step to the platform. The step ID is always taken from the request
+// and lines without a timestamp get the current time. Lines buffered in open
+// LineSinks are sent first so the output keeps its order. A sink that fails to
+// flush does not hold back step, its error is returned along with the result
+// of the update.
+func (r *StepReporter) Update(step models.ExecutionSteps) error {
+	var sinkErr error
+	for _, sink := range r.openSinks() {
+		if err := sink.Flush(); err != nil {
+			sinkErr = errors.Join(sinkErr, err)
+		}
+	}
+
+	return errors.Join(r.send(step), sinkErr)
+}
+
+// send delivers step to the platform and retries transient failures. A step
+// update the platform rejects with another status fails at once.
+func (r *StepReporter) send(step models.ExecutionSteps) error {
+	step.ID = r.request.Step.ID
+
+	// copy the messages, the lines are modified below
+	messages := make([]models.Message, len(step.Messages))
+	now := time.Now()
+	for i, message := range step.Messages {
+		messages[i] = models.Message{
+			Title: message.Title,
+			Lines: append([]models.Line(nil), message.Lines...),
+		}
+		for j := range messages[i].Lines {
+			if messages[i].Lines[j].Timestamp.IsZero() {
+				messages[i].Lines[j].Timestamp = now
+			}
+		}
+	}
+	r.Redactor.Messages(messages)
+	step.Messages = messages
+
+	var err error
+	for attempt := 0; attempt <= r.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(r.RetryBackoff * time.Duration(attempt))
+		}
+
+		err = r.put(step)
+		var updateErr *updateError
+		if err == nil || (errors.As(err, &updateErr) && !updateErr.transient) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// put sends a single step update. executions.UpdateStep of the runner is not
+// used, it reports rejected updates as success.
+func (r *StepReporter) put(step models.ExecutionSteps) error {
+	body, err := json.Marshal(step)
+	if err != nil {
+		return &updateError{err: err}
+	}
+
+	url, apiKey := platform.GetPlatformConfigPlain(r.request.Platform, r.request.Config)
+	req, err := http.NewRequest(http.MethodPut, url+"/api/v1/executions/"+r.request.Execution.ID.String()+"/steps/"+step.ID.String(), bytes.NewReader(body))
+	if err != nil {
+		return &updateError{err: err}
+	}
+	req.Header.Set("Authorization", apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := updateClient.Do(req)
+	if err != nil {
+		return &updateError{err: err, transient: true}
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	return &updateError{
+		err:       fmt.Errorf("failed to update step at %s: %s", r.request.Platform, resp.Status),
+		transient: resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+	}
+}
+
+func (r *StepReporter) addSink(sink *LineSink) {
+	r.sinksMu.Lock()
+	defer r.sinksMu.Unlock()
+
+	r.sinks = append(r.sinks, sink)
+}
+
+func (r *StepReporter) removeSink(sink *LineSink) {
+	r.sinksMu.Lock()
+	defer r.sinksMu.Unlock()
+
+	for i, s := range r.sinks {
+		if s == sink {
+			r.sinks = append(r.sinks[:i], r.sinks[i+1:]...)
+			return
+		}
+	}
+}
+
+func (r *StepReporter) openSinks() []*LineSink {
+	r.sinksMu.Lock()
+	defer r.sinksMu.Unlock()
+
+	return append([]*LineSink(nil), r.sinks...)
+}
+
+// Message adds a message with preformatted lines to the step
+func (r *StepReporter) Message(title string, lines ...models.Line) error {
+	return r.Update(models.ExecutionSteps{
+		Messages: []models.Message{
+			{
+				Title: title,
+				Lines: lines,
+			},
+		},
+	})
+}
+
+// Start marks the step as running and adds the given lines
+func (r *StepReporter) Start(title string, lines ...string) error {
+	return r.Update(models.ExecutionSteps{
+		Messages:  messages(title, "", lines),
+		Status:    StatusRunning,
+		StartedAt: time.Now(),
+	})
+}
+
+// Info adds uncolored lines to the step
+func (r *StepReporter) Info(title string, lines ...string) error {
+	return r.Update(models.ExecutionSteps{Messages: messages(title, "", lines)})
+}
+
+// Primary adds lines highlighted as primary to the step
+func (r *StepReporter) Primary(title string, lines ...string) error {
+	return r.Update(models.ExecutionSteps{Messages: messages(title, ColorPrimary, lines)})
+}
+
+// Success adds lines colored as success to the step without finishing it
+func (r *StepReporter) Success(title string, lines ...string) error {
+	return r.Update(models.ExecutionSteps{Messages: messages(title, ColorSuccess, lines)})
+}
+
+// Warn adds lines colored as warning to the step
+func (r *StepReporter) Warn(title string, lines ...string) error {
+	return r.Update(models.ExecutionSteps{Messages: messages(title, ColorWarning, lines)})
+}
+
+// Danger adds lines colored as danger to the step without failing it
+func (r *StepReporter) Danger(title string, lines ...string) error {
+	return r.Update(models.ExecutionSteps{Messages: messages(title, ColorDanger, lines)})
+}
+
+// Fail finishes the step with status error. The lines are followed by the error message.
+func (r *StepReporter) Fail(title string, err error, lines ...string) error {
+	if err != nil {
+		lines = append(lines, err.Error())
+	}
+
+	return r.Finish(StatusError, title, lines...)
+}
+
+// Finish sets the final status of the step and adds the given lines, colored to match the status.
+// All open LineSinks are closed before the final update is sent.
+func (r *StepReporter) Finish(status string, title string, lines ...string) error {
+	var sinkErr error
+	for _, sink := range r.openSinks() {
+		if err := sink.Close(); err != nil {
+			sinkErr = errors.Join(sinkErr, err)
+		}
+	}
+
+	err := r.send(models.ExecutionSteps{
+		Messages:   messages(title, statusColor(status), lines),
+		Status:     status,
+		FinishedAt: time.Now(),
+	})
+
+	return errors.Join(err, sinkErr)
+}
+
+// InvalidParams finishes the step with status error and lists every problem
+// found by DecodeParams as its own line
+func (r *StepReporter) InvalidParams(err error) error {
+	var paramErrs ParamErrors
+	if !errors.As(err, &paramErrs) {
+		return r.Fail("Parameters", err, "Invalid action parameters")
+	}
+
+	lines := []string{"Invalid action parameters"}
+	for _, paramErr := range paramErrs {
+		lines = append(lines, paramErr.Error())
+	}
+
+	return r.Finish(StatusError, "Parameters", lines...)
+}
+
+// Cancelled finishes the step with status canceled and reports why ctx was canceled
+func (r *StepReporter) Cancelled(ctx context.Context) error {
+	return r.Finish(StatusCanceled, "Cancel", CancelReason(ctx))
+}
+
+func messages(title string, color string, lines []string) []models.Message {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	result := make([]models.Line, 0, len(lines))
+	for _, line := range lines {
+		result = append(result, models.Line{
+			Content: line,
+			Color:   color,
+		})
+	}
+
+	return []models.Message{
+		{
+			Title: title,
+			Lines: result,
+		},
+	}
+}
+
+func statusColor(status string) string {
+	switch status {
+	case StatusSuccess:
+		return ColorSuccess
+	case StatusError, StatusCanceled:
+		return ColorDanger
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,141 @@
+package sdk
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/v1Flows/runner/config"
+	"github.com/v1Flows/runner/pkg/plugins"
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+func TestStepReporterRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int32
+		wantErr  bool
+	}{
+		{name: "success", statuses: []int{http.StatusOK}, attempts: 1},
+		{name: "server error is retried", statuses: []int{http.StatusBadGateway, http.StatusOK}, attempts: 2},
+		{name: "rate limit is retried", statuses: []int{http.StatusTooManyRequests, http.StatusOK}, attempts: 2},
+		{name: "client error fails at once", statuses: []int{http.StatusBadRequest}, attempts: 1, wantErr: true},
+		{name: "not found fails at once", statuses: []int{http.StatusNotFound}, attempts: 1, wantErr: true},
+		{name: "retries are exhausted", statuses: []int{http.StatusServiceUnavailable}, attempts: 4, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(attempts.Add(1)) - 1
+				w.WriteHeader(tt.statuses[min(n, len(tt.statuses)-1)])
+			}))
+			defer server.Close()
+
+			reporter := NewStepReporter(testRequest(server.URL))
+			reporter.RetryBackoff = 0
+
+			err := reporter.Info("Test", "line")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Info() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.attempts {
+				t.Errorf("got %d attempts, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestStepReporterRetriesNetworkErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	reporter := NewStepReporter(testRequest(url))
+	reporter.Retries = 1
+	reporter.RetryBackoff = 0
+
+	if err := reporter.Info("Test", "line"); err == nil {
+		t.Fatal("Info() succeeded without a platform")
+	}
+}
+
+func TestUpdateIsSentWhenSinkFlushFails(t *testing.T) {
+	var mu sync.Mutex
+	var statuses []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var step models.ExecutionSteps
+		_ = json.NewDecoder(r.Body).Decode(&step)
+		if len(step.Messages) > 0 && step.Messages[0].Title == "Output" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		statuses = append(statuses, step.Status)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	reporter := NewStepReporter(testRequest(server.URL))
+	sink := NewLineSink(reporter, "Output", LineSinkConfig{Interval: time.Hour})
+	defer sink.Close()
+	if err := sink.Add("line", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	err := reporter.Update(models.ExecutionSteps{Status: StatusRunning})
+	if err == nil {
+		t.Error("Update() did not return the flush error")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(statuses) != 1 || statuses[0] != StatusRunning {
+		t.Errorf("got status updates %q, want the running update", statuses)
+	}
+}
+
+func testRequest(url string) plugins.ExecuteTaskRequest {
+	cfg := &config.Config{}
+	cfg.ExFlow.URL = url
+
+	return plugins.ExecuteTaskRequest{
+		Config:    cfg,
+		Execution: models.Executions{ID: uuid.New()},
+		Step:      models.ExecutionSteps{ID: uuid.New()},
+		Platform:  "exflow",
+	}
+}
+
+func TestDecodeParamsRedactsDeclaredPasswords(t *testing.T) {
+	type params struct {
+		User     string `param:"user"`
+		Password string `param:"password" type:"password"`
+		Token    string `param:"token" type:"password" default:"default-token"`
+	}
+
+	request := testRequest("")
+	request.Step.Action.Params = []models.Params{
+		{Key: "user", Value: "alice"},
+		{Key: "password", Value: "hunter2"},
+	}
+
+	reporter := NewStepReporter(request)
+	var v params
+	if err := reporter.DecodeParams(ParamSchema(params{}), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	got := reporter.Redactor.String("alice hunter2 default-token")
+	if want := "alice **** ****"; got != want {
+		t.Errorf("redacted = %q, want %q", got, want)
+	}
+}
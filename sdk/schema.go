@@ -0,0 +1,210 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+// ParamSchema returns the action params declared by the struct v, in field order.
+// It is the counterpart of DecodeParams, so a plugin declares its params once and
+// uses the same struct for Info() and ExecuteTask(). Every field with a `param`
+// tag becomes one param, described by these tags:
+//
+//	param:"key[,required]"  the param key, optionally marked as required
+//	title:"..."              the title shown in the UI
+//	type:"..."               text, textarea, password, number, boolean or select;
+//	                         inferred from the field type if omitted
+//	default:"..."            the default value
+//	description:"..."        the description shown in the UI
+//	category:"..."           the category the param is grouped under
+//	options:"k=Value,..."    the options of a select param
+//	depends:"key=value"      only show the param if another param has this value,
+//	                         "*" matches any non-empty value
+//	formerly:"key"           the old key of a renamed param, DecodeParams reads it
+//	                         from steps of flows saved before the rename
+//
+// Non-zero field values of v override the default tag, which allows defaults
+// that are only known at runtime, e.g. paths inside the workspace.
+func ParamSchema(v interface{}) []models.Params {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		panic("ParamSchema requires a struct")
+	}
+
+	var schema []models.Params
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		key := paramKey(field)
+		if key == "" || !field.IsExported() {
+			continue
+		}
+
+		param := models.Params{
+			Key:         key,
+			Title:       field.Tag.Get("title"),
+			Type:        paramType(field),
+			Default:     field.Tag.Get("default"),
+			Required:    paramRequired(field),
+			Description: field.Tag.Get("description"),
+			Category:    field.Tag.Get("category"),
+			Options:     paramOptions(field),
+		}
+
+		if depends := field.Tag.Get("depends"); depends != "" {
+			dependsKey, dependsValue, _ := strings.Cut(depends, "=")
+			param.DependsOn = models.DependsOn{
+				Key:   dependsKey,
+				Value: dependsValue,
+			}
+		}
+
+		if fieldValue := value.Field(i); !fieldValue.IsZero() {
+			param.Default = formatField(fieldValue)
+		}
+
+		schema = append(schema, param)
+	}
+
+	return schema
+}
+
+// CheckParams verifies that schema, usually the params returned by Info(), and
+// the struct v passed to DecodeParams agree: every declared key must be consumed
+// by a field, every field must be declared, keys must be unique, also ignoring
+// case, DependsOn must
+// reference a declared param and defaults must be valid for their type. The
+// standard timeout param is consumed by the TaskRegistry and needs no field.
+func CheckParams(schema []models.Params, v interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return errors.New("CheckParams requires a struct")
+	}
+
+	fields := make(map[string]bool)
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		if key := paramKey(structType.Field(i)); key != "" {
+			fields[key] = true
+		}
+	}
+
+	var problems []string
+
+	declared := make(map[string]bool, len(schema))
+	folded := make(map[string]string, len(schema))
+	for _, param := range schema {
+		if declared[param.Key] {
+			problems = append(problems, fmt.Sprintf("param %q is declared more than once", param.Key))
+		} else if other, ok := folded[strings.ToLower(param.Key)]; ok {
+			problems = append(problems, fmt.Sprintf("params %q and %q differ only in case", other, param.Key))
+		}
+		declared[param.Key] = true
+		folded[strings.ToLower(param.Key)] = param.Key
+	}
+
+	for _, param := range schema {
+		if !fields[param.Key] && param.Key != TimeoutParamKey {
+			problems = append(problems, fmt.Sprintf("param %q is declared but not consumed", param.Key))
+		}
+		if param.DependsOn.Key != "" && !declared[param.DependsOn.Key] {
+			problems = append(problems, fmt.Sprintf("param %q depends on undeclared param %q", param.Key, param.DependsOn.Key))
+		}
+		if param.Default != "" {
+			if err := validateParam(param, param.Default); err != nil {
+				problems = append(problems, fmt.Sprintf("param %q has an invalid default: %s", param.Key, err))
+			}
+		}
+	}
+
+	for i := 0; i < structType.NumField(); i++ {
+		key := paramKey(structType.Field(i))
+		if key != "" && !declared[key] {
+			problems = append(problems, fmt.Sprintf("field %s consumes undeclared param %q", structType.Field(i).Name, key))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func paramRequired(field reflect.StructField) bool {
+	options := strings.Split(field.Tag.Get(ParamTag), ",")[1:]
+	for _, option := range options {
+		if option == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+func paramType(field reflect.StructField) string {
+	if t := field.Tag.Get("type"); t != "" {
+		return t
+	}
+
+	if field.Type == durationType {
+		return "text"
+	}
+
+	switch field.Type.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "textarea"
+	default:
+		return "text"
+	}
+}
+
+func paramOptions(field reflect.StructField) []models.Option {
+	tag := field.Tag.Get("options")
+	if tag == "" {
+		return nil
+	}
+
+	var options []models.Option
+	for _, option := range strings.Split(tag, ",") {
+		key, value, found := strings.Cut(option, "=")
+		if !found {
+			value = key
+		}
+		options = append(options, models.Option{
+			Key:   key,
+			Value: value,
+		})
+	}
+
+	return options
+}
+
+func formatField(field reflect.Value) string {
+	if field.Type() == durationType {
+		return field.Interface().(fmt.Stringer).String()
+	}
+
+	switch field.Kind() {
+	case reflect.Slice:
+		items := make([]string, 0, field.Len())
+		for i := 0; i < field.Len(); i++ {
+			items = append(items, fmt.Sprint(field.Index(i).Interface()))
+		}
+		return strings.Join(items, "\n")
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool())
+	default:
+		return fmt.Sprint(field.Interface())
+	}
+}
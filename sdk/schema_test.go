@@ -0,0 +1,83 @@
+package sdk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+func TestParamSchema(t *testing.T) {
+	type params struct {
+		Target   string        `param:"target,required" title:"Target" category:"Destination" description:"The target server"`
+		Port     uint16        `param:"port" default:"22"`
+		Wait     time.Duration `param:"wait"`
+		Verbose  bool          `param:"verbose"`
+		Method   string        `param:"method" type:"select" default:"password" options:"password=Password,agent"`
+		Password string        `param:"password" type:"password" depends:"method=password"`
+		Commands []string      `param:"commands"`
+		Dir      string        `param:"dir" default:"/tmp"`
+		Ignored  string        `param:"-"`
+		internal string        `param:"internal"`
+	}
+
+	got := ParamSchema(params{Dir: "/workspace", internal: "x"})
+	want := []models.Params{
+		{Key: "target", Title: "Target", Type: "text", Required: true, Category: "Destination", Description: "The target server"},
+		{Key: "port", Type: "number", Default: "22"},
+		{Key: "wait", Type: "text"},
+		{Key: "verbose", Type: "boolean"},
+		{Key: "method", Type: "select", Default: "password", Options: []models.Option{{Key: "password", Value: "Password"}, {Key: "agent", Value: "agent"}}},
+		{Key: "password", Type: "password", DependsOn: models.DependsOn{Key: "method", Value: "password"}},
+		{Key: "commands", Type: "textarea"},
+		{Key: "dir", Type: "text", Default: "/workspace"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParamSchema() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestCheckParams(t *testing.T) {
+	type params struct {
+		Method   string `param:"method" type:"select" default:"password" options:"password,key"`
+		Password string `param:"password" depends:"method=password"`
+	}
+	valid := ParamSchema(params{})
+
+	tests := []struct {
+		name    string
+		schema  []models.Params
+		v       interface{}
+		problem string
+	}{
+		{name: "valid", schema: valid, v: params{}},
+		{name: "standard timeout needs no field", schema: append(ParamSchema(params{}), TimeoutParam()), v: params{}},
+		{name: "duplicate key", schema: append(ParamSchema(params{}), models.Params{Key: "password"}), v: params{}, problem: `param "password" is declared more than once`},
+		{name: "keys differing in case", schema: append(ParamSchema(params{}), models.Params{Key: "Method"}), v: params{}, problem: `params "method" and "Method" differ only in case`},
+		{name: "not consumed", schema: append(ParamSchema(params{}), models.Params{Key: "port"}), v: params{}, problem: `param "port" is declared but not consumed`},
+		{name: "not declared", schema: valid[:1], v: params{}, problem: `field Password consumes undeclared param "password"`},
+		{name: "undeclared dependency", schema: []models.Params{valid[1]}, v: struct {
+			Password string `param:"password"`
+		}{}, problem: `param "password" depends on undeclared param "method"`},
+		{name: "invalid default", schema: []models.Params{{Key: "method", Type: "select", Default: "agent", Options: valid[0].Options}, valid[1]}, v: params{}, problem: `param "method" has an invalid default: "agent" is not one of password, key`},
+		{name: "not a struct", v: "params", problem: "CheckParams requires a struct"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckParams(tt.schema, tt.v)
+			if tt.problem == "" {
+				if err != nil {
+					t.Errorf("CheckParams() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("CheckParams() error = %v, want %q", err, tt.problem)
+			}
+		})
+	}
+}
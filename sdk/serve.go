@@ -0,0 +1,78 @@
+package sdk
+
+import (
+	"net/rpc"
+
+	"github.com/v1Flows/runner/pkg/plugins"
+	"github.com/v1Flows/shared-library/pkg/models"
+
+	"github.com/hashicorp/go-plugin"
+)
+
+// PluginName is the name under which the runner dispenses every plugin
+const PluginName = "plugin"
+
+// HandshakeConfig is the handshake the runner expects from every plugin
+var HandshakeConfig = plugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
+	MagicCookieValue: "hello",
+}
+
+// Serve starts the plugin server for impl and blocks until the runner disconnects
+func Serve(impl plugins.Plugin) {
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: HandshakeConfig,
+		Plugins:         PluginMap(impl),
+		GRPCServer:      plugin.DefaultGRPCServer,
+	})
+}
+
+// PluginMap returns the plugin set shared by the plugin server and its clients
+func PluginMap(impl plugins.Plugin) map[string]plugin.Plugin {
+	return map[string]plugin.Plugin{
+		PluginName: &PluginServer{Impl: impl},
+	}
+}
+
+// PluginRPCServer is the RPC server for Plugin
+type PluginRPCServer struct {
+	Impl plugins.Plugin
+}
+
+func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
+	result, err := s.Impl.ExecuteTask(request)
+	*resp = result
+	return err
+}
+
+func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
+	result, err := s.Impl.CancelTask(request)
+	*resp = result
+	return err
+}
+
+func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
+	result, err := s.Impl.EndpointRequest(request)
+	*resp = result
+	return err
+}
+
+func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *models.Plugin) error {
+	result, err := s.Impl.Info(request)
+	*resp = result
+	return err
+}
+
+// PluginServer is the implementation of plugin.Plugin interface
+type PluginServer struct {
+	Impl plugins.Plugin
+}
+
+func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
+	return &PluginRPCServer{Impl: p.Impl}, nil
+}
+
+func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
+	return &plugins.PluginRPC{Client: c}, nil
+}
@@ -0,0 +1,278 @@
+package sdk
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+// Defaults used by NewLineSink for zero values in LineSinkConfig
+const (
+	DefaultFlushInterval = 500 * time.Millisecond
+	DefaultMaxLines      = 100
+)
+
+// ErrSinkClosed is returned for lines added to a LineSink after Close
+var ErrSinkClosed = errors.New("line sink is closed")
+
+// LineSinkConfig controls how often a LineSink sends its buffered lines
+type LineSinkConfig struct {
+	// Interval is the maximum time a line stays in the buffer
+	Interval time.Duration
+	// MaxLines flushes the buffer as soon as it holds this many lines
+	MaxLines int
+}
+
+// LineSink collects output lines and sends them to the step in batches
+// instead of doing one backend round-trip per line. It also implements
+// io.Writer, splitting the written bytes into lines. Adding lines never waits
+// for the platform, the batches are sent by a background goroutine.
+type LineSink struct {
+	reporter *StepReporter
+	title    string
+	config   LineSinkConfig
+
+	mu      sync.Mutex
+	lines   []models.Line
+	partial bytes.Buffer
+	err     error
+	closed  bool
+
+	// sendMu keeps the batches in order, it is held while a batch is sent
+	sendMu sync.Mutex
+
+	full chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewLineSink returns a LineSink adding its lines to the step of reporter under title.
+// The sink is flushed and closed automatically when the reporter finishes the step.
+func NewLineSink(reporter *StepReporter, title string, config LineSinkConfig) *LineSink {
+	if config.Interval <= 0 {
+		config.Interval = DefaultFlushInterval
+	}
+	if config.MaxLines <= 0 {
+		config.MaxLines = DefaultMaxLines
+	}
+
+	s := &LineSink{
+		reporter: reporter,
+		title:    title,
+		config:   config,
+		full:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	reporter.addSink(s)
+
+	go s.run()
+
+	return s
+}
+
+func (s *LineSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = s.Flush()
+		case <-s.full:
+			_ = s.Flush()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Add buffers a line with the given color
+func (s *LineSink) Add(content string, color string) error {
+	return s.AddLine(models.Line{
+		Content:   content,
+		Color:     color,
+		Timestamp: time.Now(),
+	})
+}
+
+// AddLine buffers a preformatted line. It returns ErrSinkClosed once the sink
+// is closed, errors sending the lines are returned by Flush and Close.
+func (s *LineSink) AddLine(line models.Line) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrSinkClosed
+	}
+	if line.Timestamp.IsZero() {
+		line.Timestamp = time.Now()
+	}
+	s.lines = append(s.lines, line)
+
+	if len(s.lines) >= s.config.MaxLines {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Write splits p into lines and buffers them. An unterminated trailing line is kept
+// until the next newline or until the sink is closed.
+func (s *LineSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return 0, ErrSinkClosed
+	}
+	s.partial.Write(p)
+	var lines []string
+	for {
+		data := s.partial.Bytes()
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, strings.TrimSuffix(string(data[:i]), "\r"))
+		s.partial.Next(i + 1)
+	}
+	s.mu.Unlock()
+
+	for _, line := range lines {
+		if err := s.Add(line, ""); err != nil {
+			return len(p), err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Writer returns an io.WriteCloser adding the lines written to it in color.
+// Every writer keeps its own unterminated trailing line, so the stdout and
+// stderr of a command can be written at the same time without mixing partial
+// lines. Close adds the trailing line.
+func (s *LineSink) Writer(color string) io.WriteCloser {
+	return &lineWriter{sink: s, color: color}
+}
+
+type lineWriter struct {
+	sink  *LineSink
+	color string
+
+	mu      sync.Mutex
+	partial bytes.Buffer
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	w.partial.Write(p)
+	var lines []string
+	for {
+		data := w.partial.Bytes()
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, strings.TrimSuffix(string(data[:i]), "\r"))
+		w.partial.Next(i + 1)
+	}
+	w.mu.Unlock()
+
+	for _, line := range lines {
+		if err := w.sink.Add(line, w.color); err != nil {
+			return len(p), err
+		}
+	}
+
+	return len(p), nil
+}
+
+func (w *lineWriter) Close() error {
+	w.mu.Lock()
+	line := strings.TrimSuffix(w.partial.String(), "\r")
+	w.partial.Reset()
+	w.mu.Unlock()
+
+	if line == "" {
+		return nil
+	}
+
+	return w.sink.Add(line, w.color)
+}
+
+// Flush sends all buffered lines in a single step update. Lines can be added
+// while the update is sent, they go with the next one.
+func (s *LineSink) Flush() error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	s.mu.Lock()
+	lines := s.lines
+	s.lines = nil
+	s.mu.Unlock()
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	err := s.reporter.send(models.ExecutionSteps{
+		Messages: []models.Message{
+			{
+				Title: s.title,
+				Lines: lines,
+			},
+		},
+	})
+	if err != nil {
+		s.mu.Lock()
+		if s.err == nil {
+			s.err = err
+		}
+		s.mu.Unlock()
+	}
+
+	return err
+}
+
+// Close flushes the remaining lines, including an unterminated trailing line, and
+// stops the background flushing. It returns the first error the sink ran into.
+// Lines added after Close are rejected with ErrSinkClosed.
+func (s *LineSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		err := s.err
+		s.mu.Unlock()
+		return err
+	}
+	s.closed = true
+	if s.partial.Len() > 0 {
+		s.lines = append(s.lines, models.Line{
+			Content:   strings.TrimSuffix(s.partial.String(), "\r"),
+			Timestamp: time.Now(),
+		})
+		s.partial.Reset()
+	}
+	s.mu.Unlock()
+
+	close(s.stop)
+	<-s.done
+
+	s.reporter.removeSink(s)
+
+	_ = s.Flush()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
@@ -0,0 +1,85 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+func TestLineSinkAddDoesNotWaitForSend(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var received []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+
+		var step models.ExecutionSteps
+		_ = json.NewDecoder(r.Body).Decode(&step)
+		mu.Lock()
+		for _, message := range step.Messages {
+			for _, line := range message.Lines {
+				received = append(received, line.Content)
+			}
+		}
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	reporter := NewStepReporter(testRequest(server.URL))
+	sink := NewLineSink(reporter, "Output", LineSinkConfig{MaxLines: 2, Interval: time.Hour})
+
+	added := make(chan struct{})
+	go func() {
+		defer close(added)
+		for i := 0; i < 10; i++ {
+			_ = sink.Add(strconv.Itoa(i), "")
+		}
+	}()
+
+	select {
+	case <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Add blocked while a batch was sent")
+	}
+
+	close(release)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 10 {
+		t.Fatalf("received %d lines, want 10", len(received))
+	}
+	for i, line := range received {
+		if line != strconv.Itoa(i) {
+			t.Fatalf("line %d = %q, lines are out of order: %v", i, line, received)
+		}
+	}
+}
+
+func TestLineSinkAddAfterClose(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	sink := NewLineSink(NewStepReporter(testRequest(server.URL)), "Output", LineSinkConfig{})
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if err := sink.Add("late", ""); !errors.Is(err, ErrSinkClosed) {
+		t.Errorf("Add() error = %v, want ErrSinkClosed", err)
+	}
+	if _, err := sink.Write([]byte("late\n")); !errors.Is(err, ErrSinkClosed) {
+		t.Errorf("Write() error = %v, want ErrSinkClosed", err)
+	}
+}
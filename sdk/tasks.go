@@ -0,0 +1,201 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/v1Flows/runner/pkg/plugins"
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+// TimeoutParamKey is the action param holding the maximum runtime of a step
+const TimeoutParamKey = "timeout"
+
+// finishedRetention is how long a finished step is remembered so late cancel
+// requests can be answered without an error
+const finishedRetention = time.Hour
+
+// ErrTaskNotFound is returned by TaskRegistry.Cancel for steps it has never seen
+var ErrTaskNotFound = errors.New("task not found")
+
+// Cancellation is the cause attached to the context of a canceled step
+type Cancellation struct {
+	// By is the user who canceled the step, empty for timeouts
+	By string
+	// At is the time the step was canceled
+	At time.Time
+	// Reason is a human readable description of the cancellation
+	Reason string
+}
+
+func (c *Cancellation) Error() string {
+	return c.Reason
+}
+
+// TimeoutParam returns the action param declaring the standard step timeout
+func TimeoutParam() models.Params {
+	return models.Params{
+		Key:         TimeoutParamKey,
+		Title:       "Timeout",
+		Type:        "text",
+		Default:     "",
+		Required:    false,
+		Description: "Maximum runtime of the action, e.g. 90s, 5m or 1h. A plain number is read as seconds. Leave empty for no limit",
+		Category:    "Execution",
+	}
+}
+
+// ParseTimeout reads the standard timeout param of request. It returns 0 if no
+// timeout is set and ParamErrors if the timeout is invalid.
+func ParseTimeout(request plugins.ExecuteTaskRequest) (time.Duration, error) {
+	for _, param := range request.Step.Action.Params {
+		if param.Key != TimeoutParamKey {
+			continue
+		}
+
+		value := strings.TrimSpace(param.Value)
+		if value == "" || value == "0" {
+			return 0, nil
+		}
+
+		if seconds, err := strconv.Atoi(value); err == nil {
+			if seconds < 0 {
+				return 0, timeoutError("%q must not be negative", value)
+			}
+			return time.Duration(seconds) * time.Second, nil
+		}
+
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, timeoutError("%q is not a duration", value)
+		}
+		if timeout < 0 {
+			return 0, timeoutError("%q must not be negative", value)
+		}
+
+		return timeout, nil
+	}
+
+	return 0, nil
+}
+
+func timeoutError(format string, value string) error {
+	return ParamErrors{{Key: TimeoutParamKey, Title: "Timeout", Message: fmt.Sprintf(format, value)}}
+}
+
+// TaskRegistry keeps track of the running steps of a plugin so CancelTask can
+// stop them
+type TaskRegistry struct {
+	mu       sync.Mutex
+	running  map[string]context.CancelCauseFunc
+	finished map[string]time.Time
+}
+
+// NewTaskRegistry returns an empty TaskRegistry
+func NewTaskRegistry() *TaskRegistry {
+	return &TaskRegistry{
+		running:  make(map[string]context.CancelCauseFunc),
+		finished: make(map[string]time.Time),
+	}
+}
+
+// Register adds the step of request to the registry. The returned context is
+// canceled by Cancel or when the timeout param of the step expires. The returned
+// func must be called once the step is done. An invalid timeout fails the step
+// like any other invalid param before the error is returned.
+func (r *TaskRegistry) Register(request plugins.ExecuteTaskRequest) (context.Context, func(), error) {
+	timeout, err := ParseTimeout(request)
+	if err != nil {
+		_ = NewStepReporter(request).InvalidParams(err)
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	var stopTimeout context.CancelFunc = func() {}
+	if timeout > 0 {
+		ctx, stopTimeout = context.WithTimeoutCause(ctx, timeout, &Cancellation{
+			At:     time.Now().Add(timeout),
+			Reason: "Action exceeded its timeout of " + timeout.String(),
+		})
+	}
+
+	stepID := request.Step.ID.String()
+
+	r.mu.Lock()
+	r.running[stepID] = cancel
+	delete(r.finished, stepID)
+	r.mu.Unlock()
+
+	done := func() {
+		r.mu.Lock()
+		delete(r.running, stepID)
+		r.finished[stepID] = time.Now()
+		r.pruneLocked()
+		r.mu.Unlock()
+
+		stopTimeout()
+		cancel(nil)
+	}
+
+	return ctx, done, nil
+}
+
+// Cancel stops the step of request and records who canceled it. Steps that
+// already finished are reported as successfully canceled.
+func (r *TaskRegistry) Cancel(request plugins.CancelTaskRequest) (plugins.Response, error) {
+	stepID := request.Step.ID.String()
+
+	r.mu.Lock()
+	cancel, running := r.running[stepID]
+	_, finished := r.finished[stepID]
+	r.mu.Unlock()
+
+	if !running {
+		if finished {
+			return plugins.Response{Success: true}, nil
+		}
+
+		return plugins.Response{
+			Success: false,
+		}, ErrTaskNotFound
+	}
+
+	cancellation := &Cancellation{
+		By:     request.Step.CanceledBy,
+		At:     request.Step.CanceledAt,
+		Reason: "Action canceled",
+	}
+	if cancellation.At.IsZero() {
+		cancellation.At = time.Now()
+	}
+	if cancellation.By != "" {
+		cancellation.Reason = "Action canceled by " + cancellation.By
+	}
+
+	cancel(cancellation)
+	return plugins.Response{Success: true}, nil
+}
+
+func (r *TaskRegistry) pruneLocked() {
+	for stepID, finishedAt := range r.finished {
+		if time.Since(finishedAt) > finishedRetention {
+			delete(r.finished, stepID)
+		}
+	}
+}
+
+// CancelReason describes why ctx was canceled, suitable as a step message line
+func CancelReason(ctx context.Context) string {
+	var cancellation *Cancellation
+	if errors.As(context.Cause(ctx), &cancellation) {
+		return cancellation.Reason
+	}
+
+	return "Action canceled"
+}
@@ -0,0 +1,154 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/v1Flows/runner/pkg/plugins"
+	"github.com/v1Flows/shared-library/pkg/models"
+)
+
+func timeoutRequest(url string, timeout string) plugins.ExecuteTaskRequest {
+	request := testRequest(url)
+	request.Step.Action.Params = []models.Params{{Key: TimeoutParamKey, Value: timeout}}
+	return request
+}
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "0", want: 0},
+		{value: " 30 ", want: 30 * time.Second},
+		{value: "90s", want: 90 * time.Second},
+		{value: "1h30m", want: 90 * time.Minute},
+		{value: "abc", wantErr: true},
+		{value: "10 minutes", wantErr: true},
+		{value: "-5", wantErr: true},
+		{value: "-1m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseTimeout(timeoutRequest("", tt.value))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTimeout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var paramErrs ParamErrors
+			if err != nil && (!errors.As(err, &paramErrs) || paramErrs[0].Key != TimeoutParamKey) {
+				t.Errorf("ParseTimeout() error = %#v, want ParamErrors for %q", err, TimeoutParamKey)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got, err := ParseTimeout(testRequest("")); got != 0 || err != nil {
+		t.Errorf("ParseTimeout() without param = %v, %v, want 0, nil", got, err)
+	}
+}
+
+func TestRegisterReportsInvalidTimeout(t *testing.T) {
+	var mu sync.Mutex
+	var steps []models.ExecutionSteps
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var step models.ExecutionSteps
+		_ = json.NewDecoder(r.Body).Decode(&step)
+		mu.Lock()
+		steps = append(steps, step)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	_, _, err := NewTaskRegistry().Register(timeoutRequest(server.URL, "abc"))
+	if err == nil {
+		t.Fatal("Register() accepted an invalid timeout")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(steps) != 1 || steps[0].Status != StatusError {
+		t.Fatalf("got step updates %+v, want one update with status error", steps)
+	}
+	lines := steps[0].Messages[0].Lines
+	if got, want := lines[len(lines)-1].Content, `Timeout: "abc" is not a duration`; got != want {
+		t.Errorf("last line = %q, want %q", got, want)
+	}
+}
+
+func TestRegisterTimeout(t *testing.T) {
+	ctx, done, err := NewTaskRegistry().Register(timeoutRequest("", "50ms"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("step was not canceled after its timeout")
+	}
+
+	if got, want := CancelReason(ctx), "Action exceeded its timeout of 50ms"; got != want {
+		t.Errorf("CancelReason() = %q, want %q", got, want)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	tasks := NewTaskRegistry()
+	request := testRequest("")
+
+	ctx, done, err := tasks.Register(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step := request.Step
+	step.CanceledBy = "alice"
+	response, err := tasks.Cancel(plugins.CancelTaskRequest{Step: step})
+	if err != nil || !response.Success {
+		t.Fatalf("Cancel() = %+v, %v", response, err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("context of the canceled step is still running")
+	}
+	if got, want := CancelReason(ctx), "Action canceled by alice"; got != want {
+		t.Errorf("CancelReason() = %q, want %q", got, want)
+	}
+
+	// late cancel requests of finished steps succeed
+	done()
+	response, err = tasks.Cancel(plugins.CancelTaskRequest{Step: request.Step})
+	if err != nil || !response.Success {
+		t.Errorf("Cancel() of a finished step = %+v, %v", response, err)
+	}
+
+	_, err = tasks.Cancel(plugins.CancelTaskRequest{Step: testRequest("").Step})
+	if !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Cancel() of an unknown step error = %v, want %v", err, ErrTaskNotFound)
+	}
+}
+
+func TestCancelReason(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got, want := CancelReason(ctx), "Action canceled"; got != want {
+		t.Errorf("CancelReason() = %q, want %q", got, want)
+	}
+
+	ctx, cancelCause := context.WithCancelCause(context.Background())
+	cancelCause(&Cancellation{By: "bob", Reason: "Action canceled by bob"})
+	if got, want := CancelReason(ctx), "Action canceled by bob"; got != want {
+		t.Errorf("CancelReason() = %q, want %q", got, want)
+	}
+}
@@ -1,70 +1,58 @@
 package main
 
 import (
-	"context"
-	"errors"
-	"net/rpc"
-	"sync"
-	"time"
-
-	"github.com/v1Flows/runner/pkg/executions"
+	"github.com/v1Flows/runner-plugins/sdk"
 	"github.com/v1Flows/runner/pkg/plugins"
 
 	"github.com/v1Flows/shared-library/pkg/models"
-
-	"github.com/hashicorp/go-plugin"
 )
 
 // Plugin is an implementation of the Plugin interface
-type Plugin struct{}
+type Plugin struct {
+	sdk.ActionPlugin
+}
 
-var (
-	taskCancels   = make(map[string]context.CancelFunc)
-	taskCancelsMu sync.Mutex
-)
+var tasks = sdk.NewTaskRegistry()
+
+// Params are the action params of the template plugin
+type Params struct {
+	Text     string `param:"text" title:"Text" category:"General" description:"This param represents the text to be used in the action"`
+	Password string `param:"password" title:"Password" type:"password" category:"General" description:"This param represents the password to be used in the action and will be encrypted on the backend"`
+	Number   int    `param:"number" title:"Number" default:"0" category:"General" description:"This param represents the number to be used in the action"`
+	Boolean  bool   `param:"boolean" title:"Boolean" default:"false" category:"General" description:"This param represents the boolean to be used in the action"`
+	Select   string `param:"select,required" title:"Select" type:"select" default:"1" options:"1,2,3" category:"General" description:"This param represents the select to be used in the action"`
+}
 
 func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Response, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	stepID := request.Step.ID.String()
+	ctx, done, err := tasks.Register(request)
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
+	defer done()
 
-	// Store cancel func
-	taskCancelsMu.Lock()
-	taskCancels[stepID] = cancel
-	taskCancelsMu.Unlock()
-	defer func() {
-		taskCancelsMu.Lock()
-		delete(taskCancels, stepID)
-		taskCancelsMu.Unlock()
-	}()
+	reporter := sdk.NewStepReporter(request)
 
-	param1 := ""
+	info, err := p.Info(plugins.InfoRequest{Config: request.Config, Workspace: request.Workspace})
+	if err != nil {
+		return plugins.Response{
+			Success: false,
+		}, err
+	}
 
-	for _, param := range request.Step.Action.Params {
-		if param.Key == "param1" {
-			param1 = param.Value
-		}
+	var params Params
+	err = reporter.DecodeParams(info.Action.Params, &params)
+	if err != nil {
+		_ = reporter.InvalidParams(err)
+		return plugins.Response{
+			Success: false,
+		}, err
 	}
 
 	// Check for cancellation before each major step
 	if ctx.Err() != nil {
-		err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-			ID: request.Step.ID,
-			Messages: []models.Message{
-				{
-					Title: "Cancel",
-					Lines: []models.Line{
-						{
-							Content:   "Action canceled",
-							Color:     "danger",
-							Timestamp: time.Now(),
-						},
-					},
-				},
-			},
-			Status:     "canceled",
-			FinishedAt: time.Now(),
-		}, request.Platform)
-		if err != nil {
+		if err := reporter.Cancelled(ctx); err != nil {
 			return plugins.Response{
 				Success: false,
 			}, err
@@ -74,22 +62,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	// start the action
-	err := executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
-		Messages: []models.Message{
-			{
-				Title: "Action",
-				Lines: []models.Line{
-					{
-						Content:   "Action started",
-						Timestamp: time.Now(),
-					},
-				},
-			},
-		},
-		Status:    "running",
-		StartedAt: time.Now(),
-	}, request.Platform)
+	err = reporter.Start("Action", "Action started")
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -97,20 +70,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	// add message
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
-		Messages: []models.Message{
-			{
-				Title: "Action",
-				Lines: []models.Line{
-					{
-						Content:   "Action update",
-						Timestamp: time.Now(),
-					},
-				},
-			},
-		},
-	}, request.Platform)
+	err = reporter.Info("Action", "Action update: "+params.Text)
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -118,23 +78,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 	}
 
 	// finish the action
-	// start the action
-	err = executions.UpdateStep(request.Config, request.Execution.ID.String(), models.ExecutionSteps{
-		ID: request.Step.ID,
-		Messages: []models.Message{
-			{
-				Title: "Action",
-				Lines: []models.Line{
-					{
-						Content:   "Action finished",
-						Timestamp: time.Now(),
-					},
-				},
-			},
-		},
-		Status:     "success",
-		FinishedAt: time.Now(),
-	}, request.Platform)
+	err = reporter.Finish(sdk.StatusSuccess, "Action", "Action finished")
 	if err != nil {
 		return plugins.Response{
 			Success: false,
@@ -147,25 +91,7 @@ func (p *Plugin) ExecuteTask(request plugins.ExecuteTaskRequest) (plugins.Respon
 }
 
 func (p *Plugin) CancelTask(request plugins.CancelTaskRequest) (plugins.Response, error) {
-	stepID := request.Step.ID.String()
-	taskCancelsMu.Lock()
-	cancel, ok := taskCancels[stepID]
-	taskCancelsMu.Unlock()
-
-	if !ok {
-		return plugins.Response{
-			Success: false,
-		}, errors.New("task not found")
-	}
-
-	cancel()
-	return plugins.Response{Success: true}, nil
-}
-
-func (p *Plugin) EndpointRequest(request plugins.EndpointRequest) (plugins.Response, error) {
-	return plugins.Response{
-		Success: false,
-	}, errors.New("not implemented")
+	return tasks.Cancel(request)
 }
 
 func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
@@ -180,67 +106,7 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 			Plugin:      "template",
 			Icon:        "hugeicons:files-01",
 			Category:    "Utility",
-			Params: []models.Params{
-				{
-					Key:         "text",
-					Title:       "Text",
-					Type:        "text",
-					Default:     "",
-					Required:    false,
-					Description: "This param represents the text to be used in the action",
-					Category:    "General",
-				},
-				{
-					Key:         "password",
-					Title:       "Password",
-					Category:    "General",
-					Type:        "password",
-					Default:     "",
-					Required:    false,
-					Description: "This param represents the password to be used in the action and will be encrypted on the backend",
-				},
-				{
-					Key:         "number",
-					Title:       "Number",
-					Category:    "General",
-					Type:        "number",
-					Default:     "0",
-					Required:    false,
-					Description: "This param represents the number to be used in the action",
-				},
-				{
-					Key:         "boolean",
-					Title:       "Boolean",
-					Category:    "General",
-					Type:        "boolean",
-					Default:     "false",
-					Required:    false,
-					Description: "This param represents the boolean to be used in the action",
-				},
-				{
-					Key:         "select",
-					Title:       "Select",
-					Description: "This param represents the select to be used in the action",
-					Type:        "select",
-					Default:     "1",
-					Required:    true,
-					Category:    "General",
-					Options: []models.Option{
-						{
-							Key:   "1",
-							Value: "1",
-						},
-						{
-							Key:   "2",
-							Value: "2",
-						},
-						{
-							Key:   "3",
-							Value: "3",
-						},
-					},
-				},
-			},
+			Params:      append(sdk.ParamSchema(Params{}), sdk.TimeoutParam()),
 		},
 		Endpoint: models.Endpoint{},
 	}
@@ -248,58 +114,6 @@ func (p *Plugin) Info(request plugins.InfoRequest) (models.Plugin, error) {
 	return plugin, nil
 }
 
-// PluginRPCServer is the RPC server for Plugin
-type PluginRPCServer struct {
-	Impl plugins.Plugin
-}
-
-func (s *PluginRPCServer) ExecuteTask(request plugins.ExecuteTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.ExecuteTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) CancelTask(request plugins.CancelTaskRequest, resp *plugins.Response) error {
-	result, err := s.Impl.CancelTask(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) EndpointRequest(request plugins.EndpointRequest, resp *plugins.Response) error {
-	result, err := s.Impl.EndpointRequest(request)
-	*resp = result
-	return err
-}
-
-func (s *PluginRPCServer) Info(request plugins.InfoRequest, resp *models.Plugin) error {
-	result, err := s.Impl.Info(request)
-	*resp = result
-	return err
-}
-
-// PluginServer is the implementation of plugin.Plugin interface
-type PluginServer struct {
-	Impl plugins.Plugin
-}
-
-func (p *PluginServer) Server(*plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
-}
-
-func (p *PluginServer) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &plugins.PluginRPC{Client: c}, nil
-}
-
 func main() {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: plugin.HandshakeConfig{
-			ProtocolVersion:  1,
-			MagicCookieKey:   "PLUGIN_MAGIC_COOKIE",
-			MagicCookieValue: "hello",
-		},
-		Plugins: map[string]plugin.Plugin{
-			"plugin": &PluginServer{Impl: &Plugin{}},
-		},
-		GRPCServer: plugin.DefaultGRPCServer,
-	})
+	sdk.Serve(&Plugin{})
 }